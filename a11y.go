@@ -0,0 +1,64 @@
+package pipefence
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// ErrNoAltText is the error of blocks with image outputs but without
+// alt text, with RequireAlt.
+var ErrNoAltText = errors.New("image without alt or caption option")
+
+// altText returns the alt text of a block, taken from the alt or
+// caption option.
+func altText(info Info) string {
+	if alt := info.Options["alt"]; alt != "" {
+		return alt
+	}
+	return info.Options["caption"]
+}
+
+var (
+	svgRoleRE      = regexp.MustCompile(`\srole="[^"]*"`)
+	svgAriaLabelRE = regexp.MustCompile(`\saria-label(?:ledby)?="[^"]*"`)
+)
+
+// accessibleSVG adds role="img" to the root element of svg, and, if
+// alt is not empty, an aria-label and a <title>.  Attributes which
+// are already present are kept.
+func accessibleSVG(svg []byte, alt string) []byte {
+	loc := SVGRoot(svg)
+	if loc == nil {
+		return svg
+	}
+	root := svg[loc[0]:loc[1]]
+	selfClosing := bytes.HasSuffix(root, []byte("/>"))
+	end := len(root) - len(">")
+	if selfClosing {
+		end -= len("/")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(svg[:loc[0]])
+	buf.Write(root[:end])
+	if !svgRoleRE.Match(root) {
+		buf.WriteString(` role="img"`)
+	}
+	escaped := util.EscapeHTML([]byte(alt))
+	if alt != "" && !svgAriaLabelRE.Match(root) {
+		buf.WriteString(` aria-label="`)
+		buf.Write(escaped)
+		buf.WriteString(`"`)
+	}
+	buf.Write(root[end:])
+	if alt != "" && !selfClosing {
+		buf.WriteString("<title>")
+		buf.Write(escaped)
+		buf.WriteString("</title>")
+	}
+	buf.Write(svg[loc[1]:])
+	return buf.Bytes()
+}
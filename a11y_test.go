@@ -0,0 +1,91 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestAccessible(t *testing.T) {
+	echo := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		return src, nil
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{"echo": echo},
+		Accessible:   true,
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Alt",
+			Input: "```echo alt=\"A & B\"\n<svg width=\"1\"><g/></svg>\n```\n",
+			Want:  "<svg width=\"1\" role=\"img\" aria-label=\"A &amp; B\"><title>A &amp; B</title><g/></svg>\n",
+		},
+		{
+			Name:  "Caption",
+			Input: "```echo caption=Topology\n<svg></svg>\n```\n",
+			Want:  "<svg role=\"img\" aria-label=\"Topology\"><title>Topology</title></svg>\n",
+		},
+		{
+			Name:  "NoAlt",
+			Input: "```echo\n<svg/>\n```\n",
+			Want:  "<svg role=\"img\"/>\n",
+		},
+		{
+			Name:  "ExistingAttributes",
+			Input: "```echo alt=x\n<svg role=\"graphics-document\" aria-label=\"y\"></svg>\n```\n",
+			Want:  "<svg role=\"graphics-document\" aria-label=\"y\"><title>x</title></svg>\n",
+		},
+		{
+			Name:  "NotSVG",
+			Input: "```echo alt=x\n<p>text</p>\n```\n",
+			Want:  "<p>text</p>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestRequireAlt(t *testing.T) {
+	echo := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		return src, nil
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{"echo": echo},
+		RequireAlt:   true,
+	}))
+
+	for _, tt := range []struct {
+		Name    string
+		Input   string
+		WantErr bool
+	}{
+		{Name: "SVGWithAlt", Input: "```echo alt=x\n<svg/>\n```\n"},
+		{Name: "SVGWithoutAlt", Input: "```echo\n<svg/>\n```\n", WantErr: true},
+		{Name: "PNGWithoutAlt", Input: "```echo\n\x89PNG\r\n\x1a\n```\n", WantErr: true},
+		{Name: "Text", Input: "```echo\n<p>text</p>\n```\n"},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := gmark.Convert([]byte(tt.Input), &bytes.Buffer{})
+			if got := errors.Is(err, pipefence.ErrNoAltText); got != tt.WantErr {
+				t.Errorf("gmark.Convert(%q) = %v, want ErrNoAltText: %v", tt.Input, err, tt.WantErr)
+			}
+		})
+	}
+}
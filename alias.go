@@ -0,0 +1,86 @@
+package pipefence
+
+import "strings"
+
+// RegisterAlias makes blocks in the language alias use the pipe
+// function of lang.  It is safe to call RegisterAlias while
+// conversions are running.
+func (e *Extension) RegisterAlias(alias, lang string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.Aliases == nil {
+		e.Aliases = make(map[string]string)
+	}
+	e.Aliases[alias] = lang
+}
+
+// canonical resolves the aliases in lang, which may be a pipeline
+// such as "graphviz|svgo".  With CaseInsensitive, it also replaces
+// the languages with the spelling under which they are registered.
+func (e *Extension) canonical(lang string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if len(e.Aliases) == 0 && !e.CaseInsensitive {
+		return lang
+	}
+	if l, ok := e.canonicalName(lang); ok {
+		return l
+	}
+	if !strings.Contains(lang, "|") {
+		return lang
+	}
+	stages := strings.Split(lang, "|")
+	for i, stage := range stages {
+		if l, ok := e.canonicalName(strings.TrimSpace(stage)); ok {
+			stages[i] = l
+		}
+	}
+	return strings.Join(stages, "|")
+}
+
+// canonicalName returns the canonical name of a single language, if
+// it differs from lang.  e.mu must be held.
+func (e *Extension) canonicalName(lang string) (string, bool) {
+	if l, ok := e.Aliases[lang]; ok {
+		return l, true
+	}
+	if !e.CaseInsensitive {
+		return "", false
+	}
+	if _, ok := e.PipeFuncsCtx[lang]; ok {
+		return "", false
+	}
+	if _, ok := e.PipeFuncs[lang]; ok {
+		return "", false
+	}
+	if _, ok := e.BatchPipeFuncs[lang]; ok {
+		return "", false
+	}
+
+	// If several registered names match, the smallest one wins, so
+	// that the result does not depend on the map iteration order.
+	var (
+		best  string
+		found bool
+	)
+	match := func(name, canonical string) {
+		if strings.EqualFold(name, lang) && (!found || canonical < best) {
+			best, found = canonical, true
+		}
+	}
+	for name := range e.PipeFuncsCtx {
+		match(name, name)
+	}
+	for name := range e.PipeFuncs {
+		match(name, name)
+	}
+	for name := range e.BatchPipeFuncs {
+		match(name, name)
+	}
+	for alias, name := range e.Aliases {
+		match(alias, name)
+	}
+	return best, found
+}
@@ -0,0 +1,101 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestAliases(t *testing.T) {
+	var langs []string
+	cache := &pipefence.MemoryCache{}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"dot": func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+				langs = append(langs, info.Language)
+				return bytes.ToUpper(a), nil
+			},
+			"rev": func(_ context.Context, a []byte, _ pipefence.Info) ([]byte, error) {
+				return []byte("<" + string(bytes.TrimSpace(a)) + ">"), nil
+			},
+		},
+		Aliases: map[string]string{"graphviz": "dot"},
+		Cache:   cache,
+	}
+	ext.RegisterAlias("gv", "dot")
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	input := "```dot\na\n```\n\n```graphviz\na\n```\n\n```gv|rev\nb\n```\n"
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	if got, want := buf.String(), "A\nA\n<B>"; got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+	// The graphviz block is a cache hit for the identical dot block.
+	if got, want := len(langs), 2; got != want {
+		t.Errorf("dot pipe called %d times, want %d", got, want)
+	}
+	for _, l := range langs {
+		if l != "dot" {
+			t.Errorf("pipe got Info.Language %q, want %q", l, "dot")
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	for _, tt := range []struct {
+		Name            string
+		CaseInsensitive bool
+		Input           string
+		Want            string
+	}{
+		{
+			Name:  "Sensitive",
+			Input: "```DOT\na\n```\n",
+			Want:  "<pre><code class=\"language-DOT\">a\n</code></pre>\n",
+		},
+		{
+			Name:            "Insensitive",
+			CaseInsensitive: true,
+			Input:           "```DOT\na\n```\n",
+			Want:            "dot:A\n",
+		},
+		{
+			Name:            "Alias",
+			CaseInsensitive: true,
+			Input:           "```GraphViz\na\n```\n",
+			Want:            "dot:A\n",
+		},
+		{
+			Name:            "Pipeline",
+			CaseInsensitive: true,
+			Input:           "```Dot|Dot\na\n```\n",
+			Want:            "dot:DOT:A\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+				PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+					"dot": func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+						return append([]byte(info.Language+":"), bytes.ToUpper(a)...), nil
+					},
+				},
+				Aliases:         map[string]string{"graphviz": "dot"},
+				CaseInsensitive: tt.CaseInsensitive,
+			}))
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package pipefence
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// Cache statuses of jobs, as shown in annotations.
+const (
+	cacheNone      = "none"      // No Cache is configured.
+	cacheHit       = "hit"       // The output was taken from the Cache.
+	cacheMiss      = "miss"      // The pipe was invoked.
+	cacheFailure   = "failure"   // The failure was remembered.
+	cacheDuplicate = "duplicate" // The output is that of an identical block.
+)
+
+// cacheStatus returns the cache status of an invocation of run.
+func (e *Extension) cacheStatus(cached bool, err error) string {
+	switch {
+	case cached && err != nil:
+		return cacheFailure
+	case cached:
+		return cacheHit
+	case e.Cache == nil:
+		return cacheNone
+	default:
+		return cacheMiss
+	}
+}
+
+// pipeName returns a description of the pipe resolved for lang: the
+// language itself for registered pipes and pipelines, the pattern
+// for pipes registered with RegisterGlob or RegisterRegexp, the type
+// of Pipers, or "default" for the DefaultPipe.
+func (e *Extension) pipeName(lang string, info []byte) string {
+	if _, ok := e.lookupExact(lang); ok {
+		return lang
+	}
+	if first, _, ok := strings.Cut(lang, "|"); ok {
+		if _, ok := e.lookupRegistered(strings.TrimSpace(first), info); ok {
+			return lang
+		}
+	}
+	if p, ok := e.matchPattern(lang, info); ok {
+		return "pattern " + p.name
+	}
+	return "default"
+}
+
+// annotationEnd is the comment following annotated outputs.
+const annotationEnd = "<!-- /pipefence -->\n"
+
+// writeAnnotation writes the comment preceding the output of j with
+// Annotate.
+func writeAnnotation(w util.BufWriter, j *job) {
+	w.WriteString("<!-- pipefence language=")
+	w.WriteString(commentQuote(j.info.Language))
+	w.WriteString(" pipe=")
+	w.WriteString(commentQuote(j.pipe))
+	w.WriteString(" duration=")
+	w.WriteString(j.duration.Round(10 * time.Microsecond).String())
+	w.WriteString(" cache=")
+	w.WriteString(j.cache)
+	w.WriteString(" hash=")
+	w.WriteString(cacheKey(j.info, j.content)[:16])
+	if j.err != nil {
+		w.WriteString(" error=")
+		w.WriteString(commentQuote(j.err.Error()))
+	}
+	w.WriteString(" -->\n")
+}
+
+// commentQuote quotes s for use in an HTML comment, which must not
+// contain "--".
+func commentQuote(s string) string {
+	return strings.ReplaceAll(strconv.Quote(s), "--", `-\x2d`)
+}
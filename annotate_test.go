@@ -0,0 +1,80 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+// durationRE matches the durations in annotations, which vary.
+var durationRE = regexp.MustCompile(`duration=\S+`)
+
+func TestAnnotate(t *testing.T) {
+	upper := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		return bytes.ToUpper(src), nil
+	}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"upper": upper,
+			"fail": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				return nil, errors.New("bad -- input")
+			},
+		},
+		DefaultPipe: upper,
+		Cache:       &pipefence.MemoryCache{},
+		ErrorMode:   pipefence.RenderErrorInline,
+		Annotate:    true,
+	}
+	if err := ext.RegisterGlob("up-*", upper); err != nil {
+		t.Fatalf("RegisterGlob: %v", err)
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "CacheMiss",
+			Input: "```upper\nabc\n```\n",
+			Want:  "<!-- pipefence language=\"upper\" pipe=\"upper\" duration=X cache=miss hash=0290ce646349579e -->\nABC\n<!-- /pipefence -->\n",
+		},
+		{
+			Name:  "CacheHit",
+			Input: "```upper\nabc\n```\n",
+			Want:  "<!-- pipefence language=\"upper\" pipe=\"upper\" duration=X cache=hit hash=0290ce646349579e -->\nABC\n<!-- /pipefence -->\n",
+		},
+		{
+			Name:  "Pattern",
+			Input: "```up-x\nabc\n```\n",
+			Want:  "<!-- pipefence language=\"up-x\" pipe=\"pattern up-*\" duration=X cache=miss hash=ee4ac50c4f5080f2 -->\nABC\n<!-- /pipefence -->\n",
+		},
+		{
+			Name:  "Default",
+			Input: "```other\nabc\n```\n",
+			Want:  "<!-- pipefence language=\"other\" pipe=\"default\" duration=X cache=miss hash=6342e5f8824236e5 -->\nABC\n<!-- /pipefence -->\n",
+		},
+		{
+			Name:  "Error",
+			Input: "```fail\nabc\n```\n",
+			Want:  "<!-- pipefence language=\"fail\" pipe=\"fail\" duration=X cache=miss hash=def401e7dc3fec17 error=\"fenced block transformer \\\"fail\\\": bad -\\x2d input\" -->\n<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">fenced block transformer &quot;fail&quot;: bad -- input</pre>\n<!-- /pipefence -->\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			got := durationRE.ReplaceAllString(buf.String(), "duration=X")
+			if got != tt.Want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.Want)
+			}
+		})
+	}
+}
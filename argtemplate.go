@@ -0,0 +1,70 @@
+package pipefence
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultArgPattern is the pattern which the values of argument
+// placeholders must match, unless ExecOptions.ArgPatterns has one for
+// the option.  It admits words and numbers, but no spaces, slashes or
+// leading dashes, so that values can not smuggle in flags or file
+// paths.
+var DefaultArgPattern = regexp.MustCompile(`^[A-Za-z0-9_.,:+=][A-Za-z0-9_.,:+=-]*$`)
+
+// argPlaceholder matches the placeholders in arguments, such as
+// "{{.layout}}".
+var argPlaceholder = regexp.MustCompile(`\{\{\s*\.([A-Za-z0-9_-]+)\s*\}\}`)
+
+// expandArgs fills the placeholders in args with the block options.
+func (opts ExecOptions) expandArgs(args []string, options map[string]string) ([]string, error) {
+	var expanded []string
+	for i, arg := range args {
+		if !strings.Contains(arg, "{{") {
+			continue
+		}
+		if strings.Contains(argPlaceholder.ReplaceAllString(arg, ""), "{{") {
+			return nil, fmt.Errorf("invalid placeholder in argument %q", arg)
+		}
+		if expanded == nil {
+			expanded = append([]string(nil), args...)
+		}
+		var err error
+		out := argPlaceholder.ReplaceAllStringFunc(arg, func(p string) string {
+			name := argPlaceholder.FindStringSubmatch(p)[1]
+			v, verr := opts.argValue(name, options)
+			if verr != nil && err == nil {
+				err = verr
+			}
+			return v
+		})
+		if err != nil {
+			return nil, err
+		}
+		expanded[i] = out
+	}
+	if expanded == nil {
+		return args, nil
+	}
+	return expanded, nil
+}
+
+// argValue returns the validated value of the option name.
+func (opts ExecOptions) argValue(name string, options map[string]string) (string, error) {
+	v, ok := options[name]
+	if !ok {
+		v, ok = opts.ArgDefaults[name]
+	}
+	if !ok {
+		return "", fmt.Errorf("missing option %q", name)
+	}
+	pattern := DefaultArgPattern
+	if p, ok := opts.ArgPatterns[name]; ok {
+		pattern = p
+	}
+	if !pattern.MatchString(v) {
+		return "", fmt.Errorf("invalid value %q for option %q", v, name)
+	}
+	return v, nil
+}
@@ -0,0 +1,243 @@
+// Package asciiart provides a pipefence pipe converting ASCII-art
+// diagrams to SVG, in the spirit of goat and svgbob.
+//
+// It is implemented in pure Go and needs no external tools.  It
+// supports the common subset of the ASCII diagram syntaxes:
+//
+//	+-------+      .-----.
+//	| box   |----->| end |
+//	+-------+      '-----'
+//	    |
+//	    v
+//
+// Lines are drawn with "-", "|", "/" and "\", corners and joints
+// with "+", "." and "'", arrowheads with "<", ">", "^" and "v", and
+// dots with "*".  Everything else is rendered as text.  Characters
+// which can be both, like "-" in "built-in", are rendered as text
+// between letters.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"goat": asciiart.Pipe,
+//		},
+//	}
+package asciiart
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark/util"
+)
+
+// The size of a character cell in SVG user units.
+const (
+	cellWidth  = 8
+	cellHeight = 16
+)
+
+// Pipe is a pipefence.PipeFuncCtx converting an ASCII-art diagram
+// to SVG.
+func Pipe(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+	return Render(src), nil
+}
+
+// Render converts an ASCII-art diagram to SVG.
+func Render(src []byte) []byte {
+	g := newGrid(src)
+	var lines, shapes, texts bytes.Buffer
+	for r := 0; r < g.rows; r++ {
+		var run strings.Builder
+		runStart := -1
+		flush := func() {
+			if runStart < 0 {
+				return
+			}
+			text := strings.TrimRight(run.String(), " ")
+			fmt.Fprintf(&texts, `<text x="%d" y="%d">%s</text>`+"\n",
+				runStart*cellWidth, r*cellHeight+12, util.EscapeHTML([]byte(text)))
+			run.Reset()
+			runStart = -1
+		}
+		for c := 0; c < g.cols; c++ {
+			ch := g.at(r, c)
+			if ch == ' ' {
+				// Words separated by single spaces form one text.
+				if runStart >= 0 && g.at(r, c+1) != ' ' && !g.draw(r, c+1, &lines, &shapes, true) {
+					run.WriteRune(' ')
+					continue
+				}
+				flush()
+				continue
+			}
+			if g.draw(r, c, &lines, &shapes, false) {
+				flush()
+				continue
+			}
+			if runStart < 0 {
+				runStart = c
+			}
+			run.WriteRune(ch)
+		}
+		flush()
+	}
+
+	var buf bytes.Buffer
+	w, h := g.cols*cellWidth, g.rows*cellHeight
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" class="asciiart" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="13">`+"\n", w, h, w, h)
+	if lines.Len() > 0 {
+		fmt.Fprintf(&buf, `<path d="%s" stroke="currentColor" stroke-width="1.5" fill="none" stroke-linecap="round"/>`+"\n", strings.TrimSpace(lines.String()))
+	}
+	if shapes.Len() > 0 || texts.Len() > 0 {
+		buf.WriteString(`<g fill="currentColor">` + "\n")
+		buf.Write(shapes.Bytes())
+		buf.Write(texts.Bytes())
+		buf.WriteString("</g>\n")
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// grid is the character grid of a diagram.
+type grid struct {
+	cells      [][]rune
+	rows, cols int
+}
+
+func newGrid(src []byte) *grid {
+	text := strings.ReplaceAll(string(src), "\t", "    ")
+	text = strings.TrimRight(text, "\n")
+	g := &grid{}
+	for _, line := range strings.Split(text, "\n") {
+		row := []rune(strings.TrimRight(line, " \r"))
+		g.cells = append(g.cells, row)
+		if len(row) > g.cols {
+			g.cols = len(row)
+		}
+	}
+	g.rows = len(g.cells)
+	return g
+}
+
+// at returns the character at row r and column c, or a space if the
+// position is outside of the diagram.
+func (g *grid) at(r, c int) rune {
+	if r < 0 || r >= g.rows || c < 0 || c >= len(g.cells[r]) {
+		return ' '
+	}
+	return g.cells[r][c]
+}
+
+func isWord(ch rune) bool {
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
+
+// betweenWords reports whether the cell is surrounded by letters or
+// digits on the left and right.
+func (g *grid) betweenWords(r, c int) bool {
+	return isWord(g.at(r, c-1)) && isWord(g.at(r, c+1))
+}
+
+// draw writes the drawing for the cell at r, c, and reports whether
+// it is a drawing rather than text.  If dryRun is set, nothing is
+// written.
+func (g *grid) draw(r, c int, lines, shapes *bytes.Buffer, dryRun bool) bool {
+	if dryRun {
+		lines, shapes = &bytes.Buffer{}, &bytes.Buffer{}
+	}
+	x0, y0 := c*cellWidth, r*cellHeight
+	x1, y1 := x0+cellWidth, y0+cellHeight
+	cx, cy := x0+cellWidth/2, y0+cellHeight/2
+
+	line := func(ax, ay, bx, by int) {
+		fmt.Fprintf(lines, "M%d %dL%d %d ", ax, ay, bx, by)
+	}
+	triangle := func(ax, ay, bx, by, tx, ty int) {
+		fmt.Fprintf(shapes, `<polygon points="%d,%d %d,%d %d,%d"/>`+"\n", ax, ay, tx, ty, bx, by)
+	}
+
+	horizontal := func(ch rune) bool { return strings.ContainsRune("-+.'*", ch) }
+	vertical := func(ch rune) bool { return strings.ContainsRune("|+.'*", ch) }
+
+	switch ch := g.at(r, c); ch {
+	case '-':
+		if g.betweenWords(r, c) {
+			return false
+		}
+		line(x0, cy, x1, cy)
+	case '|':
+		if g.betweenWords(r, c) {
+			return false
+		}
+		line(cx, y0, cx, y1)
+	case '/':
+		if g.betweenWords(r, c) {
+			return false
+		}
+		line(x0, y1, x1, y0)
+	case '\\':
+		if g.betweenWords(r, c) {
+			return false
+		}
+		line(x0, y0, x1, y1)
+	case '+', '.', '\'':
+		left := strings.ContainsRune("-+.'<", g.at(r, c-1))
+		right := strings.ContainsRune("-+.'>", g.at(r, c+1))
+		up := ch != '.' && strings.ContainsRune("|+.^", g.at(r-1, c))
+		down := ch != '\'' && strings.ContainsRune("|+'v", g.at(r+1, c))
+		if ch != '+' && !up && !down {
+			// A full stop or an apostrophe.
+			return false
+		}
+		if !left && !right && !up && !down {
+			return false
+		}
+		if left {
+			line(x0, cy, cx, cy)
+		}
+		if right {
+			line(cx, cy, x1, cy)
+		}
+		if up {
+			line(cx, y0, cx, cy)
+		}
+		if down {
+			line(cx, cy, cx, y1)
+		}
+	case '>':
+		if !horizontal(g.at(r, c-1)) {
+			return false
+		}
+		triangle(x0, cy-4, x0, cy+4, x1, cy)
+	case '<':
+		if !horizontal(g.at(r, c+1)) {
+			return false
+		}
+		triangle(x1, cy-4, x1, cy+4, x0, cy)
+	case '^':
+		if !vertical(g.at(r+1, c)) {
+			return false
+		}
+		triangle(cx-4, y1, cx+4, y1, cx, y0)
+	case 'v':
+		if !vertical(g.at(r-1, c)) || g.betweenWords(r, c) {
+			return false
+		}
+		triangle(cx-4, y0, cx+4, y0, cx, y1)
+	case '*':
+		if !horizontal(g.at(r, c-1)) && !horizontal(g.at(r, c+1)) &&
+			!vertical(g.at(r-1, c)) && !vertical(g.at(r+1, c)) {
+			return false
+		}
+		fmt.Fprintf(shapes, `<circle cx="%d" cy="%d" r="3"/>`+"\n", cx, cy)
+	default:
+		return false
+	}
+	return true
+}
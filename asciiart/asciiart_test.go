@@ -0,0 +1,69 @@
+package asciiart_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gnoack/goldmark-pipefence/asciiart"
+)
+
+func TestRenderBox(t *testing.T) {
+	got := string(asciiart.Render([]byte("+-+\n|a|\n+-+\n")))
+	want := `<svg xmlns="http://www.w3.org/2000/svg" class="asciiart" width="24" height="48" viewBox="0 0 24 48" font-family="monospace" font-size="13">
+<path d="M4 8L8 8 M4 8L4 16 M8 8L16 8 M16 8L20 8 M20 8L20 16 M4 16L4 32 M20 16L20 32 M4 40L8 40 M4 32L4 40 M8 40L16 40 M16 40L20 40 M20 32L20 40" stroke="currentColor" stroke-width="1.5" fill="none" stroke-linecap="round"/>
+<g fill="currentColor">
+<text x="8" y="28">a</text>
+</g>
+</svg>
+`
+	if got != want {
+		t.Errorf("Render(box) = %s, want %s", got, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  []string
+	}{
+		{
+			Name:  "Text",
+			Input: "don't stop, and/or built-in.",
+			Want:  []string{`<text x="0" y="12">don't stop, and/or built-in.</text>`},
+		},
+		{
+			Name:  "TextAroundLines",
+			Input: "a --> b",
+			Want: []string{
+				`<text x="0" y="12">a</text>`,
+				`<polygon points="32,4 40,8 32,12"/>`,
+				`<text x="48" y="12">b</text>`,
+			},
+		},
+		{
+			Name:  "ArrowDown",
+			Input: "|\nv",
+			Want:  []string{`<polygon points="0,16 4,32 8,16"/>`},
+		},
+		{
+			Name:  "Dot",
+			Input: "*--",
+			Want:  []string{`<circle cx="4" cy="8" r="3"/>`},
+		},
+		{
+			Name:  "Escaping",
+			Input: "<b>&",
+			Want:  []string{`<text x="0" y="12">&lt;b&gt;&amp;</text>`},
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			got := string(asciiart.Render([]byte(tt.Input)))
+			for _, w := range tt.Want {
+				if !strings.Contains(got, w) {
+					t.Errorf("Render(%q) = %s, want it to contain %s", tt.Input, got, w)
+				}
+			}
+		})
+	}
+}
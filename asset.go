@@ -0,0 +1,147 @@
+package pipefence
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// AssetWriter stores pipe outputs as separate files, so that they
+// can be referenced from the HTML with <img> tags rather than being
+// inlined.
+type AssetWriter interface {
+	// WriteAsset stores data under the given file name and returns
+	// the URL under which it can be referenced.  The name is derived
+	// from a hash of the content, so writing the same name twice
+	// may be skipped.
+	WriteAsset(name string, data []byte) (url string, err error)
+}
+
+// DirAssetWriter is an AssetWriter storing assets in a directory.
+type DirAssetWriter struct {
+	// Dir is the directory to write assets to.  It is created if
+	// needed.
+	Dir string
+
+	// URLPrefix is prepended to the file names to form the URLs,
+	// e.g. "/assets/".
+	URLPrefix string
+}
+
+// WriteAsset implements AssetWriter.
+func (d *DirAssetWriter) WriteAsset(name string, data []byte) (string, error) {
+	url := d.URLPrefix + path.Base(name)
+	p := filepath.Join(d.Dir, filepath.Base(name))
+	if _, err := os.Stat(p); err == nil {
+		return url, nil
+	}
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// assetName returns a content-hash based file name for data.
+func assetName(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]) + assetExt(data)
+}
+
+// assetExt guesses the file name extension for data.
+func assetExt(data []byte) string {
+	if isSVG(data) {
+		return ".svg"
+	}
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	return ""
+}
+
+// isSVG reports whether data looks like an SVG document.
+func isSVG(data []byte) bool {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.Contains(head, []byte("<svg"))
+}
+
+// externalize writes output via the AssetWriter and returns an <img>
+// tag referencing it.
+func (e *Extension) externalize(output []byte, info Info) ([]byte, error) {
+	url, err := e.AssetWriter.WriteAsset(assetName(output), output)
+	if err != nil {
+		return nil, err
+	}
+	return imgTag(util.URLEscape([]byte(url), false), info), nil
+}
+
+// outputType returns the MIME type of the output of a pipe for the
+// given language, as declared in OutputTypes or detected.
+func (e *Extension) outputType(lang string, output []byte) string {
+	if t, ok := e.OutputTypes[lang]; ok {
+		return t
+	}
+	if isSVG(output) {
+		return "image/svg+xml"
+	}
+	return http.DetectContentType(output)
+}
+
+// isBinary reports whether outputs of the given MIME type can not be
+// inlined into HTML.
+func isBinary(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/") && mimeType != "image/svg+xml"
+}
+
+// dataURIImage returns an <img> tag with output embedded as a data:
+// URI.
+func dataURIImage(mimeType string, output []byte, info Info) []byte {
+	src := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(output)
+	return imgTag([]byte(src), info)
+}
+
+// imgTag returns an <img> tag for the given source URL, with the alt
+// text taken from the alt or caption option.
+func imgTag(src []byte, info Info) []byte {
+	alt := altText(info)
+
+	var buf bytes.Buffer
+	buf.WriteString(`<img src="`)
+	buf.Write(util.EscapeHTML(src))
+	buf.WriteString(`" alt="`)
+	buf.Write(util.EscapeHTML([]byte(alt)))
+	buf.WriteString("\">\n")
+	return buf.Bytes()
+}
+
+// linkTag wraps the rendering out of an output in a link to href,
+// the URL of the output written as an asset.
+func linkTag(href, out []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<a class="pipefence-asset" href="`)
+	buf.Write(util.EscapeHTML(href))
+	buf.WriteString(`">`)
+	buf.Write(bytes.TrimSuffix(out, []byte("\n")))
+	buf.WriteString("</a>\n")
+	return buf.Bytes()
+}
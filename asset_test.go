@@ -0,0 +1,131 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestAssetWriter(t *testing.T) {
+	dir := t.TempDir()
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"svg": func(a []byte) ([]byte, error) { return a, nil },
+		},
+		AssetWriter:  &pipefence.DirAssetWriter{Dir: dir, URLPrefix: "/assets/"},
+		AssetMinSize: 20,
+	}))
+
+	input := "```svg alt=\"Big <diagram>\"\n<svg>large enough</svg>\n```\n\n```svg\n<svg/>\n```\n"
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	const name = "b5d17d6ac41686767a7a236000e1f531.svg"
+	want := "<img src=\"/assets/" + name + "\" alt=\"Big &lt;diagram&gt;\">\n<svg/>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("reading asset: %v", err)
+	}
+	if got, want := string(data), "<svg>large enough</svg>\n"; got != want {
+		t.Errorf("asset content = %q, want %q", got, want)
+	}
+}
+
+func TestBinaryOutput(t *testing.T) {
+	// The smallest valid GIF image.
+	gif := []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;")
+	pipes := map[string]pipefence.PipeFunc{
+		"gif": func([]byte) ([]byte, error) { return gif, nil },
+		"raw": func([]byte) ([]byte, error) { return []byte{0, 1, 2}, nil },
+	}
+	const gifName = "1f19970f056cd116a5fe3c02422c1ee1.gif"
+
+	for _, tt := range []struct {
+		Name        string
+		OutputTypes map[string]string
+		AssetWriter bool
+		Input       string
+		Want        string
+	}{
+		{
+			Name:  "DataURI",
+			Input: "```gif alt=dot\n```\n",
+			Want:  `<img src="data:image/gif;base64,R0lGODlhAQABAAAAADs=" alt="dot">` + "\n",
+		},
+		{
+			Name:        "DeclaredType",
+			OutputTypes: map[string]string{"raw": "image/x-raw"},
+			Input:       "```raw\n```\n",
+			Want:        `<img src="data:image/x-raw;base64,AAEC" alt="">` + "\n",
+		},
+		{
+			Name:        "AssetWriter",
+			AssetWriter: true,
+			Input:       "```gif\n```\n",
+			Want:        `<img src="/assets/` + gifName + `" alt="">` + "\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			ext := &pipefence.Extension{
+				PipeFuncs:    pipes,
+				OutputTypes:  tt.OutputTypes,
+				AssetMinSize: 1 << 20,
+			}
+			if tt.AssetWriter {
+				ext.AssetWriter = &pipefence.DirAssetWriter{Dir: t.TempDir(), URLPrefix: "/assets/"}
+			}
+			gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestLinkAssets(t *testing.T) {
+	dir := t.TempDir()
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"svg":  func(a []byte) ([]byte, error) { return a, nil },
+			"html": func(a []byte) ([]byte, error) { return a, nil },
+		},
+		AssetWriter:  &pipefence.DirAssetWriter{Dir: dir, URLPrefix: "/assets/"},
+		AssetMinSize: 1 << 20,
+		LinkAssets:   true,
+	}))
+
+	input := "```svg\n<svg/>\n```\n\n```html\n<b>not an image</b>\n```\n"
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	const name = "cd1fafe3cc7f06f55ead3f0dce39300a.svg"
+	want := "<a class=\"pipefence-asset\" href=\"/assets/" + name + "\"><svg/></a>\n<b>not an image</b>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("reading asset: %v", err)
+	}
+	if got, want := string(data), "<svg/>\n"; got != want {
+		t.Errorf("asset content = %q, want %q", got, want)
+	}
+}
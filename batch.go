@@ -0,0 +1,233 @@
+package pipefence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// BatchPipeFunc transforms the contents of several fenced code
+// blocks at once, returning one output per input.  It suits tools
+// which are slow to start, such as JVM-based ones, which can then
+// transform all blocks of a document in one invocation.
+//
+// Batch pipe functions do not receive the options of the blocks.
+// If the outputs are not all valid, the function should fail as a
+// whole.
+type BatchPipeFunc func([][]byte) ([][]byte, error)
+
+// batchKey is the context key for the outputs of the batch pipe
+// functions of a conversion.
+type batchKey struct{}
+
+// batchResult is the result of a batch pipe function for a single
+// block.
+type batchResult struct {
+	out []byte
+	err error
+}
+
+// lookupBatch returns the batch pipe function registered for lang,
+// unless a regular pipe function takes precedence.
+func (e *Extension) lookupBatch(lang string) (BatchPipeFunc, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, ok := e.PipeFuncsCtx[lang]; ok {
+		return nil, false
+	}
+	if _, ok := e.PipeFuncs[lang]; ok {
+		return nil, false
+	}
+	b, ok := e.BatchPipeFuncs[lang]
+	return b, ok
+}
+
+// batchPipe returns a pipe function for a language with a batch pipe
+// function b.  It returns the output computed by runBatches if there
+// is one, and otherwise invokes b for the single block, e.g. as a
+// pipeline stage.
+func batchPipe(b BatchPipeFunc) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+		if results, ok := ctx.Value(batchKey{}).(map[string]batchResult); ok {
+			if r, ok := results[info.Language+"\x00"+string(src)]; ok {
+				return r.out, r.err
+			}
+		}
+		outs, err := b([][]byte{src})
+		if err != nil {
+			return nil, err
+		}
+		if len(outs) != 1 {
+			return nil, fmt.Errorf("batch returned %d outputs for 1 block", len(outs))
+		}
+		return outs[0], nil
+	}
+}
+
+// cacheProbesKey is the context key for the Cache lookups made by
+// runBatches, so that run does not look up the same keys again.
+type cacheProbesKey struct{}
+
+// cacheProbe is the result of a Cache lookup.
+type cacheProbe struct {
+	value []byte
+	ok    bool
+}
+
+// errBatchTimeout reports that a batch pipe function did not finish
+// within the timeout of its language.
+var errBatchTimeout = errors.New("batch timed out")
+
+// runBatches invokes the batch pipe functions once per language for
+// the jobs which need them, and returns a context from which their
+// pipe functions take the outputs.  Jobs whose output is in the
+// Cache, whose failure is remembered, or whose content exceeds the
+// SizeLimit, are left out.
+func (e *Extension) runBatches(ctx context.Context, jobs []*job) context.Context {
+	if ctx.Err() != nil {
+		return ctx
+	}
+	type batch struct {
+		fn   BatchPipeFunc
+		jobs []*job
+		srcs [][]byte
+	}
+	var (
+		batches = make(map[string]*batch)
+		langs   []string
+		seen    = make(map[string]bool)
+		probes  = make(map[string]cacheProbe)
+	)
+	for _, j := range jobs {
+		if j.err != nil {
+			continue
+		}
+		lang := j.info.Language
+		fn, ok := e.lookupBatch(lang)
+		if !ok {
+			continue
+		}
+		if max := e.sizeLimit(lang).Input; max > 0 && len(j.content) > max {
+			continue
+		}
+		key := lang + "\x00" + string(j.content)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ckey := e.cacheKey(j.info, j.content, j.inline)
+		if e.Cache != nil {
+			v, ok := e.Cache.Get(ckey)
+			probes[ckey] = cacheProbe{value: v, ok: ok}
+			if ok {
+				continue
+			}
+		}
+		if e.FailureTTL > 0 {
+			if _, ok := e.failures.get(ckey, time.Now()); ok {
+				continue
+			}
+		}
+		b, ok := batches[lang]
+		if !ok {
+			b = &batch{fn: fn}
+			batches[lang] = b
+			langs = append(langs, lang)
+		}
+		b.jobs = append(b.jobs, j)
+		b.srcs = append(b.srcs, j.content)
+	}
+	if len(probes) > 0 {
+		ctx = context.WithValue(ctx, cacheProbesKey{}, probes)
+	}
+	if len(batches) == 0 {
+		return ctx
+	}
+
+	results := make(map[string]batchResult)
+	for _, lang := range langs {
+		b := batches[lang]
+		outs, err := e.invokeBatch(ctx, lang, b.fn, b.srcs)
+		if err == nil && len(outs) != len(b.srcs) {
+			err = fmt.Errorf("batch returned %d outputs for %d blocks", len(outs), len(b.srcs))
+		}
+		for i, j := range b.jobs {
+			r := batchResult{err: err}
+			if err == nil {
+				r.out = outs[i]
+			}
+			if perr, ok := err.(*PanicError); ok {
+				r.err = &PanicError{Language: lang, Line: j.line, Value: perr.Value, Stack: perr.Stack}
+			}
+			if err == errBatchTimeout {
+				r.err = &TimeoutError{Language: lang, Line: j.line, Timeout: e.timeout(lang)}
+			}
+			results[lang+"\x00"+string(j.content)] = r
+		}
+		e.logger().Debug("pipefence: batch", "language", lang, "blocks", len(b.srcs), "error", err)
+	}
+	return context.WithValue(ctx, batchKey{}, results)
+}
+
+// invokeBatch invokes fn like a single pipe invocation for lang: once
+// the Limits permit, and within the timeout of lang and the lifetime
+// of ctx.  If the timeout passes, it returns errBatchTimeout.  As with
+// pipe functions ignoring their context, fn keeps running in the
+// background until it returns, but its result is discarded.
+func (e *Extension) invokeBatch(ctx context.Context, lang string, fn BatchPipeFunc, srcs [][]byte) ([][]byte, error) {
+	if lim := e.limiter(lang); lim != nil {
+		release, err := lim.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	type result struct {
+		outs [][]byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		outs, err := callBatch(fn, srcs)
+		done <- result{outs, err}
+	}()
+
+	var timeout <-chan time.Time
+	if d := e.timeout(lang); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case r := <-done:
+		return r.outs, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, errBatchTimeout
+	}
+}
+
+// batched reports whether the output for the block in lang with the
+// content src was computed by runBatches.
+func batched(ctx context.Context, lang string, src []byte) bool {
+	results, _ := ctx.Value(batchKey{}).(map[string]batchResult)
+	_, ok := results[lang+"\x00"+string(src)]
+	return ok
+}
+
+// callBatch invokes fn, but returns a *PanicError instead of
+// crashing if fn panics.
+func callBatch(fn BatchPipeFunc, srcs [][]byte) (outs [][]byte, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			outs = nil
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn(srcs)
+}
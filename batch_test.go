@@ -0,0 +1,161 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestBatchPipeFuncs(t *testing.T) {
+	var batches [][]string
+	upper := func(srcs [][]byte) ([][]byte, error) {
+		var batch []string
+		outs := make([][]byte, len(srcs))
+		for i, src := range srcs {
+			batch = append(batch, string(src))
+			outs[i] = bytes.ToUpper(src)
+		}
+		batches = append(batches, batch)
+		return outs, nil
+	}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"rev": func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				out := bytes.Clone(src)
+				for i, k := 0, len(out)-1; i < k; i, k = i+1, k-1 {
+					out[i], out[k] = out[k], out[i]
+				}
+				return out, nil
+			},
+		},
+		BatchPipeFuncs: map[string]pipefence.BatchPipeFunc{"upper": upper},
+		Workers:        4,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	input := "```upper\na\n```\n\n```rev\nxy\n```\n\n```upper\nb\n```\n\n```upper\na\n```\n"
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got, want := buf.String(), "A\n\nyxB\nA\n"; got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+	if len(batches) != 1 || strings.Join(batches[0], "") != "a\nb\n" {
+		t.Errorf("batches = %q, want one batch of the distinct blocks", batches)
+	}
+
+	// In pipelines, the stages get single blocks.
+	batches = nil
+	buf.Reset()
+	if err := gmark.Convert([]byte("```rev|upper\nab\n```\n"), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got, want := buf.String(), "\nBA"; got != want {
+		t.Errorf("pipeline output = %q, want %q", got, want)
+	}
+	if len(batches) != 1 {
+		t.Errorf("batches = %q, want 1", batches)
+	}
+}
+
+func TestBatchPipeFuncsCache(t *testing.T) {
+	calls := 0
+	cache := &pipefence.MemoryCache{}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		BatchPipeFuncs: map[string]pipefence.BatchPipeFunc{
+			"upper": func(srcs [][]byte) ([][]byte, error) {
+				calls++
+				outs := make([][]byte, len(srcs))
+				for i, src := range srcs {
+					outs[i] = bytes.ToUpper(src)
+				}
+				return outs, nil
+			},
+		},
+		Cache: cache,
+	}))
+
+	// Each block is looked up in the Cache once per conversion.
+	for _, want := range []pipefence.CacheStats{
+		{Misses: 1, Entries: 1, Bytes: 2},
+		{Hits: 1, Misses: 1, Entries: 1, Bytes: 2},
+	} {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte("```upper\na\n```\n"), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if got := buf.String(); got != "A\n" {
+			t.Errorf("gmark.Convert() = %q, want %q", got, "A\n")
+		}
+		if got := cache.Stats(); got != want {
+			t.Errorf("cache.Stats() = %+v, want %+v", got, want)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("batch pipe function called %d times, want 1", calls)
+	}
+}
+
+func TestBatchPipeFuncsErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		batch pipefence.BatchPipeFunc
+		want  string
+	}{
+		{
+			name: "error",
+			batch: func([][]byte) ([][]byte, error) {
+				return nil, errors.New("boom")
+			},
+			want: "boom",
+		},
+		{
+			name: "count",
+			batch: func([][]byte) ([][]byte, error) {
+				return [][]byte{[]byte("x")}, nil
+			},
+			want: "batch returned 1 outputs for 2 blocks",
+		},
+		{
+			name: "panic",
+			batch: func([][]byte) ([][]byte, error) {
+				panic("oops")
+			},
+			want: "panic: oops",
+		},
+		{
+			name: "timeout",
+			batch: func(srcs [][]byte) ([][]byte, error) {
+				time.Sleep(time.Second)
+				return srcs, nil
+			},
+			want: "timed out after 50ms",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []int
+			ext := &pipefence.Extension{
+				BatchPipeFuncs: map[string]pipefence.BatchPipeFunc{"b": tt.batch},
+				ErrorMode:      pipefence.RenderErrorInline,
+				Timeout:        50 * time.Millisecond,
+			}
+			issues := ext.Check([]byte("```b\nx\n```\n\n```b\ny\n```\n"))
+			for _, issue := range issues {
+				lines = append(lines, issue.Line)
+				if !strings.Contains(issue.Err.Error(), tt.want) {
+					t.Errorf("line %d: error %q, want %q", issue.Line, issue.Err, tt.want)
+				}
+			}
+			if len(lines) != 2 || lines[0] != 1 || lines[1] != 5 {
+				t.Errorf("issues at lines %v, want [1 5]", lines)
+			}
+		})
+	}
+}
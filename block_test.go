@@ -0,0 +1,156 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+func TestBlock(t *testing.T) {
+	ext := &pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"upper": func(a []byte) ([]byte, error) { return bytes.ToUpper(a), nil },
+		},
+		Figure: &pipefence.FigureOptions{Numbered: true},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	src := []byte("# Title\n\n```upper caption=Shout\nhello\n```\n\n```go\nfoo\n```\n")
+	doc := gmark.Parser().Parse(text.NewReader(src))
+
+	var blocks []*pipefence.Block
+	ast.Walk(doc, func(n ast.Node, enter bool) (ast.WalkStatus, error) {
+		if b, ok := n.(*pipefence.Block); ok && enter {
+			if n.Kind() != pipefence.KindBlock {
+				t.Errorf("block kind = %v, want %v", n.Kind(), pipefence.KindBlock)
+			}
+			blocks = append(blocks, b)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if len(blocks) != 1 {
+		t.Fatalf("found %d blocks, want 1", len(blocks))
+	}
+	b := blocks[0]
+	if got := b.PipeInfo().Options["caption"]; got != "Shout" {
+		t.Errorf("caption = %q, want %q", got, "Shout")
+	}
+	if b.Line() != 3 || string(b.Output()) != "HELLO\n" || b.Err() != nil || b.Figure() != 1 {
+		t.Errorf("block: line %d, output %q, err %v, figure %d; want 3, %q, nil, 1", b.Line(), b.Output(), b.Err(), b.Figure(), "HELLO\n")
+	}
+}
+
+func TestBlockRawContent(t *testing.T) {
+	ext := &pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"cat": func(a []byte) ([]byte, error) { return a, nil },
+		},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Contiguous",
+			Input: "```cat\na\n  b\n```\n",
+			Want:  "a\n  b\n",
+		},
+		{
+			Name:  "Quoted",
+			Input: "> ```cat\n> a\n> b\n> ```\n",
+			Want:  "a\nb\n",
+		},
+		{
+			Name:  "Tabs",
+			Input: "- ```cat\n\ta\n  ```\n",
+			Want:  "  a\n",
+		},
+		{
+			Name:  "Empty",
+			Input: "```cat\n```\n",
+			Want:  "",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			src := []byte(tt.Input)
+			doc := gmark.Parser().Parse(text.NewReader(src))
+			var got []byte
+			ast.Walk(doc, func(n ast.Node, enter bool) (ast.WalkStatus, error) {
+				if b, ok := n.(*pipefence.Block); ok && enter {
+					got = b.RawContent(src)
+				}
+				return ast.WalkContinue, nil
+			})
+			if string(got) != tt.Want {
+				t.Errorf("RawContent() = %q, want %q", got, tt.Want)
+			}
+			_ = append(got, "appended"...)
+			if string(src) != tt.Input {
+				t.Errorf("appending to RawContent() modified the source: %q", src)
+			}
+		})
+	}
+}
+
+func BenchmarkConvert(b *testing.B) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"cat": func(a []byte) ([]byte, error) { return a, nil },
+		},
+	}))
+	var sb strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&sb, "Block %d:\n\n```cat\n<p>line one</p>\n<p>line two</p>\n<p>line three</p>\n```\n\n", i)
+	}
+	src := []byte(sb.String())
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := gmark.Convert(src, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRawContent(b *testing.B) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"cat": func(a []byte) ([]byte, error) { return a, nil },
+		},
+	}))
+	for _, bb := range []struct {
+		Name  string
+		Input string
+	}{
+		{Name: "Contiguous", Input: "```cat\n" + strings.Repeat("line\n", 100) + "```\n"},
+		{Name: "Quoted", Input: "> ```cat\n" + strings.Repeat("> line\n", 100) + "> ```\n"},
+	} {
+		b.Run(bb.Name, func(b *testing.B) {
+			src := []byte(bb.Input)
+			doc := gmark.Parser().Parse(text.NewReader(src))
+			var block *pipefence.Block
+			ast.Walk(doc, func(n ast.Node, enter bool) (ast.WalkStatus, error) {
+				if fb, ok := n.(*pipefence.Block); ok && enter {
+					block = fb
+				}
+				return ast.WalkContinue, nil
+			})
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				block.RawContent(src)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package pipefence
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cache stores the outputs of pipe functions, so that identical
+// fenced code blocks do not need to be transformed again.
+//
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the cached output for key, if present.
+	Get(key string) ([]byte, bool)
+
+	// Set stores the output for key.
+	Set(key string, value []byte)
+}
+
+// StatsCache is a Cache which reports usage statistics, such as
+// MemoryCache and the Cache of the rediscache module.  Remote
+// caches shared by CI machines can implement it, so that their hit
+// rates can be monitored.
+type StatsCache interface {
+	Cache
+
+	// Stats returns the usage statistics of the cache.
+	Stats() CacheStats
+}
+
+// cacheKey returns the cache key for a block with the given info
+// and content.  It covers the language, the options, the attributes
+// and a hash of the content.
+func cacheKey(info Info, src []byte) string {
+	h := sha256.New()
+	h.Write([]byte(info.Language))
+	h.Write([]byte{0})
+	hashMap(h, info.Options)
+	hashMap(h, info.Attributes)
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheKey returns the key of a block in the Cache.  It starts with
+// the CacheKeyPrefix of the language, and covers the CacheVersions
+// of the stages of the language, or the versions of their Pipers,
+// and the Path if a stage is PathDependent.  Code spans have keys of
+// their own, as they have pipe functions of their own.
+func (e *Extension) cacheKey(info Info, src []byte, inline bool) string {
+	key := cacheKey(info, src)
+	if inline {
+		key = "inline-" + key
+	}
+	var extra []string
+	pathDependent := false
+	for _, stage := range strings.Split(info.Language, "|") {
+		stage = strings.TrimSpace(stage)
+		if v, ok := e.CacheVersions[stage]; ok {
+			extra = append(extra, v)
+		} else if v, ok := e.piperVersion(stage, info); ok {
+			extra = append(extra, v)
+		}
+		pathDependent = pathDependent || e.PathDependent[stage]
+	}
+	if pathDependent {
+		extra = append(extra, "path:"+info.Path)
+	}
+	if extra != nil {
+		h := sha256.New()
+		h.Write([]byte(key))
+		for _, v := range extra {
+			h.Write([]byte{0})
+			h.Write([]byte(v))
+		}
+		key = hex.EncodeToString(h.Sum(nil))
+	}
+	return CacheKeyPrefix(info.Language) + key
+}
+
+// cacheMagic starts the Cache entries which hold more than the
+// output of a pipe.
+const cacheMagic = "\x00pipefence\x00"
+
+// cacheMeta is what a pipe declared besides its output, as stored in
+// the Cache.
+type cacheMeta struct {
+	Assets       []string `json:",omitempty"`
+	Dependencies []string `json:",omitempty"`
+	Warnings     []string `json:",omitempty"`
+}
+
+// encodeCacheEntry returns the Cache entry for res.  Outputs of pipes
+// which declared nothing are stored as they are; otherwise, the entry
+// starts with cacheMagic and a JSON-encoded cacheMeta line.
+func encodeCacheEntry(res pipeResult) []byte {
+	meta := cacheMeta{Assets: res.assets, Dependencies: res.deps, Warnings: res.warnings}
+	if len(meta.Assets) == 0 && len(meta.Dependencies) == 0 && len(meta.Warnings) == 0 {
+		return res.out
+	}
+	header, err := json.Marshal(meta)
+	if err != nil {
+		panic(err) // Can not happen for string slices.
+	}
+	var buf bytes.Buffer
+	buf.WriteString(cacheMagic)
+	buf.Write(header)
+	buf.WriteByte('\n')
+	buf.Write(res.out)
+	return buf.Bytes()
+}
+
+// decodeCacheEntry returns the pipeResult stored in the Cache entry
+// v by encodeCacheEntry.
+func decodeCacheEntry(v []byte) pipeResult {
+	rest, ok := bytes.CutPrefix(v, []byte(cacheMagic))
+	if !ok {
+		return pipeResult{out: v}
+	}
+	header, out, ok := bytes.Cut(rest, []byte("\n"))
+	var meta cacheMeta
+	if !ok || json.Unmarshal(header, &meta) != nil {
+		return pipeResult{out: v}
+	}
+	return pipeResult{out: out, assets: meta.Assets, deps: meta.Dependencies, warnings: meta.Warnings}
+}
+
+// CacheKeyPrefix returns the prefix of the keys of the outputs of
+// blocks in lang in the Cache, so that caches can purge the outputs
+// of a language.  For pipelines such as "dot|svgo", lang is the whole
+// pipeline.
+func CacheKeyPrefix(lang string) string {
+	sum := sha256.Sum256([]byte(lang))
+	return hex.EncodeToString(sum[:4]) + "-"
+}
+
+// hashMap writes the entries of m to h in a canonical order.
+func hashMap(h hash.Hash, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{'='})
+		h.Write([]byte(m[k]))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+}
+
+// CacheStats are usage statistics of a StatsCache.
+type CacheStats struct {
+	Hits   int
+	Misses int
+
+	// Entries and Bytes are the number and total size of the
+	// entries, or zero if unknown.
+	Entries int
+	Bytes   int64
+}
+
+// HitRate returns the fraction of lookups which were hits, or zero
+// if there were none.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// MemoryCache is an in-memory Cache.
+//
+// The zero value is an empty cache ready to use.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	stats   CacheStats
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.entries[key]
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	return v, ok
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string][]byte)
+	}
+	c.entries[key] = value
+}
+
+// Stats returns the usage statistics of the cache.
+func (c *MemoryCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	for _, v := range c.entries {
+		stats.Bytes += int64(len(v))
+	}
+	return stats
+}
+
+// Purge removes the outputs of blocks in lang and returns their
+// number.
+func (c *MemoryCache) Purge(lang string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := CacheKeyPrefix(lang)
+	n := 0
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+			n++
+		}
+	}
+	return n
+}
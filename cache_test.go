@@ -0,0 +1,184 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestCache(t *testing.T) {
+	calls := 0
+	cache := &pipefence.MemoryCache{}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				calls++
+				return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+			},
+		},
+		Cache: cache,
+	}))
+
+	for _, input := range []string{
+		"```banana\nfoo\n```\n",
+		"```banana\nfoo\n```\n",
+		"```banana width=1\nfoo\n```\n",
+		"```banana\nboo\n```\n",
+		"```banana\nfoo\n```\n",
+	} {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(input), &buf); err != nil {
+			t.Fatalf("gmark.Convert(%q): %v", input, err)
+		}
+	}
+
+	if calls != 3 {
+		t.Errorf("PipeFunc called %d times, want 3", calls)
+	}
+	want := pipefence.CacheStats{Hits: 2, Misses: 3, Entries: 3, Bytes: 12}
+	if got := cache.Stats(); got != want {
+		t.Errorf("cache.Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCacheVersions(t *testing.T) {
+	calls := 0
+	ext := &pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				calls++
+				return a, nil
+			},
+		},
+		Cache: &pipefence.MemoryCache{},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		versions map[string]string
+		calls    int
+	}{
+		{versions: nil, calls: 1},
+		{versions: map[string]string{"other": "v1"}, calls: 1},
+		{versions: map[string]string{"banana": "v1"}, calls: 2},
+		{versions: map[string]string{"banana": "v1"}, calls: 2},
+		{versions: map[string]string{"banana": "v2"}, calls: 3},
+	} {
+		ext.CacheVersions = tt.versions
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte("```banana\nfoo\n```\n"), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if calls != tt.calls {
+			t.Errorf("with versions %v: PipeFunc called %d times, want %d", tt.versions, calls, tt.calls)
+		}
+	}
+}
+
+func TestCachePathDependent(t *testing.T) {
+	calls := map[string]int{}
+	pathPipe := func(_ context.Context, _ []byte, info pipefence.Info) ([]byte, error) {
+		calls[info.Language]++
+		return []byte(info.Path), nil
+	}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"inc":   pathPipe,
+			"plain": pathPipe,
+		},
+		PathDependent: map[string]bool{"inc": true},
+		Cache:         &pipefence.MemoryCache{},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, path := range []string{"a/doc.md", "b/doc.md", "a/doc.md"} {
+		pc := parser.NewContext()
+		pipefence.WithPath(pc, path)
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte("```inc\nx\n```\n"), &buf, parser.WithContext(pc)); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if got := buf.String(); got != path {
+			t.Errorf("gmark.Convert() in %s = %q, want %q", path, got, path)
+		}
+		if err := gmark.Convert([]byte("```plain\nx\n```\n"), &buf, parser.WithContext(pc)); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+	}
+	if want := map[string]int{"inc": 2, "plain": 1}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("pipe calls = %v, want %v", calls, want)
+	}
+}
+
+func TestToolFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "tool")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fp1, err := pipefence.ToolFingerprint(tool, true)
+	if err != nil {
+		t.Fatalf("ToolFingerprint: %v", err)
+	}
+	if fp, _ := pipefence.ToolFingerprint(tool, true); fp != fp1 {
+		t.Errorf("ToolFingerprint changed without changes to the tool: %q, %q", fp1, fp)
+	}
+
+	// Upgrades change the fingerprint, even with the same size and
+	// modification time.
+	mtime := time.Now().Add(-time.Hour)
+	os.Chtimes(tool, mtime, mtime)
+	fp1, _ = pipefence.ToolFingerprint(tool, true)
+	if err := os.WriteFile(tool, []byte("#!/bin/ss\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(tool, mtime, mtime)
+	if fp, _ := pipefence.ToolFingerprint(tool, true); fp == fp1 {
+		t.Errorf("ToolFingerprint(hashContent) unchanged after upgrade")
+	}
+	os.Chtimes(tool, time.Now(), time.Now())
+	fp1, _ = pipefence.ToolFingerprint(tool, false)
+	os.Chtimes(tool, mtime, mtime)
+	if fp, _ := pipefence.ToolFingerprint(tool, false); fp == fp1 {
+		t.Errorf("ToolFingerprint unchanged after changing the modification time")
+	}
+
+	if _, err := pipefence.ToolFingerprint("pipefence-no-such-tool", false); err == nil {
+		t.Errorf("ToolFingerprint(missing tool) succeeded, want error")
+	}
+}
+
+func TestMemoryCachePurge(t *testing.T) {
+	cache := &pipefence.MemoryCache{}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"dot":  func(src []byte) ([]byte, error) { return src, nil },
+			"math": func(src []byte) ([]byte, error) { return src, nil },
+		},
+		Cache: cache,
+	}))
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte("```dot\na\n```\n\n```dot\nb\n```\n\n```math\nc\n```\n"), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if n := cache.Purge("dot"); n != 2 {
+		t.Errorf("Purge(dot) = %d, want 2", n)
+	}
+	if n := cache.Purge("dot"); n != 0 {
+		t.Errorf("second Purge(dot) = %d, want 0", n)
+	}
+	if got := cache.Stats(); got.Entries != 1 {
+		t.Errorf("Stats().Entries after Purge = %d, want 1", got.Entries)
+	}
+	if got := (pipefence.CacheStats{}).HitRate(); got != 0 {
+		t.Errorf("HitRate() without lookups = %v, want 0", got)
+	}
+}
@@ -0,0 +1,70 @@
+package pipefence
+
+import (
+	"fmt"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// BlockIssue is a problem with a fenced code block or code span,
+// as reported by Check and Validate.
+type BlockIssue struct {
+	Line     int    // Line of the opening code fence, starting at 1.
+	Language string // Language of the block.
+	Err      error  // Error of the pipe function or pipeline.
+}
+
+func (i BlockIssue) String() string {
+	return fmt.Sprintf("line %d: %v", i.Line, i.Err)
+}
+
+// validateOnlyKey marks a parser.Context in which the transformer
+// must not run the pipe functions.
+var validateOnlyKey = parser.NewContextKey()
+
+// Check parses source and runs the pipe functions for all its blocks
+// like a conversion would, but returns the failures in document
+// order instead of rendering HTML.  It is meant for validating
+// documentation in CI.
+//
+// Check only knows about the Markdown syntax of goldmark's default
+// parser, without other extensions.
+func (e *Extension) Check(source []byte) []BlockIssue {
+	return e.check(source, parser.NewContext())
+}
+
+// Validate is like Check, but does not run the pipe functions.  It
+// only reports blocks which can not be piped at all, such as
+// pipelines with unknown stages.
+func (e *Extension) Validate(source []byte) []BlockIssue {
+	pc := parser.NewContext()
+	pc.Set(validateOnlyKey, true)
+	return e.check(source, pc)
+}
+
+func (e *Extension) check(source []byte, pc parser.Context) []BlockIssue {
+	p := goldmark.New(goldmark.WithExtensions(e)).Parser()
+	doc := p.Parse(text.NewReader(source), parser.WithContext(pc))
+
+	var issues []BlockIssue
+	ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+		if !enter {
+			return ast.WalkContinue, nil
+		}
+		var j *job
+		switch n := node.(type) {
+		case *Block:
+			j = &n.job
+		case *pfInline:
+			j = &n.job
+		}
+		if j != nil && j.err != nil {
+			issues = append(issues, BlockIssue{Line: j.line, Language: j.info.Language, Err: j.err})
+		}
+		return ast.WalkContinue, nil
+	})
+	return issues
+}
@@ -0,0 +1,71 @@
+package pipefence_test
+
+import (
+	"errors"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+func TestCheck(t *testing.T) {
+	calls := 0
+	ext := &pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"ok": func(a []byte) ([]byte, error) {
+				calls++
+				return a, nil
+			},
+			"broken": func(a []byte) ([]byte, error) {
+				calls++
+				return nil, errors.New("syntax error")
+			},
+		},
+	}
+	input := "```ok\nfoo\n```\n\n```broken\nfoo\n```\n\n```ok|nope\nfoo\n```\n\n```go\nfoo\n```\n"
+
+	type issue struct {
+		Line     int
+		Language string
+	}
+	for _, tt := range []struct {
+		Name      string
+		Check     func([]byte) []pipefence.BlockIssue
+		Want      []issue
+		WantCalls int
+	}{
+		{
+			Name:      "Check",
+			Check:     ext.Check,
+			Want:      []issue{{5, "broken"}, {9, "ok|nope"}},
+			WantCalls: 2,
+		},
+		{
+			Name:      "Validate",
+			Check:     ext.Validate,
+			Want:      []issue{{9, "ok|nope"}},
+			WantCalls: 0,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			calls = 0
+			var got []issue
+			for _, i := range tt.Check([]byte(input)) {
+				if i.Err == nil {
+					t.Errorf("issue at line %d has no error", i.Line)
+				}
+				got = append(got, issue{i.Line, i.Language})
+			}
+			if len(got) != len(tt.Want) {
+				t.Fatalf("got issues %v, want %v", got, tt.Want)
+			}
+			for i := range got {
+				if got[i] != tt.Want[i] {
+					t.Errorf("issue %d = %v, want %v", i, got[i], tt.Want[i])
+				}
+			}
+			if calls != tt.WantCalls {
+				t.Errorf("pipe functions called %d times, want %d", calls, tt.WantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Package chess provides pipefence pipes rendering chess positions
+// as SVG boards.
+//
+// It is implemented in pure Go and needs no external tools.  The FEN
+// pipe renders positions in Forsyth-Edwards Notation, the PGN pipe
+// renders the position at the end of a game in Portable Game
+// Notation, highlighting the last move.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"fen": chess.FEN,
+//			"pgn": chess.PGN,
+//		},
+//	}
+//
+// The rendering can be adjusted with block options:
+//
+//	```pgn orientation=black ply=5 highlight=f7 arrows=d1h5,h5f7
+//	1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7#
+//	```
+//
+// The orientation option is "white" (the default) or "black".  The
+// ply option selects the position after the given number of half
+// moves of a game.  The highlight option lists squares to highlight,
+// the arrows option moves to draw as arrows.  Setting the
+// coordinates option to false hides the file and rank labels.
+package chess
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Board dimensions in SVG user units.
+const (
+	squareSize = 45
+	margin     = 16 // Space for the coordinates.
+)
+
+// glyphs are the Unicode chess symbols.  White pieces are drawn with
+// the solid black symbols filled white, so that they look the same
+// in all fonts.
+var glyphs = map[byte]string{'k': "♚", 'q': "♛", 'r': "♜", 'b': "♝", 'n': "♞", 'p': "♟"}
+
+// FEN is a pipefence.PipeFuncCtx rendering a position in
+// Forsyth-Edwards Notation.
+func FEN(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	p, err := parseFEN(string(src))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := info.Options["ply"]; ok {
+		return nil, fmt.Errorf("the ply option requires a game")
+	}
+	return render(p, info.Options)
+}
+
+// PGN is a pipefence.PipeFuncCtx rendering the final position of a
+// game in Portable Game Notation, or the position selected with the
+// ply option.
+func PGN(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	g, err := parsePGN(string(src))
+	if err != nil {
+		return nil, err
+	}
+	plies := len(g.moves)
+	if v, ok := info.Options["ply"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > len(g.moves) {
+			return nil, fmt.Errorf("invalid ply %q: the game has %d half moves", v, len(g.moves))
+		}
+		plies = n
+	}
+	p, err := g.position(plies)
+	if err != nil {
+		return nil, err
+	}
+	return render(p, info.Options)
+}
+
+// render renders a board.
+func render(p *position, opts map[string]string) ([]byte, error) {
+	flip := false
+	switch o := opts["orientation"]; o {
+	case "", "white":
+	case "black":
+		flip = true
+	default:
+		return nil, fmt.Errorf("invalid orientation %q", o)
+	}
+	coords := true
+	if v, ok := opts["coordinates"]; ok {
+		var err error
+		if coords, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid coordinates option %q: %w", v, err)
+		}
+	}
+
+	var highlights []int
+	if p.lastFrom >= 0 {
+		highlights = append(highlights, p.lastFrom, p.lastTo)
+	}
+	if v := opts["highlight"]; v != "" {
+		for _, name := range strings.Split(v, ",") {
+			sq, ok := parseSquare(name)
+			if !ok {
+				return nil, fmt.Errorf("invalid square %q", name)
+			}
+			highlights = append(highlights, sq)
+		}
+	}
+	var arrows [][2]int
+	if v := opts["arrows"]; v != "" {
+		for _, move := range strings.Split(v, ",") {
+			from, ok1 := parseSquare(move[:min(2, len(move))])
+			to, ok2 := parseSquare(move[min(2, len(move)):])
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("invalid arrow %q", move)
+			}
+			arrows = append(arrows, [2]int{from, to})
+		}
+	}
+
+	off := 0
+	if coords {
+		off = margin
+	}
+	// pos returns the top left corner of a square.
+	pos := func(sq int) (x, y int) {
+		file, rank := sq%8, 7-sq/8
+		if flip {
+			file, rank = 7-file, 7-rank
+		}
+		return off + file*squareSize, rank * squareSize
+	}
+
+	size := 8*squareSize + off
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" class="chessboard" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size, size, size, size)
+	fmt.Fprintf(&buf, `<rect x="%d" y="0" width="%d" height="%d" fill="#f0d9b5"/>`+"\n", off, 8*squareSize, 8*squareSize)
+	buf.WriteString(`<g fill="#b58863">` + "\n")
+	for sq := 0; sq < 64; sq++ {
+		if (sq%8+sq/8)%2 == 0 {
+			x, y := pos(sq)
+			fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d"/>`+"\n", x, y, squareSize, squareSize)
+		}
+	}
+	buf.WriteString("</g>\n")
+
+	if len(highlights) > 0 {
+		buf.WriteString(`<g fill="#ff0" fill-opacity="0.4">` + "\n")
+		for _, sq := range highlights {
+			x, y := pos(sq)
+			fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d"/>`+"\n", x, y, squareSize, squareSize)
+		}
+		buf.WriteString("</g>\n")
+	}
+
+	if coords {
+		buf.WriteString(`<g font-family="sans-serif" font-size="12" text-anchor="middle" fill="currentColor">` + "\n")
+		for i := 0; i < 8; i++ {
+			x, _ := pos(i)
+			_, y := pos(i * 8)
+			fmt.Fprintf(&buf, `<text x="%d" y="%d">%c</text>`+"\n", x+squareSize/2, 8*squareSize+12, 'a'+i)
+			fmt.Fprintf(&buf, `<text x="%d" y="%d">%c</text>`+"\n", margin/2, y+squareSize/2+4, '1'+i)
+		}
+		buf.WriteString("</g>\n")
+	}
+
+	buf.WriteString(`<g font-family="serif" font-size="38" text-anchor="middle" stroke="#000" stroke-width="1">` + "\n")
+	for sq, piece := range p.board {
+		if piece == 0 {
+			continue
+		}
+		fill := "#000"
+		if isWhite(piece) {
+			fill = "#fff"
+		}
+		x, y := pos(sq)
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", x+squareSize/2, y+squareSize-9, fill, glyphs[piece|0x20])
+	}
+	buf.WriteString("</g>\n")
+
+	if len(arrows) > 0 {
+		buf.WriteString(`<g fill="#15781b" stroke="#15781b" opacity="0.8">` + "\n")
+		for _, a := range arrows {
+			x1, y1 := pos(a[0])
+			x2, y2 := pos(a[1])
+			buf.WriteString(arrow(x1+squareSize/2, y1+squareSize/2, x2+squareSize/2, y2+squareSize/2))
+		}
+		buf.WriteString("</g>\n")
+	}
+
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// arrow returns a line with an arrowhead from (x1, y1) to (x2, y2).
+func arrow(x1, y1, x2, y2 int) string {
+	const head = 14
+	dx, dy := float64(x2-x1), float64(y2-y1)
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return ""
+	}
+	ux, uy := dx/l, dy/l
+	// The line ends at the base of the arrowhead.
+	bx, by := float64(x2)-ux*head, float64(y2)-uy*head
+	return fmt.Sprintf(`<line x1="%d" y1="%d" x2="%.1f" y2="%.1f" stroke-width="6"/>`+"\n"+
+		`<polygon points="%d,%d %.1f,%.1f %.1f,%.1f" stroke="none"/>`+"\n",
+		x1, y1, bx, by,
+		x2, y2, bx-uy*head/2, by+ux*head/2, bx+uy*head/2, by-ux*head/2)
+}
@@ -0,0 +1,185 @@
+package chess_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/chess"
+	"github.com/yuin/goldmark"
+)
+
+// pieces returns the group of pieces of a rendered board.
+func pieces(t *testing.T, svg []byte) string {
+	t.Helper()
+	s := string(svg)
+	start := strings.Index(s, `<g font-family="serif"`)
+	if start < 0 {
+		t.Fatalf("no pieces in %s", svg)
+	}
+	return s[start : start+strings.Index(s[start:], "</g>")]
+}
+
+func TestFEN(t *testing.T) {
+	for _, tt := range []struct {
+		Name    string
+		Options map[string]string
+		Want    []string
+	}{
+		{
+			Name: "Default",
+			Want: []string{
+				`<svg xmlns="http://www.w3.org/2000/svg" class="chessboard" width="376" height="376" viewBox="0 0 376 376">`,
+				`<text x="218" y="351" fill="#fff">♚</text>`,
+				`<text x="8" y="341">1</text>`,
+			},
+		},
+		{
+			Name:    "Black",
+			Options: map[string]string{"orientation": "black"},
+			Want: []string{
+				`<text x="173" y="36" fill="#fff">♚</text>`,
+				`<text x="8" y="26">1</text>`,
+			},
+		},
+		{
+			Name:    "Annotations",
+			Options: map[string]string{"coordinates": "false", "highlight": "e4", "arrows": "e2e4"},
+			Want: []string{
+				`width="360"`,
+				`<g fill="#ff0" fill-opacity="0.4">` + "\n" + `<rect x="180" y="180" width="45" height="45"/>`,
+				`<line x1="202" y1="292" x2="202.0" y2="216.0" stroke-width="6"/>`,
+				`<polygon points="202,202 209.0,216.0 195.0,216.0" stroke="none"/>`,
+			},
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := chess.FEN(context.Background(), []byte("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1\n"), pipefence.Info{Options: tt.Options})
+			if err != nil {
+				t.Fatalf("FEN: %v", err)
+			}
+			for _, want := range tt.Want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("FEN() = %s, want it to contain %s", got, want)
+				}
+			}
+			if strings.Contains(string(got), "<text x=\"8\"") != (tt.Options["coordinates"] != "false") {
+				t.Errorf("FEN() = %s, coordinates not as requested", got)
+			}
+		})
+	}
+}
+
+func TestPGN(t *testing.T) {
+	for _, tt := range []struct {
+		Name string
+		PGN  string
+		Ply  string
+		Want string // Expected position in FEN.
+	}{
+		{
+			Name: "Castling",
+			PGN:  "1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. O-O",
+			Want: "r1bqkbnr/1ppp1ppp/p1n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQ1RK1",
+		},
+		{
+			Name: "EnPassant",
+			PGN:  "1. e4 a6 2. e5 d5 3. exd6",
+			Want: "rnbqkbnr/1pp1pppp/p2P4/8/8/8/PPPP1PPP/RNBQKBNR",
+		},
+		{
+			Name: "Promotion",
+			PGN:  "[FEN \"8/P7/8/8/8/8/8/k6K w - - 0 1\"]\n\n1. a8=Q+ *",
+			Want: "Q7/8/8/8/8/8/8/k6K",
+		},
+		{
+			Name: "Disambiguation",
+			PGN:  "[FEN \"k7/8/8/8/8/8/7K/R6R w - - 0 1\"]\n1. Rad1",
+			Want: "k7/8/8/8/8/8/7K/3R3R",
+		},
+		{
+			Name: "Pin",
+			PGN:  "[FEN \"4k3/4r3/8/8/8/8/2N1N3/4K3 w - - 0 1\"]\n1. Nd4",
+			Want: "4k3/4r3/8/8/3N4/8/4N3/4K3",
+		},
+		{
+			Name: "Comments",
+			PGN:  "[Event \"Test\"]\n1. e4 {best by test} (1. d4 d5) e5 $1 ; main line\n2. Nf3! 1-0\n",
+			Want: "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R",
+		},
+		{
+			Name: "Ply",
+			PGN:  "1. e4 e5 2. Nf3",
+			Ply:  "1",
+			Want: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			info := pipefence.Info{Options: map[string]string{}}
+			if tt.Ply != "" {
+				info.Options["ply"] = tt.Ply
+			}
+			got, err := chess.PGN(context.Background(), []byte(tt.PGN), info)
+			if err != nil {
+				t.Fatalf("PGN(%q): %v", tt.PGN, err)
+			}
+			want, err := chess.FEN(context.Background(), []byte(tt.Want), pipefence.Info{})
+			if err != nil {
+				t.Fatalf("FEN(%q): %v", tt.Want, err)
+			}
+			if pieces(t, got) != pieces(t, want) {
+				t.Errorf("PGN(%q) = %s, want position %s", tt.PGN, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestPGNLastMove(t *testing.T) {
+	got, err := chess.PGN(context.Background(), []byte("1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7#"), pipefence.Info{})
+	if err != nil {
+		t.Fatalf("PGN: %v", err)
+	}
+	want := `<g fill="#ff0" fill-opacity="0.4">` + "\n" +
+		`<rect x="331" y="135" width="45" height="45"/>` + "\n" +
+		`<rect x="241" y="45" width="45" height="45"/>` + "\n"
+	if !strings.Contains(string(got), want) {
+		t.Errorf("PGN() = %s, want highlights %s", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"fen": chess.FEN,
+			"pgn": chess.PGN,
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Input string
+		Want  string
+	}{
+		{Input: "```fen\n8/8/8\n```\n", Want: `invalid FEN &quot;8/8/8\n&quot;: want 8 ranks`},
+		{Input: "```fen\n8/8/8/8/8/8/8/7\n```\n", Want: `rank 1 has 7 squares`},
+		{Input: "```fen ply=1\n8/8/8/8/8/8/8/8\n```\n", Want: `the ply option requires a game`},
+		{Input: "```fen orientation=up\n8/8/8/8/8/8/8/8\n```\n", Want: `invalid orientation &quot;up&quot;`},
+		{Input: "```fen arrows=e2\n8/8/8/8/8/8/8/8\n```\n", Want: `invalid arrow &quot;e2&quot;`},
+		{Input: "```pgn\n1. e5\n```\n", Want: `move 1: illegal move &quot;e5&quot;`},
+		{Input: "```pgn\n1. e4 e5 2. Ke3\n```\n", Want: `move 2: illegal move &quot;Ke3&quot;`},
+		{Input: "```pgn\n1. e4 e5 2. O-O\n```\n", Want: `move 2: illegal move &quot;O-O&quot;`},
+		{Input: "```pgn\n[FEN \"k7/8/8/8/8/8/7K/R6R w - - 0 1\"]\n1. Rd1\n```\n", Want: `ambiguous move &quot;Rd1&quot;`},
+		{Input: "```pgn ply=3\n1. e4 e5\n```\n", Want: `invalid ply &quot;3&quot;: the game has 2 half moves`},
+		{Input: "```pgn\n1. e4 (1. d4\n```\n", Want: `unterminated variation`},
+	} {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if !strings.Contains(buf.String(), tt.Want) {
+			t.Errorf("gmark.Convert(%q) = %s, want error %s", tt.Input, buf.String(), tt.Want)
+		}
+	}
+}
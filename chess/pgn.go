@@ -0,0 +1,118 @@
+package chess
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// game is a parsed PGN game.
+type game struct {
+	tags  map[string]string
+	moves []string // Moves of the main line in SAN.
+}
+
+var (
+	tagRE        = regexp.MustCompile(`^\[(\w+)\s+"((?:[^"\\]|\\.)*)"\]$`)
+	moveNumberRE = regexp.MustCompile(`^\d+\.+`)
+)
+
+// parsePGN parses a game in Portable Game Notation.  Comments,
+// variations and annotation glyphs are skipped.
+func parsePGN(src string) (*game, error) {
+	g := &game{tags: make(map[string]string)}
+	var movetext strings.Builder
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			m := tagRE.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid tag %q", line)
+			}
+			g.tags[m[1]] = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(m[2])
+			continue
+		}
+		if strings.HasPrefix(line, "%") {
+			continue
+		}
+		movetext.WriteString(line)
+		movetext.WriteByte('\n')
+	}
+
+	text := movetext.String()
+	depth := 0 // Nesting depth of variations.
+	for len(text) > 0 {
+		switch c := text[0]; {
+		case c == '{':
+			end := strings.IndexByte(text, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			text = text[end+1:]
+			continue
+		case c == ';':
+			end := strings.IndexByte(text, '\n')
+			if end < 0 {
+				end = len(text)
+			}
+			text = text[end:]
+			continue
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("unbalanced %q", ")")
+			}
+			depth--
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			end := strings.IndexAny(text, " \t\r\n{}();")
+			if end < 0 {
+				end = len(text)
+			}
+			tok := text[:end]
+			text = text[end:]
+			if depth == 0 {
+				g.addToken(tok)
+			}
+			continue
+		}
+		text = text[1:]
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("unterminated variation")
+	}
+	return g, nil
+}
+
+// addToken adds a token of the movetext, which is a move, possibly
+// preceded by a move number, a result or an annotation glyph.
+func (g *game) addToken(tok string) {
+	tok = moveNumberRE.ReplaceAllString(tok, "")
+	switch {
+	case tok == "", tok[0] == '$':
+	case tok == "1-0", tok == "0-1", tok == "1/2-1/2", tok == "*":
+	default:
+		g.moves = append(g.moves, tok)
+	}
+}
+
+// position returns the position after the given number of half
+// moves.
+func (g *game) position(plies int) (*position, error) {
+	fen := startFEN
+	if f, ok := g.tags["FEN"]; ok {
+		fen = f
+	}
+	p, err := parseFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	for i, move := range g.moves[:plies] {
+		p, err = p.san(move)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %w", i/2+1, err)
+		}
+	}
+	return p, nil
+}
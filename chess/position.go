@@ -0,0 +1,364 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// position is a chess position.  Squares are indexed from 0 (a1) to
+// 63 (h8), rank by rank.
+type position struct {
+	board     [64]byte // Pieces as in FEN, "PNBRQK" for white, 0 if empty.
+	blackMove bool
+	castling  string // Castling rights as in FEN, e.g. "KQkq".
+	enPassant int    // Target square of an en passant capture, or -1.
+	lastFrom  int    // Squares of the last move, or -1.
+	lastTo    int
+}
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// parseSquare parses a square name like "e4".
+func parseSquare(s string) (int, bool) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return 0, false
+	}
+	return int(s[1]-'1')*8 + int(s[0]-'a'), true
+}
+
+func squareName(sq int) string {
+	return string([]byte{byte('a' + sq%8), byte('1' + sq/8)})
+}
+
+// parseFEN parses a position in Forsyth-Edwards Notation.  Only the
+// piece placement is required.
+func parseFEN(fen string) (*position, error) {
+	fields := strings.Fields(fen)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty FEN")
+	}
+	p := &position{enPassant: -1, lastFrom: -1, lastTo: -1}
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("invalid FEN %q: want 8 ranks", fen)
+	}
+	for i, rank := range ranks {
+		file := 0
+		for _, c := range rank {
+			switch {
+			case c >= '1' && c <= '8':
+				file += int(c - '0')
+			case strings.ContainsRune("PNBRQKpnbrqk", c):
+				if file < 8 {
+					p.board[(7-i)*8+file] = byte(c)
+				}
+				file++
+			default:
+				return nil, fmt.Errorf("invalid FEN %q: unexpected %q", fen, c)
+			}
+		}
+		if file != 8 {
+			return nil, fmt.Errorf("invalid FEN %q: rank %d has %d squares", fen, 8-i, file)
+		}
+	}
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "w":
+		case "b":
+			p.blackMove = true
+		default:
+			return nil, fmt.Errorf("invalid FEN %q: side to move %q", fen, fields[1])
+		}
+	}
+	if len(fields) > 2 && fields[2] != "-" {
+		p.castling = fields[2]
+	}
+	if len(fields) > 3 && fields[3] != "-" {
+		sq, ok := parseSquare(fields[3])
+		if !ok {
+			return nil, fmt.Errorf("invalid FEN %q: en passant square %q", fen, fields[3])
+		}
+		p.enPassant = sq
+	}
+	return p, nil
+}
+
+// isWhite reports whether a piece is white.
+func isWhite(piece byte) bool {
+	return piece >= 'A' && piece <= 'Z'
+}
+
+// own returns the piece of the given type for the side to move.
+func (p *position) own(kind byte) byte {
+	if p.blackMove {
+		return kind + 'a' - 'A'
+	}
+	return kind
+}
+
+// Movement directions as file and rank deltas.  Queens move like
+// kings, but any distance.
+var (
+	knightMoves = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingMoves   = [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	rookDirs    = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	bishopDirs  = [][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+)
+
+// offset returns the square at the given delta from sq, or false if
+// it is off the board.
+func offset(sq int, d [2]int) (int, bool) {
+	f, r := sq%8+d[0], sq/8+d[1]
+	if f < 0 || f > 7 || r < 0 || r > 7 {
+		return 0, false
+	}
+	return r*8 + f, true
+}
+
+// reaches reports whether the piece on from attacks or can move to
+// to, ignoring pawn pushes, castling and checks.
+func (p *position) reaches(from, to int) bool {
+	piece := p.board[from]
+	switch piece | 0x20 {
+	case 'p':
+		dir := 1
+		if !isWhite(piece) {
+			dir = -1
+		}
+		return to/8-from/8 == dir && (to%8-from%8 == 1 || to%8-from%8 == -1)
+	case 'n', 'k':
+		moves := knightMoves
+		if piece|0x20 == 'k' {
+			moves = kingMoves
+		}
+		for _, d := range moves {
+			if sq, ok := offset(from, d); ok && sq == to {
+				return true
+			}
+		}
+		return false
+	}
+
+	var dirs [][2]int
+	switch piece | 0x20 {
+	case 'r':
+		dirs = rookDirs
+	case 'b':
+		dirs = bishopDirs
+	case 'q':
+		dirs = kingMoves
+	}
+	for _, d := range dirs {
+		for sq, ok := offset(from, d); ok; sq, ok = offset(sq, d) {
+			if sq == to {
+				return true
+			}
+			if p.board[sq] != 0 {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// attacked reports whether sq is attacked by the given side.
+func (p *position) attacked(sq int, byWhite bool) bool {
+	for from, piece := range p.board {
+		if piece != 0 && isWhite(piece) == byWhite && p.reaches(from, sq) {
+			return true
+		}
+	}
+	return false
+}
+
+// inCheck reports whether the king of the given side is attacked.
+func (p *position) inCheck(white bool) bool {
+	king := byte('K')
+	if !white {
+		king = 'k'
+	}
+	for sq, piece := range p.board {
+		if piece == king {
+			return p.attacked(sq, !white)
+		}
+	}
+	return false
+}
+
+// canMove reports whether the piece on from can move to to,
+// ignoring checks.
+func (p *position) canMove(from, to int) bool {
+	piece := p.board[from]
+	if target := p.board[to]; target != 0 && isWhite(target) == isWhite(piece) {
+		return false
+	}
+	if piece|0x20 != 'p' {
+		return p.reaches(from, to)
+	}
+
+	dir, startRank := 8, 1
+	if !isWhite(piece) {
+		dir, startRank = -8, 6
+	}
+	switch {
+	case to == from+dir:
+		return p.board[to] == 0
+	case to == from+2*dir:
+		return from/8 == startRank && p.board[from+dir] == 0 && p.board[to] == 0
+	}
+	return p.reaches(from, to) && (p.board[to] != 0 || to == p.enPassant)
+}
+
+// move makes a move, which must be valid apart from checks, and
+// returns the resulting position.
+func (p *position) move(from, to int, promotion byte) *position {
+	n := *p
+	piece := n.board[from]
+	n.board[to] = piece
+	n.board[from] = 0
+	n.lastFrom, n.lastTo = from, to
+	n.enPassant = -1
+
+	switch piece | 0x20 {
+	case 'p':
+		if to == p.enPassant {
+			n.board[from/8*8+to%8] = 0
+		}
+		if to-from == 16 || from-to == 16 {
+			n.enPassant = (from + to) / 2
+		}
+		if to/8 == 0 || to/8 == 7 {
+			n.board[to] = n.own(promotion)
+		}
+	case 'k':
+		// Castling moves the rook, too.
+		if to-from == 2 {
+			n.board[from+1], n.board[from+3] = n.board[from+3], 0
+		} else if from-to == 2 {
+			n.board[from-1], n.board[from-4] = n.board[from-4], 0
+		}
+	}
+
+	// Moving the king or a rook, or capturing a rook, loses the
+	// corresponding castling rights.
+	for _, r := range []struct {
+		right   string
+		squares []int
+	}{
+		{"K", []int{4, 7}}, {"Q", []int{4, 0}}, {"k", []int{60, 63}}, {"q", []int{60, 56}},
+	} {
+		for _, sq := range r.squares {
+			if sq == from || sq == to {
+				n.castling = strings.Replace(n.castling, r.right, "", 1)
+			}
+		}
+	}
+
+	n.blackMove = !n.blackMove
+	return &n
+}
+
+// san makes a move in Standard Algebraic Notation, e.g. "Nbd7",
+// "exd5", "e8=Q+" or "O-O".
+func (p *position) san(move string) (*position, error) {
+	s := strings.TrimRight(move, "+#!?")
+	home := 0
+	if p.blackMove {
+		home = 56
+	}
+	switch s {
+	case "O-O", "0-0":
+		return p.castle(move, home+4, home+6, home+7, []int{home + 5, home + 6}, p.own('K'))
+	case "O-O-O", "0-0-0":
+		return p.castle(move, home+4, home+2, home+0, []int{home + 3, home + 2, home + 1}, p.own('Q'))
+	}
+
+	var promotion byte
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		s = s[:i] + s[i+1:]
+	}
+	if len(s) > 2 && strings.IndexByte("NBRQ", s[len(s)-1]) >= 0 {
+		promotion, s = s[len(s)-1], s[:len(s)-1]
+	}
+
+	kind := byte('P')
+	if len(s) > 0 && strings.IndexByte("NBRQK", s[0]) >= 0 {
+		kind, s = s[0], s[1:]
+	}
+	if len(s) < 2 {
+		return nil, fmt.Errorf("invalid move %q", move)
+	}
+	to, ok := parseSquare(s[len(s)-2:])
+	if !ok {
+		return nil, fmt.Errorf("invalid move %q", move)
+	}
+	hint := strings.Replace(s[:len(s)-2], "x", "", 1)
+	if len(hint) > 2 {
+		return nil, fmt.Errorf("invalid move %q", move)
+	}
+	if kind == 'P' && (to/8 == 0 || to/8 == 7) {
+		if promotion == 0 {
+			return nil, fmt.Errorf("invalid move %q: missing promotion", move)
+		}
+	} else if promotion != 0 {
+		return nil, fmt.Errorf("invalid move %q: promotion", move)
+	}
+
+	var result *position
+	for from, piece := range p.board {
+		if piece != p.own(kind) || !matchesHint(from, hint) || !p.canMove(from, to) {
+			continue
+		}
+		n := p.move(from, to, promotion)
+		if n.inCheck(!p.blackMove) {
+			continue
+		}
+		if result != nil {
+			return nil, fmt.Errorf("ambiguous move %q", move)
+		}
+		result = n
+	}
+	if result == nil {
+		return nil, fmt.Errorf("illegal move %q", move)
+	}
+	return result, nil
+}
+
+// matchesHint reports whether sq matches the disambiguating file
+// and rank of a move.
+func matchesHint(sq int, hint string) bool {
+	for _, c := range []byte(hint) {
+		switch {
+		case c >= 'a' && c <= 'h':
+			if sq%8 != int(c-'a') {
+				return false
+			}
+		case c >= '1' && c <= '8':
+			if sq/8 != int(c-'1') {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// castle castles, if the rights, the empty squares and the absence
+// of attacks allow it.
+func (p *position) castle(move string, king, to, rook int, between []int, right byte) (*position, error) {
+	if !strings.ContainsRune(p.castling, rune(right)) || p.board[king] != p.own('K') || p.board[rook] != p.own('R') {
+		return nil, fmt.Errorf("illegal move %q", move)
+	}
+	for _, sq := range between {
+		if p.board[sq] != 0 {
+			return nil, fmt.Errorf("illegal move %q", move)
+		}
+	}
+	for _, sq := range []int{king, (king + to) / 2, to} {
+		if p.attacked(sq, p.blackMove) {
+			return nil, fmt.Errorf("illegal move %q", move)
+		}
+	}
+	return p.move(king, to, 0), nil
+}
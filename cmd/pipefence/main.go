@@ -0,0 +1,289 @@
+// Command pipefence converts Markdown files to HTML, piping fenced
+// code blocks through external commands.
+//
+// Usage:
+//
+//	pipefence [-config pipefence.json] [-o outdir] [file or glob ...]
+//	pipefence [-config pipefence.json] -check-tools
+//
+// Without arguments, pipefence reads Markdown from standard input and
+// writes HTML to standard output.  Otherwise, each input file is
+// converted to an .html file of the same name, placed next to the
+// input or in the output directory given with -o.  In the output
+// directory, the files keep their paths relative to the deepest
+// directory containing all inputs, so that docs/a/index.md and
+// docs/b/index.md become out/a/index.html and out/b/index.html.
+// Glob patterns such as "docs/*.md" are expanded by pipefence
+// itself, so they work when quoted as well.
+//
+// The config file maps fence languages to commands, which receive
+// the block content on standard input:
+//
+//	{
+//		"pipes": {
+//			"dot": ["dot", "-Tsvg"],
+//			"pikchr": ["pikchr", "--svg-only", "-"]
+//		},
+//		"softPipes": ["pikchr"],
+//		"workers": 4,
+//		"timeout": "30s",
+//		"errorMode": "inline"
+//	}
+//
+// The errorMode is one of "fail" (the default), "original" and
+// "inline", corresponding to the pipefence ErrorModes.  Blocks of
+// the softPipes render as plain code blocks if the command is not
+// installed.
+//
+// With -check-tools, pipefence runs the commands of all pipes with
+// --version and reports the results instead of converting files.  It
+// fails if a command which is not soft is unusable.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+// config is the format of the config file.
+type config struct {
+	Pipes     map[string][]string `json:"pipes"`
+	SoftPipes []string            `json:"softPipes"`
+	Workers   int                 `json:"workers"`
+	Timeout   string              `json:"timeout"`
+	ErrorMode string              `json:"errorMode"`
+}
+
+// loadConfig reads the config file at path.
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// extension returns the pipefence extension described by c.
+func (c *config) extension() (*pipefence.Extension, error) {
+	ext := &pipefence.Extension{
+		Tools:   make(map[string]pipefence.Tool),
+		Workers: c.Workers,
+	}
+	for lang, argv := range c.Pipes {
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("pipe %q: empty command", lang)
+		}
+		ext.RegisterExec(lang, argv[0], argv[1:]...)
+		if slices.Contains(c.SoftPipes, lang) {
+			ext.Tools[lang] = pipefence.Tool{Command: argv[0], Soft: true}
+		}
+	}
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("timeout: %w", err)
+		}
+		ext.Timeout = d
+	}
+	switch c.ErrorMode {
+	case "", "fail":
+		ext.ErrorMode = pipefence.FailFast
+	case "original":
+		ext.ErrorMode = pipefence.RenderOriginalBlock
+	case "inline":
+		ext.ErrorMode = pipefence.RenderErrorInline
+	default:
+		return nil, fmt.Errorf("unknown errorMode %q", c.ErrorMode)
+	}
+	return ext, nil
+}
+
+// expand expands the glob patterns in args.  Arguments which are
+// not patterns, or which match nothing, are kept as they are, so
+// that missing files are reported when they are read.
+func expand(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			matches = []string{arg}
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// outputPaths returns the paths of the HTML files for the inputs.
+// With an outDir, the inputs keep their paths relative to the
+// deepest directory containing all of them.  It fails if two inputs
+// map to the same output.
+func outputPaths(inputs []string, outDir string) ([]string, error) {
+	abs := make([]string, len(inputs))
+	for i, input := range inputs {
+		a, err := filepath.Abs(input)
+		if err != nil {
+			return nil, err
+		}
+		abs[i] = a
+	}
+	root := commonDir(abs)
+
+	outputs := make([]string, len(inputs))
+	seen := make(map[string]int) // Output to index of input.
+	for i, input := range inputs {
+		name := strings.TrimSuffix(abs[i], filepath.Ext(abs[i])) + ".html"
+		if outDir == "" {
+			name = strings.TrimSuffix(input, filepath.Ext(input)) + ".html"
+		} else {
+			if root == "" {
+				return nil, fmt.Errorf("%s: no directory contains all inputs", input)
+			}
+			rel, err := filepath.Rel(root, name)
+			if err != nil {
+				return nil, err
+			}
+			name = filepath.Join(outDir, rel)
+		}
+		if k, ok := seen[name]; ok && abs[k] != abs[i] {
+			return nil, fmt.Errorf("%s and %s are both converted to %s", inputs[k], input, name)
+		}
+		seen[name] = i
+		outputs[i] = name
+	}
+	return outputs, nil
+}
+
+// commonDir returns the deepest directory containing all the given
+// absolute paths.
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	dir := filepath.Dir(paths[0])
+	for _, p := range paths[1:] {
+		for !within(p, dir) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				// E.g. different volumes on Windows.
+				return ""
+			}
+			dir = parent
+		}
+	}
+	return dir
+}
+
+// within reports whether path is inside the directory dir.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("pipefence", flag.ContinueOnError)
+	configPath := fs.String("config", "pipefence.json", "path of the config file")
+	outDir := fs.String("o", "", "output directory (default: next to the inputs)")
+	checkTools := fs.Bool("check-tools", false, "check that the commands of the pipes are installed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	ext, err := c.extension()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *configPath, err)
+	}
+	if *checkTools {
+		return checkToolsReport(ext, stdout)
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	if fs.NArg() == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		return gmark.Convert(src, stdout)
+	}
+
+	files, err := expand(fs.Args())
+	if err != nil {
+		return err
+	}
+	outputs, err := outputPaths(files, *outDir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, input := range files {
+		src, err := os.ReadFile(input)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pc := parser.NewContext()
+		pipefence.WithPath(pc, input)
+		var buf bytes.Buffer
+		if err := gmark.Convert(src, &buf, parser.WithContext(pc)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", input, err))
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(outputs[i]), 0o755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.WriteFile(outputs[i], buf.Bytes(), 0o644); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkToolsReport writes the results of ext.ValidateTools to w.  It
+// fails if a tool which is not soft is unusable.
+func checkToolsReport(ext *pipefence.Extension, w io.Writer) error {
+	var failed []string
+	for _, s := range ext.ValidateTools(context.Background()) {
+		fmt.Fprintln(w, s)
+		if s.Err != nil && !s.Soft {
+			failed = append(failed, s.Language)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unusable tools for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "pipefence:", err)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+	"pipes": {"shout": ["tr", "a-z", "A-Z"]},
+	"errorMode": "inline"
+}`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "pipefence.json")
+	writeFile(t, config, testConfig)
+
+	var out strings.Builder
+	err := run([]string{"-config", config}, strings.NewReader("```shout\nhello\n```\n"), &out)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, want := out.String(), "HELLO\n"; got != want {
+		t.Errorf("run: output = %q, want %q", got, want)
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "pipefence.json")
+	writeFile(t, config, testConfig)
+	writeFile(t, filepath.Join(dir, "a.md"), "```shout\na\n```\n")
+	writeFile(t, filepath.Join(dir, "b.md"), "```shout\nb\n```\n")
+	outDir := filepath.Join(dir, "out")
+
+	err := run([]string{"-config", config, "-o", outDir, filepath.Join(dir, "*.md")}, nil, nil)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for name, want := range map[string]string{"a.html": "A\n", "b.html": "B\n"} {
+		got, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Errorf("os.ReadFile: %v", err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRunFilesNested(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "pipefence.json")
+	writeFile(t, config, testConfig)
+	for _, sub := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, "docs", sub), 0o755); err != nil {
+			t.Fatalf("os.MkdirAll: %v", err)
+		}
+		writeFile(t, filepath.Join(dir, "docs", sub, "index.md"), "```shout\n"+sub+"\n```\n")
+	}
+	outDir := filepath.Join(dir, "out")
+
+	err := run([]string{"-config", config, "-o", outDir, filepath.Join(dir, "docs", "*", "index.md")}, nil, nil)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for name, want := range map[string]string{"a/index.html": "A\n", "b/index.html": "B\n"} {
+		got, err := os.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Errorf("os.ReadFile: %v", err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestOutputPathsConflict(t *testing.T) {
+	_, err := outputPaths([]string{"a/doc.md", "a/doc.markdown"}, "out")
+	if err == nil || !strings.Contains(err.Error(), "both converted to") {
+		t.Errorf("outputPaths() = %v, want a conflict error", err)
+	}
+	if _, err := outputPaths([]string{"a/doc.md", "a/doc.md"}, "out"); err != nil {
+		t.Errorf("outputPaths() with a repeated input = %v, want nil", err)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, tt := range []struct {
+		Name   string
+		Config config
+	}{
+		{Name: "EmptyCommand", Config: config{Pipes: map[string][]string{"dot": nil}}},
+		{Name: "BadTimeout", Config: config{Timeout: "soon"}},
+		{Name: "BadErrorMode", Config: config{ErrorMode: "ignore"}},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			if _, err := tt.Config.extension(); err == nil {
+				t.Errorf("extension() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestRunCheckTools(t *testing.T) {
+	for _, tt := range []struct {
+		Name    string
+		Config  string
+		Want    string
+		WantErr bool
+	}{
+		{
+			Name:   "Present",
+			Config: `{"pipes": {"shout": ["cat"]}}`,
+			Want:   "shout: ",
+		},
+		{
+			Name:   "SoftMissing",
+			Config: `{"pipes": {"x": ["pipefence-no-such-tool"]}, "softPipes": ["x"]}`,
+			Want:   "x: exec: \"pipefence-no-such-tool\"",
+		},
+		{
+			Name:    "Missing",
+			Config:  `{"pipes": {"x": ["pipefence-no-such-tool"]}}`,
+			Want:    "x: exec: \"pipefence-no-such-tool\"",
+			WantErr: true,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			config := filepath.Join(t.TempDir(), "pipefence.json")
+			writeFile(t, config, tt.Config)
+
+			var out strings.Builder
+			err := run([]string{"-config", config, "-check-tools"}, nil, &out)
+			if (err != nil) != tt.WantErr {
+				t.Errorf("run: err = %v, want error: %v", err, tt.WantErr)
+			}
+			if !strings.HasPrefix(out.String(), tt.Want) {
+				t.Errorf("run: output = %q, want prefix %q", out.String(), tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package pipefence
+
+import "bytes"
+
+// ColorSchemes are the options with which the blocks of a language
+// are rendered for light and dark color schemes.  They are added to
+// the options of each block, so that pipes can pick them up, e.g.
+// with argument placeholders of ExecPipe:
+//
+//	ext.Register("dot", pipefence.ExecPipeWith(pipefence.ExecOptions{
+//		ArgDefaults: map[string]string{"fg": "black"},
+//	}, "dot", "-Tsvg", "-Ncolor={{.fg}}", "-Ecolor={{.fg}}"))
+//	ext.ColorSchemes = map[string]pipefence.ColorSchemes{
+//		"dot": {Dark: map[string]string{"fg": "white"}},
+//	}
+type ColorSchemes struct {
+	Light map[string]string
+	Dark  map[string]string
+}
+
+// ColorSchemeCSS is a stylesheet showing the outputs for the color
+// scheme preferred by the user, for pages with ColorSchemes.  Sites
+// with a color scheme toggle can instead hide .pipefence-light or
+// .pipefence-dark depending on a class of their own.
+const ColorSchemeCSS = `.pipefence-dark { display: none; }
+@media (prefers-color-scheme: dark) {
+  .pipefence-light { display: none; }
+  .pipefence-dark { display: revert; }
+}
+`
+
+// colorSchemes returns the HTML combining the outputs for light and
+// dark color schemes.  For inline outputs, it uses <span> elements.
+func colorSchemes(light, dark []byte, inline bool) []byte {
+	elem, nl := "div", "\n"
+	if inline {
+		elem, nl = "span", ""
+	}
+	var buf bytes.Buffer
+	for _, v := range []struct {
+		class string
+		out   []byte
+	}{{"pipefence-light", light}, {"pipefence-dark", dark}} {
+		buf.WriteString("<" + elem + ` class="` + v.class + `">` + nl)
+		buf.Write(v.out)
+		buf.WriteString("</" + elem + ">\n")
+	}
+	if inline {
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,63 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestColorSchemes(t *testing.T) {
+	themed := func(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		return []byte(info.Options["fg"] + ":" + string(src)), nil
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{"dot": themed, "plain": themed},
+		InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{
+			"dot": func(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+				return []byte(info.Options["fg"]), nil
+			},
+		},
+		ColorSchemes: map[string]pipefence.ColorSchemes{
+			"dot": {
+				Light: map[string]string{"fg": "black"},
+				Dark:  map[string]string{"fg": "white"},
+			},
+		},
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Block",
+			Input: "```dot\nx\n```\n",
+			Want: "<div class=\"pipefence-light\">\nblack:x\n</div>\n" +
+				"<div class=\"pipefence-dark\">\nwhite:x\n</div>\n",
+		},
+		{
+			Name:  "Inline",
+			Input: "a `dot:x` b\n",
+			Want:  "<p>a <span class=\"pipefence-light\">black</span>\n<span class=\"pipefence-dark\">white</span> b</p>\n",
+		},
+		{
+			Name:  "OtherLanguage",
+			Input: "```plain\nx\n```\n",
+			Want:  ":x\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
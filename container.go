@@ -0,0 +1,64 @@
+package pipefence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os/exec"
+)
+
+// Container configures ExecPipeWith to run commands inside a
+// throwaway container, so that untrusted block contents can not
+// exploit the tools on the host.  The container has no network, no
+// mounts except for an empty /tmp, a read-only root file system and
+// no capabilities.
+type Container struct {
+	// Runtime is the container runtime command, e.g. "docker" or
+	// "podman".  If empty, "docker" is used.
+	Runtime string
+
+	// Image is the image containing the command.
+	Image string
+
+	// Args are additional arguments to the run command of the
+	// runtime, e.g. "--memory=256m" or "--user=nobody".
+	Args []string
+}
+
+// runtime returns the container runtime command.
+func (c *Container) runtime() string {
+	if c.Runtime == "" {
+		return "docker"
+	}
+	return c.Runtime
+}
+
+// wrap returns the command line running argv in a container, and a
+// function removing the container.  The environment variables named
+// env are passed from the runtime client into the container.
+func (c *Container) wrap(argv, env []string) (wrapped []string, remove func()) {
+	runtime := c.runtime()
+	var suffix [8]byte
+	rand.Read(suffix[:])
+	id := "pipefence-" + hex.EncodeToString(suffix[:])
+
+	wrapped = []string{
+		runtime, "run", "--rm", "--interactive",
+		"--name", id,
+		"--network", "none",
+		"--read-only",
+		"--tmpfs", "/tmp",
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+	}
+	for _, k := range env {
+		// The values are taken from the environment of the
+		// client, so that they do not show in process lists.
+		wrapped = append(wrapped, "--env", k)
+	}
+	wrapped = append(wrapped, c.Args...)
+	wrapped = append(wrapped, "--", c.Image)
+	wrapped = append(wrapped, argv...)
+	return wrapped, func() {
+		exec.Command(runtime, "rm", "--force", id).Run()
+	}
+}
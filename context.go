@@ -0,0 +1,53 @@
+package pipefence
+
+import (
+	"context"
+	"slices"
+
+	"github.com/yuin/goldmark/parser"
+)
+
+var (
+	// contextKey is the parser.Context key for the context of a
+	// conversion.
+	contextKey = parser.NewContextKey()
+
+	// valuesKey is the parser.Context key for the values added with
+	// WithValue.
+	valuesKey = parser.NewContextKey()
+
+	// pathKey is the parser.Context key for the document path.
+	pathKey = parser.NewContextKey()
+)
+
+// contextValue is a value added with WithValue.
+type contextValue struct {
+	key, value any
+}
+
+// WithContext sets the context for the pipe functions of a single
+// conversion.  It takes precedence over Extension.Context.  This
+// allows passing per-request deadlines, or values such as
+// credentials for remote renderers, to the pipes:
+//
+//	pc := parser.NewContext()
+//	pipefence.WithContext(pc, r.Context())
+//	err := md.Convert(src, w, parser.WithContext(pc))
+func WithContext(pc parser.Context, ctx context.Context) {
+	pc.Set(contextKey, ctx)
+}
+
+// WithValue adds a value to the context for the pipe functions of a
+// single conversion, as with context.WithValue.  Pipe functions
+// retrieve it with ctx.Value(key).  The value is added to the context
+// set with WithContext, or else to Extension.Context.
+func WithValue(pc parser.Context, key, value any) {
+	values, _ := pc.Get(valuesKey).([]contextValue)
+	pc.Set(valuesKey, append(slices.Clip(values), contextValue{key, value}))
+}
+
+// WithPath sets the path of the Markdown document for a single
+// conversion.  The pipe functions receive it as Info.Path.
+func WithPath(pc parser.Context, path string) {
+	pc.Set(pathKey, path)
+}
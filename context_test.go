@@ -0,0 +1,113 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestWithContext(t *testing.T) {
+	type key struct{}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"banana": func(ctx context.Context, a []byte, _ pipefence.Info) ([]byte, error) {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				v, _ := ctx.Value(key{}).(string)
+				return []byte(v), nil
+			},
+		},
+	}))
+	input := []byte("```banana\nfoo\n```\n")
+
+	// Values are only visible within the conversion they were set for.
+	pc := parser.NewContext()
+	pipefence.WithValue(pc, key{}, "faa")
+	var buf bytes.Buffer
+	if err := gmark.Convert(input, &buf, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got, want := buf.String(), "faa"; got != want {
+		t.Errorf("gmark.Convert() = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := gmark.Convert(input, &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got := buf.String(); got != "" {
+		t.Errorf("gmark.Convert() without value = %q, want empty output", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pc = parser.NewContext()
+	pipefence.WithContext(pc, ctx)
+	err := gmark.Convert(input, &buf, parser.WithContext(pc))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("gmark.Convert() with cancelled context: err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestWithValueExtensionContext(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"banana": func(ctx context.Context, a []byte, _ pipefence.Info) ([]byte, error) {
+				called = true
+				return a, nil
+			},
+		},
+		Context: ctx,
+	}))
+
+	// Adding a value must not drop the cancellation of the
+	// Extension's context.
+	pc := parser.NewContext()
+	pipefence.WithValue(pc, key{}, "faa")
+	err := gmark.Convert([]byte("```banana\nfoo\n```\n"), io.Discard, parser.WithContext(pc))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("gmark.Convert() with cancelled Extension.Context: err = %v, want %v", err, context.Canceled)
+	}
+	if called {
+		t.Errorf("pipe function called with cancelled Extension.Context")
+	}
+}
+
+func TestWithPath(t *testing.T) {
+	var got []pipefence.Info
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"dot": func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+				got = append(got, info)
+				return nil, nil
+			},
+		},
+	}))
+
+	pc := parser.NewContext()
+	pipefence.WithPath(pc, "docs/arch.md")
+	input := "# Architecture\n\n```dot\na\n```\n\n```dot\nb\n```\n"
+	if err := gmark.Convert([]byte(input), io.Discard, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("pipe called %d times, want 2", len(got))
+	}
+	for i, wantLine := range []int{3, 7} {
+		if got[i].Path != "docs/arch.md" || got[i].Line != wantLine {
+			t.Errorf("block %d: Path, Line = %q, %d, want %q, %d", i, got[i].Path, got[i].Line, "docs/arch.md", wantLine)
+		}
+	}
+}
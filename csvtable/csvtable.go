@@ -0,0 +1,230 @@
+// Package csvtable provides a pipefence pipe rendering CSV data as
+// HTML tables.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"csv": csvtable.Pipe(csvtable.Options{}),
+//		},
+//	}
+//
+// The rendering can be adjusted with block options:
+//
+//	```csv header=true align=left,right sortable delimiter=;
+//	Name;Count
+//	apples;3
+//	```
+//
+// The header option is "true", "false" or "auto" (the default), in
+// which case the first row is a header if it contains no numbers.
+// The align option lists the alignments of the columns ("left",
+// "right" or "center"); by default, columns containing only numbers
+// are aligned right.  The delimiter option is a single character,
+// or "tab".
+package csvtable
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark/util"
+)
+
+// Options are the defaults for the block options.
+type Options struct {
+	// Class is the class of the <table> element.  If empty,
+	// "csv" is used.
+	Class string
+
+	// Sortable adds the class "sortable" to all tables, and
+	// data-sort attributes ("number" or "text") to the header
+	// cells, for use with a table sorting script.  It can be
+	// enabled per block with the sortable option.
+	Sortable bool
+
+	// Delimiter is the field delimiter.  If zero, ',' is used.
+	Delimiter rune
+}
+
+// Pipe returns a pipe function rendering CSV as an HTML table.
+func Pipe(opts Options) pipefence.PipeFuncCtx {
+	return func(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		return render(src, opts, info.Options)
+	}
+}
+
+func render(src []byte, opts Options, blockOpts map[string]string) ([]byte, error) {
+	r := csv.NewReader(bytes.NewReader(src))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	if opts.Delimiter != 0 {
+		r.Comma = opts.Delimiter
+	}
+	if d, ok := blockOpts["delimiter"]; ok {
+		c, err := delimiter(d)
+		if err != nil {
+			return nil, err
+		}
+		r.Comma = c
+	}
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var header []string
+	switch h := blockOpts["header"]; h {
+	case "", "auto":
+		if len(rows) > 0 && !anyNumber(rows[0]) {
+			header, rows = rows[0], rows[1:]
+		}
+	case "true":
+		if len(rows) > 0 {
+			header, rows = rows[0], rows[1:]
+		}
+	case "false":
+	default:
+		return nil, fmt.Errorf("invalid header option %q", h)
+	}
+
+	cols := len(header)
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	numeric := make([]bool, cols)
+	for c := range numeric {
+		numeric[c] = numericColumn(rows, c)
+	}
+	aligns, err := alignments(blockOpts["align"], numeric)
+	if err != nil {
+		return nil, err
+	}
+	_, sortable := blockOpts["sortable"]
+	sortable = sortable || opts.Sortable
+
+	class := opts.Class
+	if class == "" {
+		class = "csv"
+	}
+	if sortable {
+		class += " sortable"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<table class=\"%s\">\n", util.EscapeHTML([]byte(class)))
+	if header != nil {
+		buf.WriteString("<thead>\n<tr>")
+		for c := 0; c < cols; c++ {
+			var attrs string
+			if sortable {
+				attrs = ` data-sort="text"`
+				if numeric[c] {
+					attrs = ` data-sort="number"`
+				}
+			}
+			writeCell(&buf, "th", attrs+aligns[c], cell(header, c))
+		}
+		buf.WriteString("</tr>\n</thead>\n")
+	}
+	buf.WriteString("<tbody>\n")
+	for _, row := range rows {
+		buf.WriteString("<tr>")
+		for c := 0; c < cols; c++ {
+			writeCell(&buf, "td", aligns[c], cell(row, c))
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</tbody>\n</table>\n")
+	return buf.Bytes(), nil
+}
+
+func writeCell(buf *bytes.Buffer, tag, attrs, content string) {
+	fmt.Fprintf(buf, "<%s%s>", tag, attrs)
+	buf.Write(util.EscapeHTML([]byte(content)))
+	fmt.Fprintf(buf, "</%s>", tag)
+}
+
+// cell returns the cell c of row, or "" for missing cells.
+func cell(row []string, c int) string {
+	if c < len(row) {
+		return row[c]
+	}
+	return ""
+}
+
+// delimiter parses the delimiter option.
+func delimiter(s string) (rune, error) {
+	if s == "tab" {
+		return '\t', nil
+	}
+	c, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return c, nil
+}
+
+// alignments returns the style attributes for the columns, according
+// to the align option or else the numeric columns.
+func alignments(opt string, numeric []bool) ([]string, error) {
+	aligns := make([]string, len(numeric))
+	for c, n := range numeric {
+		if n {
+			aligns[c] = ` style="text-align: right"`
+		}
+	}
+	if opt == "" {
+		return aligns, nil
+	}
+	for c, a := range strings.Split(opt, ",") {
+		if c >= len(aligns) {
+			break
+		}
+		switch a = strings.TrimSpace(a); a {
+		case "left", "right", "center":
+			aligns[c] = ` style="text-align: ` + a + `"`
+		case "":
+		default:
+			return nil, fmt.Errorf("invalid alignment %q", a)
+		}
+	}
+	return aligns, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
+
+func anyNumber(row []string) bool {
+	for _, s := range row {
+		if isNumber(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// numericColumn reports whether the non-empty cells of column c are
+// all numbers, and there is at least one.
+func numericColumn(rows [][]string, c int) bool {
+	found := false
+	for _, row := range rows {
+		s := cell(row, c)
+		if s == "" {
+			continue
+		}
+		if !isNumber(s) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
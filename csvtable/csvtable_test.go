@@ -0,0 +1,73 @@
+package csvtable_test
+
+import (
+	"bytes"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/csvtable"
+	"github.com/yuin/goldmark"
+)
+
+func TestPipe(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"csv": csvtable.Pipe(csvtable.Options{}),
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "AutoHeader",
+			Input: "```csv\nFruit, Count\n<apples>, 3\npears\n```\n",
+			Want: "<table class=\"csv\">\n<thead>\n" +
+				"<tr><th>Fruit</th><th style=\"text-align: right\">Count</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr><td>&lt;apples&gt;</td><td style=\"text-align: right\">3</td></tr>\n" +
+				"<tr><td>pears</td><td style=\"text-align: right\"></td></tr>\n" +
+				"</tbody>\n</table>\n",
+		},
+		{
+			Name:  "NoHeader",
+			Input: "```csv\n1,2\n```\n",
+			Want: "<table class=\"csv\">\n<tbody>\n" +
+				"<tr><td style=\"text-align: right\">1</td><td style=\"text-align: right\">2</td></tr>\n" +
+				"</tbody>\n</table>\n",
+		},
+		{
+			Name:  "Options",
+			Input: "```csv header=false align=center delimiter=; sortable\na;b\n```\n",
+			Want: "<table class=\"csv sortable\">\n<tbody>\n" +
+				"<tr><td style=\"text-align: center\">a</td><td>b</td></tr>\n" +
+				"</tbody>\n</table>\n",
+		},
+		{
+			Name:  "Sortable",
+			Input: "```csv sortable\nName,Size\nx,1\n```\n",
+			Want: "<table class=\"csv sortable\">\n<thead>\n" +
+				"<tr><th data-sort=\"text\">Name</th><th data-sort=\"number\" style=\"text-align: right\">Size</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr><td>x</td><td style=\"text-align: right\">1</td></tr>\n" +
+				"</tbody>\n</table>\n",
+		},
+		{
+			Name:  "InvalidAlignment",
+			Input: "```csv align=middle\na\n```\n",
+			Want: "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">" +
+				"fenced block transformer &quot;csv&quot;: invalid alignment &quot;middle&quot;</pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
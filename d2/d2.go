@@ -0,0 +1,144 @@
+// Package d2 provides a pipefence pipe for D2 diagrams.
+//
+// The diagrams are rendered in-process with the D2 Go libraries, so
+// no external binaries are needed.  This package is a separate
+// module, so that users of pipefence who do not need it do not
+// depend on the D2 libraries.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"d2": d2.Pipe(d2.Options{Layout: "elk"}),
+//		},
+//	}
+//
+// The options can be overridden per block:
+//
+//	```d2 theme=200 layout=dagre sketch
+//	x -> y
+//	```
+package d2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"cdr.dev/slog"
+	"cdr.dev/slog/sloggers/sloghuman"
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"oss.terrastruct.com/d2/d2graph"
+	"oss.terrastruct.com/d2/d2layouts/d2dagrelayout"
+	"oss.terrastruct.com/d2/d2layouts/d2elklayout"
+	"oss.terrastruct.com/d2/d2lib"
+	"oss.terrastruct.com/d2/d2renderers/d2svg"
+	"oss.terrastruct.com/d2/lib/log"
+	"oss.terrastruct.com/d2/lib/textmeasure"
+)
+
+// Options configure the rendering of D2 diagrams.
+type Options struct {
+	// Theme is the D2 theme ID, e.g. 200 for "Dark Mauve".  It can
+	// be overridden with the theme option of a block.
+	Theme int64
+
+	// Layout is the layout engine, "dagre" or "elk".  If empty,
+	// "dagre" is used.  It can be overridden with the layout option
+	// of a block.
+	Layout string
+
+	// Sketch renders diagrams in a hand-drawn style.  It can be
+	// overridden with the sketch option of a block.
+	Sketch bool
+
+	// Pad is the padding around the diagram in pixels.
+	Pad int64
+}
+
+// Pipe returns a pipe function which renders D2 diagrams to SVG.
+func Pipe(opts Options) pipefence.PipeFuncCtx {
+	var (
+		mu    sync.Mutex
+		ruler *textmeasure.Ruler
+	)
+	return func(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		o, err := blockOptions(opts, info)
+		if err != nil {
+			return nil, err
+		}
+		layout, err := layoutFunc(o.Layout)
+		if err != nil {
+			return nil, err
+		}
+
+		// The ruler measures text with the bundled fonts; it is
+		// expensive to create and not safe for concurrent use.
+		mu.Lock()
+		defer mu.Unlock()
+		if ruler == nil {
+			ruler, err = textmeasure.NewRuler()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		renderOpts := &d2svg.RenderOpts{
+			Pad:     &o.Pad,
+			Sketch:  &o.Sketch,
+			ThemeID: &o.Theme,
+		}
+		compileOpts := &d2lib.CompileOptions{
+			LayoutResolver: func(string) (d2graph.LayoutGraph, error) { return layout, nil },
+			Ruler:          ruler,
+		}
+		diagram, _, err := d2lib.Compile(log.With(ctx, discardLogger), string(src), compileOpts, renderOpts)
+		if err != nil {
+			return nil, err
+		}
+		return d2svg.Render(diagram, renderOpts)
+	}
+}
+
+// discardLogger silences the diagnostics of the D2 libraries, which
+// expect a logger in the context.
+var discardLogger = slog.Make(sloghuman.Sink(io.Discard))
+
+// blockOptions returns opts with the overrides from the options of
+// the block.
+func blockOptions(opts Options, info pipefence.Info) (Options, error) {
+	if v, ok := info.Options["theme"]; ok {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid theme %q: %w", v, err)
+		}
+		opts.Theme = id
+	}
+	if v, ok := info.Options["layout"]; ok {
+		opts.Layout = v
+	}
+	if v, ok := info.Options["sketch"]; ok {
+		sketch, err := strconv.ParseBool(v)
+		if v == "" {
+			sketch, err = true, nil
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid sketch option %q: %w", v, err)
+		}
+		opts.Sketch = sketch
+	}
+	return opts, nil
+}
+
+// layoutFunc returns the layout engine of the given name.
+func layoutFunc(name string) (d2graph.LayoutGraph, error) {
+	switch name {
+	case "", "dagre":
+		return d2dagrelayout.DefaultLayout, nil
+	case "elk":
+		return d2elklayout.DefaultLayout, nil
+	}
+	return nil, fmt.Errorf("unknown layout engine %q", name)
+}
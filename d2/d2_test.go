@@ -0,0 +1,49 @@
+package d2
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+func TestBlockOptions(t *testing.T) {
+	defaults := Options{Theme: 1, Layout: "elk"}
+	for _, tt := range []struct {
+		Name    string
+		Options map[string]string
+		Want    Options
+		WantErr bool
+	}{
+		{Name: "Defaults", Want: defaults},
+		{
+			Name:    "Overrides",
+			Options: map[string]string{"theme": "200", "layout": "dagre", "sketch": ""},
+			Want:    Options{Theme: 200, Layout: "dagre", Sketch: true},
+		},
+		{Name: "SketchFalse", Options: map[string]string{"sketch": "false"}, Want: defaults},
+		{Name: "BadTheme", Options: map[string]string{"theme": "dark"}, WantErr: true},
+		{Name: "BadSketch", Options: map[string]string{"sketch": "maybe"}, WantErr: true},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := blockOptions(defaults, pipefence.Info{Options: tt.Options})
+			if (err != nil) != tt.WantErr {
+				t.Fatalf("blockOptions: err = %v, want error: %v", err, tt.WantErr)
+			}
+			if err == nil && got != tt.Want {
+				t.Errorf("blockOptions = %+v, want %+v", got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestPipe(t *testing.T) {
+	out, err := Pipe(Options{})(context.Background(), []byte("x -> y\n"), pipefence.Info{})
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	if !bytes.Contains(out, []byte("<svg")) {
+		t.Errorf("Pipe output is not an SVG: %q", out)
+	}
+}
@@ -0,0 +1,139 @@
+package pipefence
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"sync"
+)
+
+// flightGroup coalesces concurrent pipe invocations with the same
+// key, so that the pipe function only runs once.
+//
+// The zero value is ready to use.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	res  pipeResult
+	err  error
+
+	// shared is whether the result may be passed to the waiting
+	// invocations, which is not the case if the invocation failed
+	// because its context was done.
+	shared bool
+}
+
+// do invokes fn, unless an invocation with the same key is already
+// running, in which case it waits for that and returns its result.
+// If the running invocation fails because its context is done, fn
+// is invoked again instead.  ctx is the context passed to fn.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (pipeResult, error)) (pipeResult, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	for {
+		c, ok := g.calls[key]
+		if !ok {
+			break
+		}
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			if c.shared {
+				return c.res, c.err
+			}
+		case <-ctx.Done():
+			return pipeResult{}, ctx.Err()
+		}
+		g.mu.Lock()
+	}
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.res, c.err = fn()
+	c.shared = c.err == nil || ctx.Err() == nil
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+	return c.res, c.err
+}
+
+// dedupe groups the jobs by cache key, keeping code spans apart from
+// blocks, as they have pipe functions of their own and render
+// differently.  It returns the jobs which need to run, and for each
+// job which does not, the job it duplicates.
+func dedupe(jobs []*job) (leaders []*job, dups map[*job]*job) {
+	dups = make(map[*job]*job)
+	byKey := make(map[string]*job)
+	for _, j := range jobs {
+		if j.err != nil {
+			leaders = append(leaders, j)
+			continue
+		}
+		key := cacheKey(j.info, j.content)
+		if j.inline {
+			key = "inline:" + key
+		}
+		if l, ok := byKey[key]; ok {
+			dups[j] = l
+			continue
+		}
+		byKey[key] = j
+		leaders = append(leaders, j)
+	}
+	return leaders, dups
+}
+
+var (
+	svgIDRE   = regexp.MustCompile(`\sid="([^"]*)"`)
+	svgSizeRE = regexp.MustCompile(`\s(?:width|height|viewBox)="[^"]*"`)
+	svgNSRE   = regexp.MustCompile(`\sxmlns="[^"]*"`)
+)
+
+// svgUseReference rewrites the SVG output of a duplicated block.
+// It returns the output for the first occurrence, which gets an id
+// if it has none, and a small SVG referencing it with <use> for the
+// following occurrences.  If svg is not an SVG, ok is false.
+func svgUseReference(svg []byte, id string) (first, ref []byte, ok bool) {
+	loc := SVGRoot(svg)
+	if loc == nil {
+		return nil, nil, false
+	}
+	root := svg[loc[0]:loc[1]]
+
+	if m := svgIDRE.FindSubmatch(root); m != nil {
+		id = string(m[1])
+		first = svg
+	} else {
+		var buf bytes.Buffer
+		buf.Write(svg[:loc[0]])
+		buf.WriteString(`<svg id="`)
+		buf.WriteString(id)
+		buf.WriteString(`"`)
+		buf.Write(root[len("<svg"):])
+		buf.Write(svg[loc[1]:])
+		first = buf.Bytes()
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<svg")
+	for _, attr := range svgSizeRE.FindAll(root, -1) {
+		buf.Write(attr)
+	}
+	if ns := svgNSRE.Find(root); ns != nil {
+		buf.Write(ns)
+	}
+	buf.WriteString(`><use href="#`)
+	buf.WriteString(id)
+	buf.WriteString(`"/></svg>`)
+	buf.WriteString("\n")
+	return first, buf.Bytes(), true
+}
@@ -0,0 +1,188 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestDeduplicate(t *testing.T) {
+	var calls atomic.Int32
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				calls.Add(1)
+				return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+			},
+		},
+		Deduplicate: true,
+		Workers:     4,
+	}))
+
+	input := strings.Repeat("```banana\nfoo\n```\n", 5) + "```banana\nboo\n```\n"
+	want := strings.Repeat("faa\n", 5) + "baa\n"
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("PipeFunc called %d times, want 2", got)
+	}
+}
+
+func TestSVGUseReferences(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"svg": func(a []byte) ([]byte, error) {
+				return []byte(`<svg width="10" height="20" xmlns="http://www.w3.org/2000/svg"><rect/></svg>` + "\n"), nil
+			},
+		},
+		Deduplicate:      true,
+		SVGUseReferences: true,
+	}))
+
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte("```svg\nx\n```\n\n```svg\nx\n```\n"), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("gmark.Convert() = %q, want two lines", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], `<svg id="pipefence-`) || !strings.HasSuffix(lines[0], "<rect/></svg>") {
+		t.Errorf("first occurrence = %q, want SVG with id", lines[0])
+	}
+	id := strings.SplitN(lines[0], `"`, 3)[1]
+	want := `<svg width="10" height="20" xmlns="http://www.w3.org/2000/svg"><use href="#` + id + `"/></svg>`
+	if lines[1] != want {
+		t.Errorf("second occurrence = %q, want %q", lines[1], want)
+	}
+}
+
+func TestDeduplicateInline(t *testing.T) {
+	for _, tt := range []struct {
+		Name        string
+		Deduplicate bool
+		Cache       pipefence.Cache
+	}{
+		{Name: "Deduplicate", Deduplicate: true},
+		{Name: "Cache", Cache: &pipefence.MemoryCache{}},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var calls atomic.Int32
+			tagged := func(tag string) pipefence.PipeFuncCtx {
+				return func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+					calls.Add(1)
+					return []byte(tag + ":" + strings.TrimSpace(string(src))), nil
+				}
+			}
+			gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+				PipeFuncsCtx:    map[string]pipefence.PipeFuncCtx{"icon": tagged("block")},
+				InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{"icon": tagged("span")},
+				Deduplicate:     tt.Deduplicate,
+				Cache:           tt.Cache,
+			}))
+
+			// Empty blocks and code spans have the same content.
+			input := "```icon\n```\n\n`icon:` and `icon:`\n\n```icon\n```\n"
+			want := "block:<p>span: and span:</p>\nblock:"
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+			}
+			if got := calls.Load(); got != 2 {
+				t.Errorf("pipe functions called %d times, want 2", got)
+			}
+		})
+	}
+}
+
+func TestDeduplicateConversions(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"slow": func(ctx context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				calls.Add(1)
+				started <- struct{}{}
+				select {
+				case <-release:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+				pipefence.RequireAssets(ctx, "slow.css")
+				pipefence.Warnf(ctx, "slow")
+				return src, nil
+			},
+		},
+		Deduplicate: true,
+		FailureTTL:  time.Minute,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	type result struct {
+		out string
+		pc  parser.Context
+		err error
+	}
+	convert := func(ctx context.Context) <-chan result {
+		ch := make(chan result, 1)
+		go func() {
+			pc := parser.NewContext()
+			pipefence.WithContext(pc, ctx)
+			var buf bytes.Buffer
+			err := gmark.Convert([]byte("```slow\nx\n```\n"), &buf, parser.WithContext(pc))
+			ch <- result{buf.String(), pc, err}
+		}()
+		return ch
+	}
+
+	// The second conversion waits for the first, which is cancelled,
+	// and then invokes the pipe itself.  The third conversion waits
+	// for the second.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := convert(ctx)
+	<-started
+	second := convert(context.Background())
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-first
+	<-started
+	third := convert(context.Background())
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for _, r := range []result{<-second, <-third} {
+		if r.err != nil {
+			t.Errorf("gmark.Convert: %v", r.err)
+		}
+		if r.out != "x\n" {
+			t.Errorf("gmark.Convert() = %q, want %q", r.out, "x\n")
+		}
+		if got, want := ext.RequiredAssets(r.pc), []string{"slow.css"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("RequiredAssets() = %q, want %q", got, want)
+		}
+		if ws := ext.Warnings(r.pc); len(ws) != 1 || ws[0].Message != "slow" {
+			t.Errorf("Warnings() = %v, want the warning of the pipe", ws)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("pipe function called %d times, want 2", got)
+	}
+}
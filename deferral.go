@@ -0,0 +1,45 @@
+package pipefence
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// Deferral configures the client-side rendering of a language.
+//
+// For example, mermaid diagrams can be left to the mermaid
+// JavaScript library with:
+//
+//	Defer: map[string]pipefence.Deferral{
+//		"mermaid": {
+//			Open:   `<div class="mermaid">`,
+//			Close:  "</div>",
+//			Assets: []string{"https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.min.js"},
+//		},
+//	}
+type Deferral struct {
+	// Open and Close enclose the HTML-escaped block content.  If
+	// Open is empty, the content is enclosed in a <pre> element
+	// with the language as its class.
+	Open, Close string
+
+	// Assets are the URLs of the client libraries which render the
+	// blocks, as reported by RequiredAssets.
+	Assets []string
+}
+
+// shell returns the HTML for a deferred block.
+func (d Deferral) shell(lang string, content []byte) []byte {
+	start, end := d.Open, d.Close
+	if start == "" {
+		start = `<pre class="` + string(util.EscapeHTML([]byte(lang))) + `">`
+		end = "</pre>"
+	}
+	var buf bytes.Buffer
+	buf.WriteString(start)
+	buf.Write(util.EscapeHTML(content))
+	buf.WriteString(end)
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,63 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestDefer(t *testing.T) {
+	called := false
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"mermaid": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				called = true
+				return nil, nil
+			},
+		},
+		Defer: map[string]pipefence.Deferral{
+			"mermaid": {
+				Open:   `<div class="mermaid">`,
+				Close:  "</div>",
+				Assets: []string{"mermaid.js"},
+			},
+			"abc": {
+				Assets: []string{"abcjs.js", "abcjs.css"},
+			},
+		},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	pc := parser.NewContext()
+	input := "```mermaid\nA-->B\n```\n\n```abc\nX:1\n```\n\n```mermaid\nC\n```\n"
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	want := "<div class=\"mermaid\">A--&gt;B\n</div>\n" +
+		"<pre class=\"abc\">X:1\n</pre>\n" +
+		"<div class=\"mermaid\">C\n</div>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+	if called {
+		t.Errorf("pipe function of deferred language was called")
+	}
+	if got, want := ext.RequiredAssets(pc), []string{"mermaid.js", "abcjs.js", "abcjs.css"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RequiredAssets() = %q, want %q", got, want)
+	}
+
+	// Conversions without deferred blocks need no assets.
+	pc = parser.NewContext()
+	if err := gmark.Convert([]byte("```go\nx\n```\n"), &buf, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got := ext.RequiredAssets(pc); got != nil {
+		t.Errorf("RequiredAssets() = %q, want nil", got)
+	}
+}
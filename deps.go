@@ -0,0 +1,84 @@
+package pipefence
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/parser"
+)
+
+// DependOn declares that the output of the running pipe depends on
+// the given files, such as configuration files or files included by
+// the block content.  They are reported by Dependencies.  Pipe
+// functions call it with the context they were passed; outside of
+// pipe functions it does nothing.  As with RequireAssets, the
+// dependencies are stored in the Cache together with the output.
+func DependOn(ctx context.Context, paths ...string) {
+	r, ok := ctx.Value(requirementsKey{}).(*requirements)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deps = append(r.deps, paths...)
+}
+
+// dependenciesKey is the parser.Context key for the dependencies of
+// a conversion.
+var dependenciesKey = parser.NewContextKey()
+
+// addDependencies records the files the given jobs depend on: the
+// files included with the src option, the binaries of the Tools of
+// their languages, and the files declared with DependOn.
+func (e *Extension) addDependencies(pc parser.Context, jobs []*job) {
+	deps, _ := pc.Get(dependenciesKey).([]string)
+	for _, j := range jobs {
+		for _, p := range j.deps {
+			if abs, err := filepath.Abs(p); err == nil {
+				p = abs
+			}
+			deps = append(deps, p)
+		}
+		for _, stage := range strings.Split(j.info.Language, "|") {
+			tool, ok := e.Tools[strings.TrimSpace(stage)]
+			if !ok {
+				continue
+			}
+			if path, err := exec.LookPath(tool.Command); err == nil {
+				if abs, err := filepath.Abs(path); err == nil {
+					path = abs
+				}
+				deps = append(deps, path)
+			}
+		}
+	}
+	if deps == nil {
+		return
+	}
+	sort.Strings(deps)
+	pc.Set(dependenciesKey, slices.Compact(deps))
+}
+
+// Dependencies returns the files which the outputs of a conversion
+// depend on, sorted and as absolute paths, so that build systems can
+// convert the document again when one of them changes.  They are the
+// files included with the src option, the binaries of the Tools of
+// the languages used, and the files which pipes declared with
+// DependOn.  It is called after the conversion, with its
+// parser.Context:
+//
+//	pc := parser.NewContext()
+//	err := md.Convert(src, w, parser.WithContext(pc))
+//	deps := ext.Dependencies(pc)
+//
+// With WithPlaceholders, the files declared by pipes which run in
+// RunPending are not included.
+func (e *Extension) Dependencies(pc parser.Context) []string {
+	deps, _ := pc.Get(dependenciesKey).([]string)
+	return deps
+}
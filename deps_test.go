@@ -0,0 +1,62 @@
+package pipefence_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestDependencies(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	included := filepath.Join(root, "arch.dot")
+	if err := os.WriteFile(included, []byte("digraph {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("cat not found")
+	}
+	cat, _ = filepath.Abs(cat)
+	config := filepath.Join(root, "style.conf")
+
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"dot": func(ctx context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				pipefence.DependOn(ctx, config)
+				return src, nil
+			},
+			"cat":   pipefence.ExecPipe("cat"),
+			"plain": pipefence.ExecPipe("cat"),
+		},
+		Tools:       map[string]pipefence.Tool{"cat": {Command: "cat"}},
+		IncludeRoot: root,
+		Cache:       &pipefence.MemoryCache{},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	// The second conversion takes the outputs from the Cache.
+	for i := 0; i < 2; i++ {
+		pc := parser.NewContext()
+		src := "```dot src=arch.dot\n```\n\n```cat\nx\n```\n\n```plain\ny\n```\n"
+		if err := gmark.Convert([]byte(src), io.Discard, parser.WithContext(pc)); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		want := []string{cat, config, included}
+		sort.Strings(want)
+		if got := ext.Dependencies(pc); !reflect.DeepEqual(got, want) {
+			t.Errorf("conversion %d: Dependencies() = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package pipefence
+
+import (
+	"bytes"
+	"strings"
+)
+
+// mermaidKeywords are the first words of Mermaid diagrams.
+var mermaidKeywords = []string{
+	"sequenceDiagram", "classDiagram", "stateDiagram", "stateDiagram-v2",
+	"erDiagram", "flowchart", "gantt", "pie", "journey", "gitGraph",
+	"mindmap", "timeline", "quadrantChart", "requirementDiagram",
+}
+
+// DetectDiagram is a detector for Extension.Detect, which recognizes
+// diagrams by their first line: "dot" for Graphviz graphs, "plantuml"
+// for diagrams starting with @startuml and similar, and "mermaid" for
+// Mermaid diagrams.  Other contents yield "".  Use Aliases if the
+// pipes are registered under other names.
+func DetectDiagram(src []byte) string {
+	var first string
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		if l := strings.TrimSpace(string(line)); l != "" && !strings.HasPrefix(l, "%%") {
+			first = l
+			break
+		}
+	}
+	fields := strings.Fields(first)
+	if len(fields) == 0 {
+		return ""
+	}
+	word, _, _ := strings.Cut(fields[0], "{")
+	switch {
+	case strings.HasPrefix(word, "@start"):
+		return "plantuml"
+	case word == "digraph" || word == "strict":
+		return "dot"
+	case word == "graph" && strings.HasSuffix(first, "{"):
+		return "dot"
+	case word == "graph":
+		// Mermaid flowcharts, such as "graph TD".
+		return "mermaid"
+	}
+	for _, k := range mermaidKeywords {
+		if word == k {
+			return "mermaid"
+		}
+	}
+	return ""
+}
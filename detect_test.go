@@ -0,0 +1,83 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestDetectDiagram(t *testing.T) {
+	for _, tt := range []struct {
+		Src  string
+		Want string
+	}{
+		{Src: "digraph { a -> b }\n", Want: "dot"},
+		{Src: "digraph{a->b}\n", Want: "dot"},
+		{Src: "strict graph G {\n}\n", Want: "dot"},
+		{Src: "\ngraph G {\n  a -- b\n}\n", Want: "dot"},
+		{Src: "graph TD\n  A --> B\n", Want: "mermaid"},
+		{Src: "%% comment\nsequenceDiagram\n  A->>B: hi\n", Want: "mermaid"},
+		{Src: "@startuml\nA -> B\n@enduml\n", Want: "plantuml"},
+		{Src: "@startmindmap\n* root\n@endmindmap\n", Want: "plantuml"},
+		{Src: "func main() {}\n", Want: ""},
+		{Src: "", Want: ""},
+	} {
+		if got := pipefence.DetectDiagram([]byte(tt.Src)); got != tt.Want {
+			t.Errorf("DetectDiagram(%q) = %q, want %q", tt.Src, got, tt.Want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tagged := func(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		return []byte(info.Language + ":" + string(src)), nil
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"graphviz": tagged,
+			"mermaid":  tagged,
+		},
+		Aliases: map[string]string{"dot": "graphviz"},
+		Detect:  pipefence.DetectDiagram,
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Aliased",
+			Input: "```\ndigraph {}\n```\n",
+			Want:  "graphviz:digraph {}\n",
+		},
+		{
+			Name:  "Mermaid",
+			Input: "```\ngraph LR\n```\n",
+			Want:  "mermaid:graph LR\n",
+		},
+		{
+			Name:  "Undetected",
+			Input: "```\nplain\n```\n",
+			Want:  "<pre><code>plain\n</code></pre>\n",
+		},
+		{
+			Name:  "ExplicitLanguage",
+			Input: "```text\ndigraph {}\n```\n",
+			Want:  "<pre><code class=\"language-text\">digraph {}\n</code></pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
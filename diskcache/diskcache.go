@@ -0,0 +1,278 @@
+// Package diskcache provides a pipefence.Cache which stores pipe
+// outputs as files in a directory.
+//
+// The cache directory can be kept between builds (e.g. as a CI
+// artifact), so that unchanged diagrams do not need to be rendered
+// again.  It can be shared by several build processes running at the
+// same time, such as the workers of a parallel static site build:
+// entries are written atomically, and the processes coordinate
+// through a lock file in the directory, so that garbage collections
+// do not overlap with each other or with writes.
+package diskcache
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Cache is a pipefence.StatsCache backed by a directory.
+//
+// Entries are stored in files named after their keys.  When the
+// total size of the entries exceeds MaxBytes, the least recently
+// used entries are removed.  Long-running services can additionally
+// call GC periodically, e.g. to remove entries which have not been
+// used for a while.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64 // Approximate total size of entries.
+	stats pipefence.CacheStats
+}
+
+// New returns a Cache storing its entries in dir, which is created
+// if needed.  If maxBytes is positive, the cache is garbage
+// collected when it grows beyond that size.
+func New(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &Cache{dir: dir, maxBytes: maxBytes}
+	entries, err := c.entries(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		c.size += e.size
+	}
+	return c, nil
+}
+
+// path returns the file name for key, or "" if key is not a valid
+// cache key.
+func (c *Cache) path(key string) string {
+	if len(key) < 3 || strings.ContainsAny(key, `/\.`) {
+		return ""
+	}
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Get implements pipefence.Cache.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	p := c.path(key)
+	if p == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(p)
+	c.mu.Lock()
+	if err != nil {
+		c.stats.Misses++
+	} else {
+		c.stats.Hits++
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, false
+	}
+	// Record the use for garbage collection; errors are harmless.
+	now := time.Now()
+	os.Chtimes(p, now, now)
+	return data, true
+}
+
+// Set implements pipefence.Cache.
+//
+// Errors are ignored, as a failure to store an entry only means that
+// it needs to be rendered again.
+func (c *Cache) Set(key string, value []byte) {
+	p := c.path(key)
+	if p == "" {
+		return
+	}
+	if !write(p, value, c.lockPath()) {
+		return
+	}
+
+	c.mu.Lock()
+	c.size += int64(len(value))
+	full := c.maxBytes > 0 && c.size > c.maxBytes
+	c.mu.Unlock()
+
+	if full {
+		c.GC(0, c.maxBytes)
+	}
+}
+
+// write atomically writes value to the file p, holding a shared lock
+// on the lock file, and reports whether it succeeded.
+func write(p string, value []byte, lockPath string) bool {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return false
+	}
+	unlock, err := lock(lockPath, false)
+	if err != nil {
+		return false
+	}
+	defer unlock()
+
+	// Write to a temporary file first, so that readers never see
+	// partially written entries.
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return false
+	}
+	_, werr := f.Write(value)
+	cerr := f.Close()
+	if werr != nil || cerr != nil {
+		os.Remove(f.Name())
+		return false
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		os.Remove(f.Name())
+		return false
+	}
+	return true
+}
+
+// lockPath returns the path of the lock file coordinating the
+// processes using the cache.
+func (c *Cache) lockPath() string {
+	return filepath.Join(c.dir, ".lock")
+}
+
+// staleTemp is the age from which temporary files are considered
+// left over by crashed processes.
+const staleTemp = time.Hour
+
+type entry struct {
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+// entries lists all cache entries.  With removeTemp, it removes
+// temporary files which are older than staleTemp.
+func (c *Cache) entries(removeTemp bool) ([]entry, error) {
+	var entries []entry
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if removeTemp && strings.HasPrefix(d.Name(), ".tmp-") {
+				if fi, err := d.Info(); err == nil && time.Since(fi.ModTime()) > staleTemp {
+					os.Remove(path)
+				}
+			}
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{path: path, size: fi.Size(), mtime: fi.ModTime()})
+		return nil
+	})
+	return entries, err
+}
+
+// GC removes the entries which have not been used for maxAge, and
+// then the least recently used entries until the total size is at
+// most maxBytes, as well as temporary files left over by crashed
+// processes.  Zero values mean no limit.
+func (c *Cache) GC(maxAge time.Duration, maxBytes int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unlock, err := lock(c.lockPath(), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := c.entries(true)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].mtime.Before(entries[j].mtime)
+	})
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		expired := maxAge > 0 && e.mtime.Before(cutoff)
+		if !expired && (maxBytes <= 0 || size <= maxBytes) {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			c.size = size
+			return err
+		}
+		size -= e.size
+	}
+	c.size = size
+	return nil
+}
+
+// Stats implements pipefence.StatsCache.  The hits and misses are
+// those of this Cache, while the entries and bytes are those in the
+// directory, which may be shared with other processes.
+func (c *Cache) Stats() pipefence.CacheStats {
+	entries, _ := c.entries(false)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(entries)
+	for _, e := range entries {
+		stats.Bytes += e.size
+	}
+	return stats
+}
+
+// Purge removes the outputs of blocks in lang, e.g. after fixing a
+// bug in its pipe, and returns their number.
+func (c *Cache) Purge(lang string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unlock, err := lock(c.lockPath(), true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := c.entries(false)
+	if err != nil {
+		return 0, err
+	}
+	prefix := pipefence.CacheKeyPrefix(lang)
+	n := 0
+	for _, e := range entries {
+		if !strings.HasPrefix(filepath.Base(e.path), prefix) {
+			continue
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return n, err
+		}
+		c.size -= e.size
+		n++
+	}
+	return n, nil
+}
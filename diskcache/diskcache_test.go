@@ -0,0 +1,214 @@
+package diskcache_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/diskcache"
+	"github.com/yuin/goldmark"
+)
+
+var _ pipefence.Cache = (*diskcache.Cache)(nil)
+
+func TestGetSet(t *testing.T) {
+	dir := t.TempDir()
+	c, err := diskcache.New(dir, 0)
+	if err != nil {
+		t.Fatalf("diskcache.New: %v", err)
+	}
+
+	if _, ok := c.Get("abcdef"); ok {
+		t.Errorf("Get(abcdef) on empty cache: ok = true, want false")
+	}
+	c.Set("abcdef", []byte("<svg/>"))
+
+	// A second Cache on the same directory sees the entry.
+	c2, err := diskcache.New(dir, 0)
+	if err != nil {
+		t.Fatalf("diskcache.New: %v", err)
+	}
+	got, ok := c2.Get("abcdef")
+	if !ok || string(got) != "<svg/>" {
+		t.Errorf("Get(abcdef) = %q, %v, want %q, true", got, ok, "<svg/>")
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	c, err := diskcache.New(filepath.Join(dir, "cache"), 0)
+	if err != nil {
+		t.Fatalf("diskcache.New: %v", err)
+	}
+
+	c.Set("../../escape", []byte("x"))
+	if _, err := os.Stat(filepath.Join(dir, "escape")); !os.IsNotExist(err) {
+		t.Errorf("Set with path traversal key created a file outside the cache")
+	}
+}
+
+func TestGC(t *testing.T) {
+	c, err := diskcache.New(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("diskcache.New: %v", err)
+	}
+
+	c.Set("aaaa", []byte("12345"))
+	time.Sleep(10 * time.Millisecond)
+	c.Set("bbbb", []byte("12345"))
+	time.Sleep(10 * time.Millisecond)
+	c.Get("aaaa") // aaaa is now more recently used than bbbb.
+	time.Sleep(10 * time.Millisecond)
+	c.Set("cccc", []byte("12345"))
+
+	if _, ok := c.Get("bbbb"); ok {
+		t.Errorf("Get(bbbb) after GC: ok = true, want false")
+	}
+	for _, key := range []string{"aaaa", "cccc"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("Get(%s) after GC: ok = false, want true", key)
+		}
+	}
+}
+
+func TestSharedDirectory(t *testing.T) {
+	// Several Caches on one directory behave like several build
+	// processes sharing it.
+	dir := t.TempDir()
+	value := bytes.Repeat([]byte("x"), 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		c, err := diskcache.New(dir, 5000)
+		if err != nil {
+			t.Fatalf("diskcache.New: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 50; k++ {
+				key := fmt.Sprintf("key%03d", k)
+				c.Set(key, value)
+				if got, ok := c.Get(key); ok && !bytes.Equal(got, value) {
+					t.Errorf("Get(%s) returned a corrupt entry of %d bytes", key, len(got))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	c, err := diskcache.New(dir, 5000)
+	if err != nil {
+		t.Fatalf("diskcache.New: %v", err)
+	}
+	if err := c.GC(0, 5000); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && d.Name() != ".lock" {
+			fi, _ := d.Info()
+			size += fi.Size()
+		}
+		return nil
+	})
+	if size > 5000 {
+		t.Errorf("cache size after GC = %d, want at most 5000", size)
+	}
+}
+
+func TestGCRemovesStaleTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	c, err := diskcache.New(dir, 0)
+	if err != nil {
+		t.Fatalf("diskcache.New: %v", err)
+	}
+	c.Set("abcd", []byte("x"))
+
+	stale := filepath.Join(dir, "ab", ".tmp-stale")
+	fresh := filepath.Join(dir, "ab", ".tmp-fresh")
+	for _, p := range []string{stale, fresh} {
+		if err := os.WriteFile(p, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(stale, old, old)
+
+	if err := c.GC(0, 0); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale temporary file not removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("fresh temporary file removed: %v", err)
+	}
+	if _, ok := c.Get("abcd"); !ok {
+		t.Errorf("Get(abcd) after GC: ok = false, want true")
+	}
+}
+
+func TestGCMaxAge(t *testing.T) {
+	c, err := diskcache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("diskcache.New: %v", err)
+	}
+	c.Set("aaaa", []byte("old"))
+	c.Set("bbbb", []byte("new"))
+	// Entries are dated by their last use.
+	time.Sleep(50 * time.Millisecond)
+	c.Get("bbbb")
+
+	if err := c.GC(25*time.Millisecond, 0); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if _, ok := c.Get("aaaa"); ok {
+		t.Errorf("Get(aaaa) after GC: ok = true, want false")
+	}
+	if _, ok := c.Get("bbbb"); !ok {
+		t.Errorf("Get(bbbb) after GC: ok = false, want true")
+	}
+}
+
+func TestStatsAndPurge(t *testing.T) {
+	c, err := diskcache.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("diskcache.New: %v", err)
+	}
+	ext := &pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"dot":  func(src []byte) ([]byte, error) { return src, nil },
+			"math": func(src []byte) ([]byte, error) { return src, nil },
+		},
+		Cache: c,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+	input := "```dot\na\n```\n\n```dot\nbb\n```\n\n```math\nccc\n```\n"
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(input), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+	}
+
+	want := pipefence.CacheStats{Hits: 3, Misses: 3, Entries: 3, Bytes: 9}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if got := c.Stats().HitRate(); got != 0.5 {
+		t.Errorf("HitRate() = %v, want 0.5", got)
+	}
+
+	if n, err := c.Purge("dot"); n != 2 || err != nil {
+		t.Errorf("Purge(dot) = %d, %v, want 2, nil", n, err)
+	}
+	if got := c.Stats(); got.Entries != 1 || got.Bytes != 4 {
+		t.Errorf("Stats() after Purge = %+v, want 1 entry of 4 bytes", got)
+	}
+}
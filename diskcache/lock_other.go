@@ -0,0 +1,10 @@
+//go:build !unix
+
+package diskcache
+
+// lock does nothing on systems without flock.  Entries are still
+// written atomically, but garbage collections of several processes
+// may overlap.
+func lock(path string, exclusive bool) (unlock func(), err error) {
+	return func() {}, nil
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package diskcache
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock opens the lock file at path and locks it, shared or
+// exclusively.  The returned function releases the lock.
+func lock(path string, exclusive bool) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "flock", Path: path, Err: err}
+	}
+	// Closing the file releases the lock.
+	return func() { f.Close() }, nil
+}
@@ -0,0 +1,42 @@
+package pipefence
+
+import (
+	"github.com/yuin/goldmark/util"
+)
+
+// ErrorMode defines how a failing PipeFunc affects the conversion.
+type ErrorMode int
+
+const (
+	// FailFast aborts the conversion with the pipe's error.
+	FailFast ErrorMode = iota
+
+	// RenderOriginalBlock renders the fenced code block as if it
+	// had no pipe registered, i.e. as a plain <pre><code> block.
+	RenderOriginalBlock
+
+	// RenderErrorInline renders an error box containing the error
+	// message in place of the block's output.
+	RenderErrorInline
+)
+
+// writeOriginalBlock writes the fenced code block content the same
+// way goldmark's default HTML renderer does.
+func writeOriginalBlock(w util.BufWriter, lang string, content []byte) {
+	w.WriteString("<pre><code")
+	if lang != "" {
+		w.WriteString(` class="language-`)
+		w.Write(util.EscapeHTML([]byte(lang)))
+		w.WriteString(`"`)
+	}
+	w.WriteString(">")
+	w.Write(util.EscapeHTML(content))
+	w.WriteString("</code></pre>\n")
+}
+
+// writeErrorBlock writes an error box for the given error.
+func writeErrorBlock(w util.BufWriter, err error) {
+	w.WriteString(`<pre class="pipefence-error" style="border: 1px solid red; color: red; padding: 0.5em">`)
+	w.Write(util.EscapeHTML([]byte(err.Error())))
+	w.WriteString("</pre>\n")
+}
@@ -0,0 +1,270 @@
+package pipefence
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ExecPipe returns a pipe function which runs the given command,
+// writes the fenced code block content to its standard input and
+// returns its standard output.
+//
+// If the command fails, the returned error is an *ExecError, which
+// includes its standard error output.  The command is killed when
+// the context is cancelled.  It inherits the environment and working
+// directory of the current process.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"dot": pipefence.ExecPipe("dot", "-Tsvg"),
+//		},
+//	}
+func ExecPipe(name string, args ...string) PipeFuncCtx {
+	return ExecPipeWith(ExecOptions{InheritEnv: true}, name, args...)
+}
+
+// ExecOptions configure ExecPipeWith.
+type ExecOptions struct {
+	// Container, if set, runs the command inside a container
+	// rather than on the host.
+	Container *Container
+
+	// Sandbox is a command line prefix running the command in a
+	// sandbox, such as
+	//
+	//	[]string{"bwrap", "--ro-bind", "/", "/", "--unshare-all", "--"}
+	//
+	// or a firejail, nsjail or Landlock helper.  The command and
+	// its arguments are appended to it.
+	Sandbox []string
+
+	// RLimits limit the resources of the command.  With a
+	// Container, they apply to the runtime client; limit the
+	// container with Container.Args instead.
+	RLimits RLimits
+
+	// Env is the environment of the command.  Unless InheritEnv is
+	// set, the command gets a clean environment with only these
+	// variables and PATH, so that it behaves the same on all
+	// machines.
+	//
+	// With a Container, only the variables of Env are passed into
+	// the container, and the runtime client additionally gets the
+	// variables it needs to reach the runtime, such as HOME,
+	// DOCKER_HOST and XDG_RUNTIME_DIR.
+	Env map[string]string
+
+	// InheritEnv makes the command inherit the environment of the
+	// current process, with Env taking precedence.  With a
+	// Container, it applies to the runtime client only.
+	InheritEnv bool
+
+	// Dir is the working directory of the command, for tools which
+	// read their configuration from it.  If empty, the command
+	// runs in the working directory of the current process.
+	Dir string
+
+	// ExtraFiles are open files passed to the command as file
+	// descriptors 3, 4 and so on.  They can not be passed into a
+	// Container, and commands with both fail.
+	ExtraFiles []*os.File
+
+	// ArgDefaults are the values of argument placeholders for
+	// blocks which do not set the option.  Blocks without a value
+	// for a placeholder fail.
+	ArgDefaults map[string]string
+
+	// ArgPatterns restrict the values of argument placeholders,
+	// keyed by option name.  The values of other options must match
+	// DefaultArgPattern.
+	ArgPatterns map[string]*regexp.Regexp
+}
+
+// ExecPipeWith is like ExecPipe, but with options controlling how
+// the command is run:
+//
+//	pipefence.ExecPipeWith(pipefence.ExecOptions{
+//		Container: &pipefence.Container{Image: "nshine/dot"},
+//	}, "dot", "-Tsvg")
+//
+// The arguments of ExecPipe and ExecPipeWith may contain placeholders
+// for block options, which are filled in per block, such as
+// "-K{{.layout}}" for a block starting with
+//
+//	```dot layout=neato
+//
+// See ExecOptions.ArgDefaults and ExecOptions.ArgPatterns.
+func ExecPipeWith(opts ExecOptions, name string, args ...string) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+		if opts.Container != nil && len(opts.ExtraFiles) > 0 {
+			return nil, fmt.Errorf("%s: ExtraFiles can not be passed into a Container", name)
+		}
+		args, err := opts.expandArgs(args, info.Options)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		var stdout, stderr bytes.Buffer
+		cmd := opts.command(ctx, name, args)
+		cmd.Stdin = bytes.NewReader(src)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &limitedWriter{w: &stderr, n: maxStderr}
+		if err := cmd.Run(); err != nil {
+			exitCode := -1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitCode = exitErr.ExitCode()
+			}
+			return nil, &ExecError{
+				Command:  append([]string{name}, args...),
+				ExitCode: exitCode,
+				Stderr:   stderr.Bytes(),
+				Err:      err,
+			}
+		}
+		return stdout.Bytes(), nil
+	}
+}
+
+// tool returns the Tool running the command name: the container
+// runtime with a Container, and the command otherwise.
+func (opts ExecOptions) tool(name string) Tool {
+	if opts.Container != nil {
+		return Tool{Command: opts.Container.runtime()}
+	}
+	return Tool{Command: name}
+}
+
+// command returns the command to run name with args.
+func (opts ExecOptions) command(ctx context.Context, name string, args []string) *exec.Cmd {
+	argv := append([]string{name}, args...)
+	var remove func()
+	if opts.Container != nil {
+		argv, remove = opts.Container.wrap(argv, opts.envKeys())
+	}
+	if len(opts.Sandbox) > 0 {
+		argv = append(slices.Clone(opts.Sandbox), argv...)
+	}
+	argv = opts.RLimits.wrap(argv)
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = opts.environ()
+	cmd.Dir = opts.Dir
+	cmd.ExtraFiles = opts.ExtraFiles
+	if remove != nil {
+		cmd.Cancel = func() error {
+			// Killing the client does not necessarily stop
+			// the container.
+			remove()
+			return cmd.Process.Kill()
+		}
+	}
+	return cmd
+}
+
+// runtimeEnv are the variables which container runtime clients need
+// to reach the runtime, e.g. rootless podman or a remote daemon.
+var runtimeEnv = []string{
+	"HOME",
+	"XDG_RUNTIME_DIR",
+	"XDG_CONFIG_HOME",
+	"DOCKER_HOST",
+	"DOCKER_CONFIG",
+	"DOCKER_CONTEXT",
+	"DOCKER_CERT_PATH",
+	"DOCKER_TLS_VERIFY",
+	"CONTAINER_HOST",
+	"CONTAINERS_CONF",
+	"REGISTRY_AUTH_FILE",
+}
+
+// environ returns the environment of the command, or of the runtime
+// client with a Container.
+func (opts ExecOptions) environ() []string {
+	var env []string
+	switch {
+	case opts.InheritEnv:
+		env = os.Environ()
+	case opts.Container != nil:
+		for _, k := range runtimeEnv {
+			if v, ok := os.LookupEnv(k); ok {
+				env = append(env, k+"="+v)
+			}
+		}
+		env = append(env, "PATH="+os.Getenv("PATH"))
+	default:
+		if _, ok := opts.Env["PATH"]; !ok {
+			env = append(env, "PATH="+os.Getenv("PATH"))
+		}
+	}
+	for _, k := range opts.envKeys() {
+		// Later entries take precedence.
+		env = append(env, k+"="+opts.Env[k])
+	}
+	return env
+}
+
+// envKeys returns the names of the variables of Env in order.
+func (opts ExecOptions) envKeys() []string {
+	keys := make([]string, 0, len(opts.Env))
+	for k := range opts.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// maxStderr is the maximum size of the standard error output captured
+// from commands.
+const maxStderr = 64 << 10
+
+// ExecError is returned by exec pipes when the command fails.  Its
+// message includes an excerpt of the standard error output, so that
+// it shows in error boxes with RenderErrorInline.
+type ExecError struct {
+	Command  []string // The command line, without sandbox or container.
+	ExitCode int      // The exit code, or -1 if the command did not exit.
+	Stderr   []byte   // The standard error output, up to 64 KiB.
+	Err      error    // The error from running the command.
+}
+
+func (e *ExecError) Error() string {
+	if excerpt := stderrExcerpt(e.Stderr); excerpt != "" {
+		return fmt.Sprintf("%s: %v: %s", e.Command[0], e.Err, excerpt)
+	}
+	return fmt.Sprintf("%s: %v", e.Command[0], e.Err)
+}
+
+func (e *ExecError) Unwrap() error { return e.Err }
+
+// stderrExcerpt returns the first lines of stderr, trimmed to a size
+// fit for error messages.
+func stderrExcerpt(stderr []byte) string {
+	const (
+		maxLines = 10
+		maxBytes = 1000
+	)
+	msg := string(bytes.TrimSpace(stderr))
+	truncated := false
+	if lines := strings.SplitN(msg, "\n", maxLines+1); len(lines) > maxLines {
+		msg = strings.Join(lines[:maxLines], "\n")
+		truncated = true
+	}
+	if len(msg) > maxBytes {
+		msg = strings.ToValidUTF8(msg[:maxBytes], "")
+		truncated = true
+	}
+	if truncated {
+		msg += " …"
+	}
+	return msg
+}
@@ -0,0 +1,328 @@
+package pipefence_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+func TestExecPipe(t *testing.T) {
+	pipe := pipefence.ExecPipe("tr", "o", "a")
+	got, err := pipe(context.Background(), []byte("foo\n"), pipefence.Info{})
+	if err != nil {
+		t.Fatalf("ExecPipe: %v", err)
+	}
+	if string(got) != "faa\n" {
+		t.Errorf("ExecPipe(tr o a)(foo) = %q, want %q", got, "faa\n")
+	}
+}
+
+func TestExecPipeStderr(t *testing.T) {
+	pipe := pipefence.ExecPipe("sh", "-c", "echo syntax error >&2; exit 1")
+	_, err := pipe(context.Background(), nil, pipefence.Info{})
+	if err == nil || !strings.Contains(err.Error(), "syntax error") {
+		t.Errorf("ExecPipe(failing command): err = %v, want error containing stderr", err)
+	}
+}
+
+func TestExecPipeContainer(t *testing.T) {
+	// The fake runtime prints its arguments, and the input.
+	dir := t.TempDir()
+	runtime := filepath.Join(dir, "runtime")
+	script := "#!/bin/sh\necho \"$@\" >>" + filepath.Join(dir, "log") + "\n" +
+		"if [ \"$1\" = run ]; then\n" +
+		"  case \"$*\" in *sleep*) exec sleep 10;; esac\n" +
+		"  cat\nfi\n"
+	if err := os.WriteFile(runtime, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	pipe := pipefence.ExecPipeWith(pipefence.ExecOptions{
+		Container: &pipefence.Container{Runtime: runtime, Image: "img", Args: []string{"--memory=64m"}},
+	}, "dot", "-Tsvg")
+	got, err := pipe(context.Background(), []byte("digraph {}\n"), pipefence.Info{})
+	if err != nil {
+		t.Fatalf("ExecPipeWith: %v", err)
+	}
+	if string(got) != "digraph {}\n" {
+		t.Errorf("ExecPipeWith() = %q, want input", got)
+	}
+	log, _ := os.ReadFile(filepath.Join(dir, "log"))
+	args := regexp.MustCompile(`pipefence-[0-9a-f]+`).ReplaceAllString(string(log), "ID")
+	want := "run --rm --interactive --name ID --network none --read-only --tmpfs /tmp --cap-drop ALL --security-opt no-new-privileges --memory=64m -- img dot -Tsvg\n"
+	if args != want {
+		t.Errorf("runtime arguments = %q, want %q", args, want)
+	}
+
+	// Cancellation removes the container.
+	os.Remove(filepath.Join(dir, "log"))
+	pipe = pipefence.ExecPipeWith(pipefence.ExecOptions{
+		Container: &pipefence.Container{Runtime: runtime, Image: "img"},
+	}, "sleep")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := pipe(ctx, nil, pipefence.Info{}); err == nil {
+		t.Errorf("ExecPipeWith(sleep) succeeded, want error")
+	}
+	log, _ = os.ReadFile(filepath.Join(dir, "log"))
+	if !regexp.MustCompile(`\nrm --force pipefence-[0-9a-f]+\n$`).Match(log) {
+		t.Errorf("runtime log = %q, want removal of the container", log)
+	}
+}
+
+func TestExecPipeContainerEnvironment(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix:///run/user/1000/podman.sock")
+	t.Setenv("PIPEFENCE_TEST_OUTER", "outer")
+
+	// The fake runtime prints its arguments and the environment
+	// variables which reach it.
+	dir := t.TempDir()
+	runtime := filepath.Join(dir, "runtime")
+	script := "#!/bin/sh\necho \"$@\"\necho \"$DOCKER_HOST $LANG $PIPEFENCE_TEST_OUTER\"\n"
+	if err := os.WriteFile(runtime, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	pipe := pipefence.ExecPipeWith(pipefence.ExecOptions{
+		Container: &pipefence.Container{Runtime: runtime, Image: "img"},
+		Env:       map[string]string{"LANG": "C"},
+	}, "dot")
+	got, err := pipe(context.Background(), nil, pipefence.Info{})
+	if err != nil {
+		t.Fatalf("ExecPipeWith: %v", err)
+	}
+	args := regexp.MustCompile(`pipefence-[0-9a-f]+`).ReplaceAllString(string(got), "ID")
+	want := "run --rm --interactive --name ID --network none --read-only --tmpfs /tmp --cap-drop ALL --security-opt no-new-privileges --env LANG -- img dot\n" +
+		"unix:///run/user/1000/podman.sock C \n"
+	if args != want {
+		t.Errorf("runtime output = %q, want %q", args, want)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	pipe = pipefence.ExecPipeWith(pipefence.ExecOptions{
+		Container:  &pipefence.Container{Runtime: runtime, Image: "img"},
+		ExtraFiles: []*os.File{w},
+	}, "dot")
+	if _, err := pipe(context.Background(), nil, pipefence.Info{}); err == nil || !strings.Contains(err.Error(), "ExtraFiles") {
+		t.Errorf("ExecPipeWith(Container, ExtraFiles): err = %v, want ExtraFiles error", err)
+	}
+}
+
+func TestExecPipeSandbox(t *testing.T) {
+	pipe := pipefence.ExecPipeWith(pipefence.ExecOptions{
+		Sandbox: []string{"env", "SANDBOXED=yes"},
+	}, "sh", "-c", "echo $SANDBOXED")
+	got, err := pipe(context.Background(), nil, pipefence.Info{})
+	if err != nil {
+		t.Fatalf("ExecPipeWith: %v", err)
+	}
+	if string(got) != "yes\n" {
+		t.Errorf("ExecPipeWith(sandbox) = %q, want %q", got, "yes\n")
+	}
+}
+
+func TestExecPipeRLimits(t *testing.T) {
+	pipe := pipefence.ExecPipeWith(pipefence.ExecOptions{
+		RLimits: pipefence.RLimits{
+			CPU:      1500 * time.Millisecond,
+			Memory:   1 << 30,
+			FileSize: 2000,
+		},
+	}, "sh", "-c", "ulimit -t; ulimit -v; ulimit -f")
+	got, err := pipe(context.Background(), nil, pipefence.Info{})
+	if err != nil {
+		t.Fatalf("ExecPipeWith: %v", err)
+	}
+	if want := "2\n1048576\n4\n"; string(got) != want {
+		t.Errorf("ExecPipeWith(limits) = %q, want %q", got, want)
+	}
+
+	dir := t.TempDir()
+	pipe = pipefence.ExecPipeWith(pipefence.ExecOptions{
+		RLimits: pipefence.RLimits{FileSize: 1024},
+	}, "sh", "-c", "trap '' XFSZ; head -c 4096 /dev/zero >"+filepath.Join(dir, "big"))
+	if _, err := pipe(context.Background(), nil, pipefence.Info{}); err == nil {
+		t.Errorf("ExecPipeWith(large file) succeeded, want error")
+	}
+}
+
+func TestExecError(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		script   string
+		exitCode int
+		want     string
+	}{
+		{
+			name:     "stderr",
+			script:   "echo 'syntax error in line 1' >&2; exit 3",
+			exitCode: 3,
+			want:     "sh: exit status 3: syntax error in line 1",
+		},
+		{
+			name:     "no stderr",
+			script:   "exit 1",
+			exitCode: 1,
+			want:     "sh: exit status 1",
+		},
+		{
+			name:     "long stderr",
+			script:   "for i in 1 2 3 4 5 6 7 8 9 10 11 12; do echo line $i >&2; done; exit 1",
+			exitCode: 1,
+			want:     "sh: exit status 1: line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10 …",
+		},
+		{
+			name:     "signal",
+			script:   "kill -9 $$",
+			exitCode: -1,
+			want:     "sh: signal: killed",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pipefence.ExecPipe("sh", "-c", tt.script)(context.Background(), nil, pipefence.Info{})
+			var eerr *pipefence.ExecError
+			if !errors.As(err, &eerr) {
+				t.Fatalf("ExecPipe() = %v, want *ExecError", err)
+			}
+			if eerr.ExitCode != tt.exitCode {
+				t.Errorf("ExitCode = %d, want %d", eerr.ExitCode, tt.exitCode)
+			}
+			if got, want := strings.Join(eerr.Command, " "), "sh -c "+tt.script; got != want {
+				t.Errorf("Command = %q, want %q", got, want)
+			}
+			if got := err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecPipeEnvironment(t *testing.T) {
+	t.Setenv("PIPEFENCE_TEST_OUTER", "outer")
+	dir := t.TempDir()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	for _, tt := range []struct {
+		name   string
+		opts   pipefence.ExecOptions
+		script string
+		want   string
+	}{
+		{
+			name:   "clean",
+			script: "env | grep -Ev '^(PWD|SHLVL|_)=' | sort",
+			want:   "PATH=" + os.Getenv("PATH") + "\n",
+		},
+		{
+			name:   "env",
+			opts:   pipefence.ExecOptions{Env: map[string]string{"LANG": "C", "PATH": "/bin:/usr/bin"}},
+			script: "env | grep -Ev '^(PWD|SHLVL|_)=' | sort",
+			want:   "LANG=C\nPATH=/bin:/usr/bin\n",
+		},
+		{
+			name:   "inherit",
+			opts:   pipefence.ExecOptions{InheritEnv: true, Env: map[string]string{"PIPEFENCE_TEST_INNER": "inner"}},
+			script: "echo $PIPEFENCE_TEST_OUTER $PIPEFENCE_TEST_INNER",
+			want:   "outer inner\n",
+		},
+		{
+			name:   "dir",
+			opts:   pipefence.ExecOptions{Dir: dir},
+			script: "pwd",
+			want:   dir + "\n",
+		},
+		{
+			name:   "extra files",
+			opts:   pipefence.ExecOptions{ExtraFiles: []*os.File{w}},
+			script: "echo hello >&3; echo ok",
+			want:   "ok\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pipefence.ExecPipeWith(tt.opts, "sh", "-c", tt.script)(context.Background(), nil, pipefence.Info{})
+			if err != nil {
+				t.Fatalf("ExecPipeWith: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ExecPipeWith(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+
+	buf := make([]byte, 6)
+	if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "hello\n" {
+		t.Errorf("extra file received %q, %v, want %q", buf, err, "hello\n")
+	}
+}
+
+func TestExecPipeArgTemplates(t *testing.T) {
+	opts := pipefence.ExecOptions{
+		ArgDefaults: map[string]string{"layout": "dot"},
+		ArgPatterns: map[string]*regexp.Regexp{"scale": regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)},
+	}
+	pipe := pipefence.ExecPipeWith(opts, "echo", "-K{{.layout}}", "-s", "{{ .scale }}")
+	for _, tt := range []struct {
+		options map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			options: map[string]string{"layout": "neato", "scale": "1.5"},
+			want:    "-Kneato -s 1.5\n",
+		},
+		{
+			options: map[string]string{"scale": "2"},
+			want:    "-Kdot -s 2\n",
+		},
+		{
+			options: map[string]string{},
+			wantErr: `echo: missing option "scale"`,
+		},
+		{
+			options: map[string]string{"scale": "big"},
+			wantErr: `echo: invalid value "big" for option "scale"`,
+		},
+		{
+			options: map[string]string{"scale": "1", "layout": "-o/etc/passwd"},
+			wantErr: `echo: invalid value "-o/etc/passwd" for option "layout"`,
+		},
+		{
+			options: map[string]string{"scale": "1", "layout": "a b"},
+			wantErr: `echo: invalid value "a b" for option "layout"`,
+		},
+	} {
+		got, err := pipe(context.Background(), nil, pipefence.Info{Options: tt.options})
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("pipe(%v) = %q, %v, want error %q", tt.options, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.want {
+			t.Errorf("pipe(%v) = %q, %v, want %q", tt.options, got, err, tt.want)
+		}
+	}
+
+	pipe = pipefence.ExecPipe("echo", "{{.layout | printf}}")
+	if _, err := pipe(context.Background(), nil, pipefence.Info{}); err == nil || !strings.Contains(err.Error(), "invalid placeholder") {
+		t.Errorf("pipe with invalid placeholder: err = %v, want invalid placeholder", err)
+	}
+}
@@ -0,0 +1,262 @@
+package pipefence
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// ExecPool is a pool of long-lived processes of a tool, which
+// transform blocks without a fork and exec per block.  This suits
+// rendering services with a high throughput.
+//
+// The processes speak a simple protocol on their standard input and
+// output.  For each block, the pool writes the length of the content
+// as a 4-byte big-endian integer, followed by the content.  The
+// process responds with a status byte, the length of the payload as
+// a 4-byte big-endian integer and the payload.  For status 0, the
+// payload is the output; otherwise, it is an error message.
+//
+// Processes are started on demand.  A process which fails to follow
+// the protocol, or whose block is cancelled, is killed and replaced
+// by a new one for the next block.
+//
+// Example:
+//
+//	pool := &pipefence.ExecPool{Command: []string{"plantuml-worker"}, Size: 4}
+//	defer pool.Close()
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"plantuml": pool.Pipe,
+//		},
+//	}
+type ExecPool struct {
+	// Command is the command line of the tool.
+	Command []string
+
+	// Size is the maximum number of processes.  If zero, a single
+	// process is used.
+	Size int
+
+	// MaxResponseSize limits the size of the responses in bytes.
+	// If zero, DefaultMaxResponseSize is used.
+	MaxResponseSize int64
+
+	initOnce sync.Once
+	slots    chan struct{}
+
+	mu     sync.Mutex
+	idle   []*poolProcess
+	closed bool
+}
+
+// errPoolClosed is returned by the Pipe of a closed ExecPool.
+var errPoolClosed = errors.New("exec pool closed")
+
+// poolProcess is a running process of an ExecPool.
+type poolProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bytes.Buffer
+}
+
+func (p *ExecPool) init() {
+	p.initOnce.Do(func() {
+		p.slots = make(chan struct{}, max(p.Size, 1))
+	})
+}
+
+// Pipe is a pipe function which transforms the block content in one
+// of the processes.
+func (p *ExecPool) Pipe(ctx context.Context, src []byte, _ Info) ([]byte, error) {
+	p.init()
+	if len(p.Command) == 0 {
+		return nil, errors.New("exec pool: empty command")
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	proc, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		out []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := p.exchange(proc, src)
+		done <- result{out, err}
+	}()
+	select {
+	case res := <-done:
+		var perr *poolError
+		if res.err == nil || errors.As(res.err, &perr) {
+			p.put(proc)
+			return res.out, res.err
+		}
+		proc.kill()
+		return nil, &ExecError{
+			Command:  p.Command,
+			ExitCode: proc.cmd.ProcessState.ExitCode(),
+			Stderr:   proc.stderr.Bytes(),
+			Err:      res.err,
+		}
+	case <-ctx.Done():
+		// The process may be in the middle of a response.
+		proc.cmd.Process.Kill()
+		<-done
+		proc.cmd.Wait()
+		return nil, ctx.Err()
+	}
+}
+
+// poolError is an error reported by a process of an ExecPool
+// according to the protocol.
+type poolError struct {
+	name string
+	msg  []byte
+}
+
+func (e *poolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.name, e.msg)
+}
+
+// exchange sends src to proc and reads its response.
+func (p *ExecPool) exchange(proc *poolProcess, src []byte) ([]byte, error) {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(src)))
+	if _, err := proc.stdin.Write(append(header[:], src...)); err != nil {
+		return nil, err
+	}
+
+	status, err := proc.stdout.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(proc.stdout, header[:]); err != nil {
+		return nil, err
+	}
+	maxSize := p.MaxResponseSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxResponseSize
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if int64(n) > maxSize {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxSize)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(proc.stdout, payload); err != nil {
+		return nil, err
+	}
+	if status != 0 {
+		return nil, &poolError{name: p.Command[0], msg: bytes.TrimSpace(payload)}
+	}
+	return payload, nil
+}
+
+// get returns an idle process, or starts a new one.
+func (p *ExecPool) get() (*poolProcess, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errPoolClosed
+	}
+	if n := len(p.idle); n > 0 {
+		proc := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return proc, nil
+	}
+	p.mu.Unlock()
+
+	cmd := exec.Command(p.Command[0], p.Command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = &limitedWriter{w: stderr, n: maxStderr}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Command[0], err)
+	}
+	return &poolProcess{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), stderr: stderr}, nil
+}
+
+// put returns proc to the idle processes.
+func (p *ExecPool) put(proc *poolProcess) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		proc.stop()
+		return
+	}
+	p.idle = append(p.idle, proc)
+}
+
+// Close stops the idle processes and makes subsequent invocations of
+// Pipe fail.  Processes which are transforming a block are stopped
+// once they have finished.
+func (p *ExecPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	var errs []error
+	for _, proc := range p.idle {
+		errs = append(errs, proc.stop())
+	}
+	p.idle = nil
+	return errors.Join(errs...)
+}
+
+// stop closes the standard input of the process, so that it can
+// exit gracefully, and waits for it.
+func (proc *poolProcess) stop() error {
+	proc.stdin.Close()
+	return proc.cmd.Wait()
+}
+
+// kill kills the process.
+func (proc *poolProcess) kill() {
+	proc.cmd.Process.Kill()
+	proc.cmd.Wait()
+}
+
+// limitedWriter writes up to n bytes to w and discards the rest.
+type limitedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+	n  int
+}
+
+func (l *limitedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(b) > l.n {
+		l.w.Write(b[:l.n])
+	} else {
+		l.w.Write(b)
+	}
+	l.n -= min(l.n, len(b))
+	return len(b), nil
+}
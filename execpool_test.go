@@ -0,0 +1,130 @@
+package pipefence_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// TestExecPoolWorker is not a real test, but a worker process for
+// the ExecPool tests.  It upper-cases its inputs, fails for "fail",
+// sleeps for "sleep" and exits for "exit".
+func TestExecPoolWorker(t *testing.T) {
+	if os.Getenv("PIPEFENCE_TEST_WORKER") != "1" {
+		t.Skip("not running as a worker")
+	}
+	r := bufio.NewReader(os.Stdin)
+	respond := func(status byte, payload []byte) {
+		var header [5]byte
+		header[0] = status
+		binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+		os.Stdout.Write(append(header[:], payload...))
+	}
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			os.Exit(0)
+		}
+		src := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(r, src); err != nil {
+			os.Exit(1)
+		}
+		switch string(src) {
+		case "fail":
+			respond(1, []byte("syntax error\n"))
+		case "sleep":
+			time.Sleep(time.Minute)
+		case "exit":
+			os.Stderr.WriteString("crashed")
+			os.Exit(2)
+		default:
+			respond(0, bytes.ToUpper(src))
+		}
+	}
+}
+
+func newTestPool(t *testing.T, size int) *pipefence.ExecPool {
+	t.Helper()
+	t.Setenv("PIPEFENCE_TEST_WORKER", "1")
+	pool := &pipefence.ExecPool{
+		Command: []string{os.Args[0], "-test.run=^TestExecPoolWorker$"},
+		Size:    size,
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+func TestExecPool(t *testing.T) {
+	pool := newTestPool(t, 2)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := pool.Pipe(ctx, []byte("abc"), pipefence.Info{})
+			if err != nil || string(got) != "ABC" {
+				t.Errorf("Pipe(abc) = %q, %v, want %q", got, err, "ABC")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Errors reported by the worker keep it alive.
+	if _, err := pool.Pipe(ctx, []byte("fail"), pipefence.Info{}); err == nil || !strings.Contains(err.Error(), "syntax error") {
+		t.Errorf("Pipe(fail) = %v, want syntax error", err)
+	}
+	if got, err := pool.Pipe(ctx, []byte("x"), pipefence.Info{}); err != nil || string(got) != "X" {
+		t.Errorf("Pipe(x) after failure = %q, %v, want %q", got, err, "X")
+	}
+}
+
+func TestExecPoolCrash(t *testing.T) {
+	pool := newTestPool(t, 1)
+	ctx := context.Background()
+
+	if _, err := pool.Pipe(ctx, []byte("exit"), pipefence.Info{}); err == nil || !strings.Contains(err.Error(), "crashed") {
+		t.Errorf("Pipe(exit) = %v, want error with stderr", err)
+	}
+	// The crashed process is replaced.
+	if got, err := pool.Pipe(ctx, []byte("x"), pipefence.Info{}); err != nil || string(got) != "X" {
+		t.Errorf("Pipe(x) after crash = %q, %v, want %q", got, err, "X")
+	}
+}
+
+func TestExecPoolCancel(t *testing.T) {
+	pool := newTestPool(t, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Pipe(ctx, []byte("sleep"), pipefence.Info{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Pipe(sleep) = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got, err := pool.Pipe(context.Background(), []byte("x"), pipefence.Info{}); err != nil || string(got) != "X" {
+		t.Errorf("Pipe(x) after cancellation = %q, %v, want %q", got, err, "X")
+	}
+}
+
+func TestExecPoolClose(t *testing.T) {
+	pool := newTestPool(t, 1)
+	if _, err := pool.Pipe(context.Background(), []byte("x"), pipefence.Info{}); err != nil {
+		t.Fatalf("Pipe(x): %v", err)
+	}
+	if err := pool.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, err := pool.Pipe(context.Background(), []byte("x"), pipefence.Info{}); err == nil {
+		t.Errorf("Pipe after Close succeeded, want error")
+	}
+}
@@ -6,8 +6,14 @@ package pipefence
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
@@ -18,9 +24,16 @@ import (
 )
 
 // PipeFunc defines how to transform the contents of a given fenced
-// code block.
+// code block.  The contents may share memory with the Markdown
+// source and must not be modified.
 type PipeFunc func([]byte) ([]byte, error)
 
+// PipeFuncCtx is a variant of PipeFunc which additionally receives a
+// context and the parsed info string of the fenced code block.
+// Long-running transformations (e.g. external tools) should abort
+// when the context is cancelled.
+type PipeFuncCtx func(ctx context.Context, src []byte, info Info) ([]byte, error)
+
 // Extension is a goldmark extension which pipes annotated fenced code
 // block contents through the matching functions.
 //
@@ -31,8 +44,600 @@ type PipeFunc func([]byte) ([]byte, error)
 //	```pikchr
 //	box "lolcat"
 //	```
+//
+// The output of a block named with the name option can be shown
+// again elsewhere in the document, by a block in the same language
+// with the ref option, or by an image with a #pipefence: link:
+//
+//	```dot name=topology
+//	digraph { a -> b }
+//	```
+//
+//	```dot ref=topology
+//	```
+//
+//	![Topology](#pipefence:topology)
+//
+// An Extension may be used by multiple conversions concurrently.
+// Once it is in use, pipes must only be added and removed through
+// Register and Unregister, not by modifying the maps directly.
 type Extension struct {
 	PipeFuncs map[string]PipeFunc
+
+	// PipeFuncsCtx are like PipeFuncs, but the functions receive a
+	// context.  If a language is registered in both maps, the entry
+	// in PipeFuncsCtx wins.
+	PipeFuncsCtx map[string]PipeFuncCtx
+
+	// BatchPipeFuncs transform all blocks of a language in a
+	// document with a single invocation.  Entries in PipeFuncs and
+	// PipeFuncsCtx take precedence.  Within pipelines, and for
+	// blocks which are not transformed together with others, the
+	// functions are invoked with a single block.
+	BatchPipeFuncs map[string]BatchPipeFunc
+
+	// Context is passed to the PipeFuncsCtx.  When it is cancelled,
+	// the conversion stops at the next fenced block to be piped.
+	// If nil, context.Background() is used.  It can be overridden
+	// for a single conversion with WithContext.
+	Context context.Context
+
+	// ErrorMode defines how errors from pipe functions are
+	// handled.  The default is FailFast.
+	ErrorMode ErrorMode
+
+	// Cache, if set, stores pipe outputs keyed by language, options
+	// and content, so that identical blocks are only transformed
+	// once.
+	Cache Cache
+
+	// CacheVersions are mixed into the cache keys of blocks, keyed
+	// by language, so that cached outputs are not reused once a
+	// pipe changes.  A version can combine a version string of the
+	// pipe with a ToolFingerprint of its binary:
+	//
+	//	fp, err := pipefence.ToolFingerprint("dot", false)
+	//	...
+	//	ext.CacheVersions = map[string]string{"dot": "v2 " + fp}
+	CacheVersions map[string]string
+
+	// PathDependent lists the languages whose pipes depend on
+	// Info.Path, e.g. to resolve relative includes.  The cache keys
+	// of their blocks cover the Path, so that blocks in different
+	// documents do not share outputs through the Cache, Deduplicate
+	// and FailureTTL.
+	PathDependent map[string]bool
+
+	// FailureTTL, if positive, makes identical blocks fail with the
+	// remembered error of a failed pipe invocation for this long,
+	// instead of invoking the pipe again.  This keeps, e.g., a
+	// development server which re-renders on every keystroke from
+	// re-running a slow tool on a broken diagram.  Failures are
+	// remembered in memory, independently of the Cache.
+	FailureTTL time.Duration
+
+	// Detect, if set, is called with the content of fenced code
+	// blocks without a language, and returns the language to treat
+	// them as, or "" to leave them as they are.  This helps to
+	// migrate documents whose diagrams have bare fences.
+	// DetectDiagram is a detector for common diagram languages.
+	Detect func(src []byte) string
+
+	// IncludeRoot, if set, allows blocks to take their content from
+	// a file named by the src option, relative to IncludeRoot, so
+	// that large diagrams can live next to the Markdown:
+	//
+	//	```dot src=diagrams/arch.dot
+	//	```
+	//
+	// The block must be empty.  Files outside of IncludeRoot can not
+	// be included, not even through symbolic links.
+	IncludeRoot string
+
+	// Params, if set, returns the metadata of a document, for
+	// expanding placeholders such as {{ .Params.version }} in the
+	// contents of blocks in ParamLanguages before piping.  The
+	// placeholders use the text/template syntax.  With the
+	// goldmark-meta extension, Params can be meta.Get, so that
+	// diagrams can embed values from the front matter.
+	Params func(pc parser.Context) map[string]any
+
+	// ParamLanguages lists the languages whose blocks have their
+	// placeholders expanded with Params.  Blocks in other languages
+	// are left alone, as some diagram languages use {{ and }} in
+	// their syntax.
+	ParamLanguages []string
+
+	// Preludes and Postludes are prepended and appended to the
+	// contents of all blocks of a language before piping, keyed by
+	// language, e.g. to share styles or settings between diagrams
+	// without repeating them in every block:
+	//
+	//	ext.Preludes = map[string][]byte{
+	//		"gnuplot": []byte("set terminal svg\n"),
+	//	}
+	//
+	// They are not shown with ShowSource.
+	Preludes  map[string][]byte
+	Postludes map[string][]byte
+
+	// ColorSchemes make blocks of a language render twice, keyed
+	// by language, with different options for light and dark color
+	// schemes, e.g. for SVG diagrams with hardcoded colors.  Both
+	// outputs are emitted; include ColorSchemeCSS in the page to
+	// show the one matching the user's preferred color scheme.
+	ColorSchemes map[string]ColorSchemes
+
+	// Srcsets make the blocks of a language render once for each of
+	// the given pixel densities, keyed by language.  Raster image
+	// outputs are then emitted as an <img> with a srcset, so that
+	// diagrams look crisp on high-DPI displays.  If the output for
+	// the first density is not a raster image, it is used as it is.
+	Srcsets map[string][]Density
+
+	// DefaultPipe, if set, is used for fenced code blocks whose
+	// language has no entry in PipeFuncs or PipeFuncsCtx, including
+	// blocks without a language.
+	DefaultPipe PipeFuncCtx
+
+	// Workers is the number of pipe functions which may run
+	// concurrently within one document.  If zero, the blocks are
+	// transformed one after another.  Pipe functions must be safe
+	// for concurrent use if Workers is larger than one.
+	Workers int
+
+	// Timeout limits the run time of each pipe function, unless
+	// overridden for the language in Timeouts.  Blocks exceeding it
+	// fail with a *TimeoutError.  Zero means no limit.
+	Timeout time.Duration
+
+	// Timeouts are per-language overrides for Timeout.
+	Timeouts map[string]time.Duration
+
+	// SizeLimit restricts the sizes of the contents and outputs of
+	// blocks, unless overridden for the language in SizeLimits.
+	// Blocks exceeding it fail with a *SizeError.
+	SizeLimit SizeLimit
+
+	// SizeLimits are per-language overrides for SizeLimit.
+	SizeLimits map[string]SizeLimit
+
+	// Logger receives internal diagnostics, such as pipe failures
+	// and cache events.  If nil, diagnostics are discarded.
+	Logger *slog.Logger
+
+	// Figure, if set, wraps the outputs of all pipes in <figure>
+	// elements, unless overridden for the language in Figures.
+	Figure *FigureOptions
+
+	// Figures are per-language overrides for Figure.  A nil entry
+	// disables figures for that language.
+	Figures map[string]*FigureOptions
+
+	// WrapElement, if set, is the name of an HTML element (e.g.
+	// "div") wrapping the outputs of blocks which have an attribute
+	// block such as {#id .class}.  The element carries the
+	// attributes.
+	WrapElement string
+
+	// AssetWriter, if set, stores pipe outputs as separate files
+	// named after their content hash, and the blocks render as
+	// <img> tags referencing them.  The alt text is taken from the
+	// alt or caption option.
+	AssetWriter AssetWriter
+
+	// AssetMinSize is the output size in bytes from which outputs
+	// are written via the AssetWriter.  Smaller outputs are
+	// inlined.  Binary outputs are always written via the
+	// AssetWriter.
+	AssetMinSize int
+
+	// LinkAssets additionally writes image outputs, including SVG,
+	// via the AssetWriter, even if they are inlined, and wraps
+	// their rendering in a link to the written file, so that
+	// readers can open complex diagrams full-screen or download
+	// them.  Blocks rendered with Srcsets link to the image of the
+	// last density.  It has no effect without an AssetWriter.
+	LinkAssets bool
+
+	// Accessible adds role="img" to the root elements of SVG
+	// outputs, as well as an aria-label and a <title> with the text
+	// of the alt or caption option, so that screen readers announce
+	// diagrams properly.
+	Accessible bool
+
+	// RequireAlt makes blocks whose output is an image, including
+	// SVG, fail with ErrNoAltText unless they have an alt or caption
+	// option.
+	RequireAlt bool
+
+	// OutputTypes declare the MIME types of the outputs of pipes,
+	// keyed by language, e.g. "image/png" for a gnuplot pipe.  For
+	// other languages, the type is detected from the output.
+	// Binary image outputs render as <img> tags, referencing either
+	// an asset written by the AssetWriter or a data: URI.
+	OutputTypes map[string]string
+
+	// Allow, if non-nil, lists the only languages which may be
+	// piped.  Blocks in other languages render as plain code
+	// blocks.
+	Allow []string
+
+	// Deny lists languages which must not be piped, even if they
+	// have a pipe function.  Blocks in these languages render as
+	// plain code blocks.
+	Deny []string
+
+	// Untrusted disables all pipes, so that every fenced code block
+	// renders as a plain code block.  This is useful for reusing the
+	// same Extension for user-submitted Markdown.
+	Untrusted bool
+
+	// OnError, if set, is called for each block whose pipe failed,
+	// in document order, regardless of the ErrorMode.  Pos is the
+	// range of the block's content in the source.
+	OnError func(err error, lang string, pos text.Segment)
+
+	// OnWarning, if set, is called for each Warning, in document
+	// order.  Warnings are also available from Warnings.
+	OnWarning func(Warning)
+
+	// WarnOutputSize, if positive, is the output size in bytes from
+	// which blocks produce a Warning, e.g. to point authors at
+	// diagrams which bloat the page.
+	WarnOutputSize int
+
+	// IndentedCodeMarker, if set, enables piping of indented code
+	// blocks whose first line starts with this marker, followed by
+	// the info string.  For example, with the marker "%%", the
+	// following indented code block is piped through "dot":
+	//
+	//	    %%dot
+	//	    digraph { a -> b }
+	IndentedCodeMarker string
+
+	// InlinePipeFuncs are pipe functions for code spans, keyed by a
+	// prefix.  A code span starting with the prefix and a colon, such
+	// as `math:\frac{1}{2}`, is replaced with the output of the pipe
+	// function for the remaining content.
+	InlinePipeFuncs map[string]PipeFuncCtx
+
+	// Sanitize, if set, is applied to all pipe outputs before they
+	// are inlined into the HTML, e.g. to strip scripts from the
+	// outputs of semi-trusted tools.  It can be a bluemonday
+	// policy's SanitizeBytes method.  Outputs written through the
+	// AssetWriter are not sanitized, as they are not inlined.
+	Sanitize func([]byte) []byte
+
+	// PostProcess are applied in order to the outputs of all pipes,
+	// including those taken from the Cache, for site-wide concerns
+	// such as minification, link rewriting or adding nonces to
+	// inline styles.  They run before the outputs are sanitized or
+	// written via the AssetWriter, and receive binary outputs as
+	// well, which they must not modify in place.  Failures make the
+	// block fail.
+	PostProcess []func(lang string, out []byte) ([]byte, error)
+
+	// Metrics, if set, is notified of every pipe invocation.
+	Metrics Metrics
+
+	// Tracer, if set, traces every pipe invocation, e.g. with
+	// OpenTelemetry spans.
+	Tracer Tracer
+
+	// Annotate surrounds the output of each piped block with HTML
+	// comments recording the language, the pipe, the duration of
+	// the invocation, the cache status and a hash of the content:
+	//
+	//	<!-- pipefence language="dot" pipe="dot" duration=41.52ms cache=miss hash=5d3e0b8a41c2f967 -->
+	//	<svg ...
+	//	<!-- /pipefence -->
+	//
+	// This helps to track down slow or stale diagrams in generated
+	// pages.  Failed blocks are annotated with the error as well.
+	Annotate bool
+
+	// Deduplicate makes identical blocks (same language, options and
+	// content) within a document invoke their pipe function only
+	// once, independently of the Cache, so that documents repeating
+	// a diagram, e.g. from a template, pay for it once.  Code spans
+	// are only deduplicated with code spans.  Concurrent invocations
+	// for identical blocks from different conversions are coalesced
+	// as well, and share the assets, dependencies and warnings the
+	// pipe declares; when the conversion whose invocation the others
+	// wait for is cancelled, the next one invokes the pipe instead.
+	Deduplicate bool
+
+	// SVGUseReferences makes deduplicated blocks with SVG output
+	// refer to the first occurrence with an SVG <use> element,
+	// instead of repeating the SVG.  It requires Deduplicate.
+	SVGUseReferences bool
+
+	// ShowSource renders a collapsible <details> element with the
+	// original code after the output of each block.  It can be
+	// overridden per block with the showsource option, whose value
+	// may also be the summary text:
+	//
+	//	```dot showsource="Graphviz source"
+	ShowSource bool
+
+	// Isolate embeds the outputs of the given languages into the
+	// HTML with an <iframe srcdoc> or a declarative shadow DOM
+	// rather than inlining them, which prevents CSS rules and IDs
+	// of outputs, such as those of many SVGs on one page, from
+	// colliding with each other and with the page.  It can be
+	// overridden per block with the isolate option:
+	//
+	//	```mermaid isolate=shadow
+	//
+	// Outputs rendered as <img> tags are not isolated.
+	Isolate map[string]Isolation
+
+	// Aliases map alternative language names to the languages of
+	// registered pipes, e.g. "graphviz" to "dot".  Blocks in an
+	// alias are treated as blocks in the aliased language in all
+	// respects: their Info.Language, cache keys, policies and
+	// per-language settings use the aliased language.
+	Aliases map[string]string
+
+	// DeprecatedAliases lists keys of Aliases which produce a
+	// Warning suggesting the aliased language when used.
+	DeprecatedAliases []string
+
+	// CaseInsensitive makes languages match pipes and aliases
+	// regardless of case, so that ```Dot and ```DOT blocks use the
+	// pipe registered as "dot".  The blocks are then treated as
+	// blocks in the registered spelling, as with Aliases.  Patterns
+	// are matched against the language as written.
+	CaseInsensitive bool
+
+	// Limits restrict the concurrency and rate of pipe invocations
+	// per language, e.g. to avoid starting dozens of processes or
+	// overloading a remote renderer.  Cache hits are not limited.
+	// Limits must not be modified once the Extension is in use.
+	Limits map[string]Limit
+
+	// Defer lists languages whose blocks are left to client-side
+	// renderers: instead of running a pipe, the block content is
+	// emitted in an HTML shell, and the client libraries needed are
+	// recorded for RequiredAssets.  Deferral takes precedence over
+	// the pipe functions of a language.
+	Defer map[string]Deferral
+
+	// Requires lists the page-level assets, such as scripts and
+	// stylesheets, needed by pages with blocks of a language, as
+	// reported by RequiredAssets.  Pipes can also declare assets at
+	// run time with RequireAssets.
+	Requires map[string][]string
+
+	// Tools describe the external tools used by the pipes, keyed by
+	// language, for ValidateTools.  Blocks whose language has a soft
+	// Tool which is not installed render as plain code blocks.
+	Tools map[string]Tool
+
+	flights  flightGroup
+	failures failureCache
+
+	toolsMu    sync.Mutex
+	toolsFound map[string]bool
+
+	limitersMu sync.Mutex
+	limiters   map[string]*limiter
+
+	// mu guards PipeFuncs, PipeFuncsCtx, BatchPipeFuncs, Aliases,
+	// patterns, middleware, required and execTools.
+	mu         sync.RWMutex
+	patterns   []patternPipe
+	middleware []Middleware
+	required   []string        // Languages passed to Require.
+	execTools  map[string]Tool // Tools of the pipes passed to RegisterExec.
+}
+
+// Register registers fn as the pipe function for lang, replacing
+// any previously registered pipe function for it.  It is safe to
+// call Register while conversions are running.
+func (e *Extension) Register(lang string, fn PipeFuncCtx) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.register(lang, fn)
+}
+
+// register registers fn for lang.  e.mu must be held.
+func (e *Extension) register(lang string, fn PipeFuncCtx) {
+	delete(e.PipeFuncs, lang)
+	delete(e.BatchPipeFuncs, lang)
+	delete(e.execTools, lang)
+	if e.PipeFuncsCtx == nil {
+		e.PipeFuncsCtx = make(map[string]PipeFuncCtx)
+	}
+	e.PipeFuncsCtx[lang] = fn
+}
+
+// RegisterExec registers ExecPipe(name, args...) as the pipe
+// function for lang, as Register does, and records name as its Tool
+// for ValidateTools.
+func (e *Extension) RegisterExec(lang string, name string, args ...string) {
+	e.RegisterExecWith(lang, ExecOptions{InheritEnv: true}, name, args...)
+}
+
+// RegisterExecWith registers ExecPipeWith(opts, name, args...) as
+// the pipe function for lang, as Register does, and records its Tool
+// for ValidateTools: name, or the runtime of opts.Container.
+func (e *Extension) RegisterExecWith(lang string, opts ExecOptions, name string, args ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.register(lang, ExecPipeWith(opts, name, args...))
+	if e.execTools == nil {
+		e.execTools = make(map[string]Tool)
+	}
+	e.execTools[lang] = opts.tool(name)
+}
+
+// Unregister removes the pipe function for lang.  It is safe to
+// call Unregister while conversions are running.
+func (e *Extension) Unregister(lang string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.PipeFuncs, lang)
+	delete(e.PipeFuncsCtx, lang)
+	delete(e.BatchPipeFuncs, lang)
+	delete(e.execTools, lang)
+}
+
+// lookup returns the pipe function registered for lang, or the
+// DefaultPipe.  The raw info string info of the block is passed to
+// Pipers.
+func (e *Extension) lookup(lang string, info []byte) (PipeFuncCtx, bool) {
+	if f, ok := e.lookupRegistered(lang, info); ok {
+		return f, true
+	}
+	if e.DefaultPipe != nil {
+		return e.DefaultPipe, true
+	}
+	return nil, false
+}
+
+// lookupRegistered returns the pipe function registered for lang,
+// either exactly or through a pattern or Piper.
+func (e *Extension) lookupRegistered(lang string, info []byte) (PipeFuncCtx, bool) {
+	if f, ok := e.lookupExact(lang); ok {
+		return f, true
+	}
+	return e.lookupPattern(lang, info)
+}
+
+// lookupExact returns the pipe function registered for lang.
+func (e *Extension) lookupExact(lang string) (PipeFuncCtx, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if f, ok := e.PipeFuncsCtx[lang]; ok {
+		return f, true
+	}
+	if f, ok := e.PipeFuncs[lang]; ok {
+		return func(_ context.Context, src []byte, _ Info) ([]byte, error) {
+			return f(src)
+		}, true
+	}
+	if b, ok := e.BatchPipeFuncs[lang]; ok {
+		return batchPipe(b), true
+	}
+	return nil, false
+}
+
+// pipeResult is the output of a pipe invocation together with the
+// assets, dependencies and warnings which the pipe declared while
+// running, so that they reach every block the output is used for.
+type pipeResult struct {
+	out      []byte
+	assets   []string // Declared with RequireAssets.
+	deps     []string // Declared with DependOn.
+	warnings []string // Reported with Warnf.
+}
+
+// run invokes pipeFunc on src, consulting the cache and the
+// remembered failures if configured, and reports whether the result
+// was taken from them.  With
+// Deduplicate, concurrent invocations for identical blocks are
+// coalesced.  Code spans, for which inline is set, are kept apart
+// from blocks.
+func (e *Extension) run(ctx context.Context, pipeFunc PipeFuncCtx, src []byte, info Info, inline bool) (res pipeResult, cached bool, err error) {
+	if e.Cache == nil && !e.Deduplicate && e.FailureTTL <= 0 {
+		res, err = e.invoke(ctx, pipeFunc, src, info)
+		return res, false, err
+	}
+
+	key := e.cacheKey(info, src, inline)
+	if e.FailureTTL > 0 {
+		if err, ok := e.failures.get(key, time.Now()); ok {
+			e.logger().Debug("pipefence: cached failure", "language", info.Language, "key", key)
+			return pipeResult{}, true, err
+		}
+	}
+	if e.Cache != nil {
+		probes, _ := ctx.Value(cacheProbesKey{}).(map[string]cacheProbe)
+		p, ok := probes[key]
+		if !ok {
+			p.value, p.ok = e.Cache.Get(key)
+		}
+		if p.ok {
+			e.logger().Debug("pipefence: cache hit", "language", info.Language, "key", key)
+			return decodeCacheEntry(p.value), true, nil
+		}
+		e.logger().Debug("pipefence: cache miss", "language", info.Language, "key", key)
+	}
+
+	invoke := func() (pipeResult, error) {
+		return e.invoke(ctx, pipeFunc, src, info)
+	}
+	if e.Deduplicate {
+		res, err = e.flights.do(ctx, key, invoke)
+	} else {
+		res, err = invoke()
+	}
+	if err != nil {
+		if e.FailureTTL > 0 && ctx.Err() == nil {
+			e.failures.set(key, err, time.Now(), e.FailureTTL)
+		}
+		return res, false, err
+	}
+	if e.Cache != nil {
+		e.Cache.Set(key, encodeCacheEntry(res))
+	}
+	return res, false, nil
+}
+
+// invoke calls pipeFunc, once the Limits for the language permit,
+// and collects what the pipe declares while running.  Outputs
+// computed by runBatches are not limited again.
+func (e *Extension) invoke(ctx context.Context, pipeFunc PipeFuncCtx, src []byte, info Info) (pipeResult, error) {
+	if lim := e.limiter(info.Language); lim != nil && !batched(ctx, info.Language, src) {
+		release, err := lim.acquire(ctx)
+		if err != nil {
+			return pipeResult{}, err
+		}
+		defer release()
+	}
+	req := &requirements{}
+	warns := &warnings{}
+	ctx = context.WithValue(ctx, requirementsKey{}, req)
+	ctx = context.WithValue(ctx, warningsKey{}, warns)
+	out, err := pipeFunc(ctx, src, info)
+	return pipeResult{
+		out:      out,
+		assets:   req.urls(),
+		deps:     req.dependencies(),
+		warnings: warns.list(),
+	}, err
+}
+
+// logger returns the logger to use for diagnostics.
+func (e *Extension) logger() *slog.Logger {
+	if e.Logger == nil {
+		return discardLogger
+	}
+	return e.Logger
+}
+
+// baseContext returns the context to use for pipe invocations in
+// the conversion with the parser context pc: the one set with
+// WithContext, or else e.Context, with the values of WithValue.
+func (e *Extension) baseContext(pc parser.Context) context.Context {
+	ctx, ok := pc.Get(contextKey).(context.Context)
+	if !ok {
+		ctx = e.Context
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	values, _ := pc.Get(valuesKey).([]contextValue)
+	for _, v := range values {
+		ctx = context.WithValue(ctx, v.key, v.value)
+	}
+	return ctx
 }
 
 // Extension extends the provided Goldmark parser with support for
@@ -45,98 +650,648 @@ func (e *Extension) Extend(md goldmark.Markdown) {
 	)
 	md.Renderer().AddOptions(
 		renderer.WithNodeRenderers(
-			util.Prioritized(&pfRenderer{ext: e}, 100),
+			util.Prioritized(&pfRenderer{}, 100),
 		),
 	)
 }
 
-// transformer transforms eligible fenced code blocks into pfBlock.
+// transformer transforms eligible fenced code blocks into Block
+// and runs them through their pipe functions.
 //
-// The only purpose of this step is so that we can register a renderer
-// for that specific pfBlock node kind, rather than for all fenced
-// code blocks.
+// The pipe functions are invoked at this stage rather than during
+// rendering, so that they can run concurrently.  The renderer only
+// writes the results.
 type transformer struct {
 	ext *Extension
 }
 
 func (t *transformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
-	var fencedBlocks []*ast.FencedCodeBlock
+	// candidate is a code block or span which may need to be piped.
+	// For fenced code blocks, node and fb are the same; for indented
+	// code blocks, fb is a fenced equivalent of node.  For code
+	// spans, fb is nil and inline is set.
+	type candidate struct {
+		node   ast.Node
+		fb     *ast.FencedCodeBlock
+		inline *job
+	}
+	var candidates []candidate
 
+	src := reader.Source()
 	err := ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
-		fb, ok := node.(*ast.FencedCodeBlock)
-		if !ok {
+		if !enter {
 			return ast.WalkContinue, nil
 		}
-		fencedBlocks = append(fencedBlocks, fb)
+		switch n := node.(type) {
+		case *ast.FencedCodeBlock:
+			candidates = append(candidates, candidate{node: n, fb: n})
+		case *ast.CodeBlock:
+			if fb := t.ext.indentedAsFenced(n, src); fb != nil {
+				candidates = append(candidates, candidate{node: n, fb: fb})
+			}
+		case *ast.CodeSpan:
+			if j := t.ext.inlineJob(n, src); j != nil {
+				candidates = append(candidates, candidate{node: n, inline: j})
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			if j := refJob(n, src); j != nil {
+				candidates = append(candidates, candidate{node: n, inline: j})
+				return ast.WalkSkipChildren, nil
+			}
+		}
 		return ast.WalkContinue, nil
 	})
 	if err != nil {
 		// Can not happen if the AST walking callback does not return errors.
-		log.Fatalf("Implementation error: ast.Walk: %v", err)
+		t.ext.logger().Error("pipefence: implementation error: ast.Walk", "error", err)
+		return
+	}
+
+	var (
+		jobs         []*job
+		deferredJobs []*job
+		originals    = make(map[*job][]byte)
+		figures      int
+		warns        []Warning
+		params       map[string]any // Params(pc), once needed.
+	)
+	for _, c := range candidates {
+		if c.inline != nil {
+			n := &pfInline{job: *c.inline, original: c.node.Text(src), ext: t.ext}
+			parent := c.node.Parent()
+			parent.ReplaceChild(parent, c.node, n)
+			jobs = append(jobs, &n.job)
+			originals[&n.job] = n.original
+			continue
+		}
+
+		fb := c.fb
+		written := string(fb.Language(src))
+		if written == "" && t.ext.Detect != nil {
+			written = t.ext.Detect(segmentsValue(fb.Lines(), src))
+		}
+		lang := t.ext.canonical(written)
+		if !t.ext.permitted(lang) {
+			continue
+		}
+		var rawInfo []byte
+		if fb.Info != nil {
+			rawInfo = fb.Info.Text(src)
+		}
+		for _, msg := range t.ext.deprecationWarnings(written) {
+			warns = append(warns, Warning{Line: blockLine(fb, src), Language: lang, Message: msg})
+		}
+		deferral, deferred := t.ext.Defer[lang]
+		var (
+			pipeFunc PipeFuncCtx
+			err      error
+		)
+		if !deferred {
+			if tool := t.ext.missingSoftTool(lang); tool != "" {
+				t.ext.logger().Warn("pipefence: tool not found, rendering plain code block", "language", lang, "line", blockLine(fb, src), "tool", tool)
+				warns = append(warns, Warning{
+					Line:     blockLine(fb, src),
+					Language: lang,
+					Message:  fmt.Sprintf("tool %s not found, rendering plain code block", tool),
+				})
+				continue
+			}
+			var ok bool
+			pipeFunc, ok, err = t.ext.resolve(lang, rawInfo)
+			if rerr := t.ext.checkRequired(lang, rawInfo); rerr != nil {
+				pipeFunc, ok, err = nil, true, rerr
+			}
+			if !ok {
+				continue
+			}
+			if err == nil {
+				pipeFunc = t.ext.wrap(pipeFunc)
+			}
+		}
+		var pipe string
+		if t.ext.Annotate && !deferred {
+			pipe = t.ext.pipeName(lang, rawInfo)
+		}
+
+		// The new node must not share the sibling and parent links
+		// of fb, so it is created afresh rather than copied.
+		block := &Block{
+			FencedCodeBlock: *ast.NewFencedCodeBlock(fb.Info),
+			job: job{
+				pipeFunc: pipeFunc,
+				err:      err,
+				pipe:     pipe,
+			},
+			ext: t.ext,
+		}
+		block.SetLines(fb.Lines())
+		block.content = block.RawContent(src)
+		if fb.Info != nil {
+			block.info = parseInfo(string(rawInfo))
+		}
+		block.info.Language = lang
+		if ref := block.info.Options["ref"]; ref != "" && !deferred {
+			// The block shows the output of a named block
+			// instead of running a pipe.
+			block.ref, block.pipeFunc, block.err = ref, nil, nil
+		}
+		if t.ext.IncludeRoot != "" && block.info.Options["src"] != "" && block.err == nil {
+			var path string
+			block.content, path, block.err = t.ext.include(block.info, block.content)
+			if path != "" {
+				block.deps = append(block.deps, path)
+			}
+		}
+		if t.ext.expandsParams(lang) && block.err == nil {
+			if params == nil {
+				params = t.ext.Params(pc)
+			}
+			block.content, block.err = expandParams(block.info, block.content, params)
+		}
+		block.line = blockLine(fb, src)
+		block.pos = blockSegment(fb)
+		if f := t.ext.figureOptions(block.info.Language); f != nil && f.Numbered {
+			figures++
+			block.figure = figures
+		}
+		parent := c.node.Parent()
+		parent.ReplaceChild(parent, c.node, block)
+		if deferred {
+			block.output = deferral.shell(lang, block.content)
+			block.assets = deferral.Assets
+			deferredJobs = append(deferredJobs, &block.job)
+			continue
+		}
+		block.source = block.content
+		block.content = t.ext.frame(lang, block.content)
+		if block.err == nil && block.pipeFunc != nil {
+			block.err = t.ext.validatePiper(block.info, block.content)
+		}
+		jobs = append(jobs, &block.job)
 	}
 
-	for _, fb := range fencedBlocks {
-		lang := fb.Language(reader.Source())
-		_, ok := t.ext.PipeFuncs[string(lang)]
+	warns = append(warns, linkReferences(jobs)...)
+
+	path, _ := pc.Get(pathKey).(string)
+	for _, j := range jobs {
+		j.info.Path = path
+		j.info.Line = j.line
+	}
+
+	switch {
+	case pc.Get(validateOnlyKey) != nil:
+	case pc.Get(placeholdersKey) != nil:
+		addPending(pc, jobs, originals)
+	default:
+		start := time.Now()
+		t.ext.runAll(t.ext.baseContext(pc), jobs)
+		t.ext.addReport(pc, jobs, time.Since(start))
+	}
+	t.ext.addRequiredAssets(pc, append(jobs, deferredJobs...))
+	t.ext.addDependencies(pc, append(jobs, deferredJobs...))
+	t.ext.reportWarnings(pc, warns, jobs)
+}
+
+// runAll runs the pipe functions of all jobs, using up to
+// e.Workers goroutines, and stores the results in the jobs.
+func (e *Extension) runAll(ctx context.Context, jobs []*job) {
+	workers := e.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Jobs referencing named blocks take their results from them.
+	leaders := slices.DeleteFunc(slices.Clone(jobs), func(j *job) bool { return j.target != nil })
+	var dups map[*job]*job
+	if e.Deduplicate {
+		leaders, dups = dedupe(leaders)
+	}
+	ctx = e.runBatches(ctx, leaders)
+
+	work := make(chan *job)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				j.output, j.err = e.runJob(ctx, j)
+			}
+		}()
+	}
+	for _, j := range leaders {
+		if j.err != nil {
+			// Failed to resolve at transform time.
+			continue
+		}
+		work <- j
+	}
+	close(work)
+	wg.Wait()
+
+	if len(dups) > 0 {
+		e.fillDuplicates(jobs, dups)
+	}
+	fillReferences(jobs)
+
+	// Report errors in document order.
+	for _, j := range jobs {
+		if j.err == nil {
+			continue
+		}
+		e.logger().Warn("pipefence: pipe failed", "language", j.info.Language, "line", j.line, "error", j.err)
+		if e.OnError != nil {
+			e.OnError(j.err, j.info.Language, j.pos)
+		}
+	}
+}
+
+// fillDuplicates copies the results of the deduplicated jobs to
+// their duplicates.  With SVGUseReferences, SVG duplicates reference
+// the first occurrence instead of repeating it.
+func (e *Extension) fillDuplicates(jobs []*job, dups map[*job]*job) {
+	refs := make(map[*job][]byte)
+	for _, j := range jobs {
+		l, ok := dups[j]
 		if !ok {
 			continue
 		}
+		j.output, j.err, j.assets, j.deps, j.warnings = l.output, l.err, l.assets, l.deps, l.warnings
+		j.duration, j.cache = l.duration, cacheDuplicate
+		if !e.SVGUseReferences || l.err != nil {
+			continue
+		}
+		ref, ok := refs[l]
+		if !ok {
+			id := "pipefence-" + cacheKey(l.info, l.content)[:12]
+			var first []byte
+			if first, ref, ok = svgUseReference(l.output, id); ok {
+				l.output = first
+			}
+			refs[l] = ref
+		}
+		if ref != nil {
+			j.output = ref
+		}
+	}
+}
 
-		parent := fb.Parent()
-		doc.ReplaceChild(parent, fb, &pfBlock{
-			FencedCodeBlock: *fb,
-		})
+// runJob runs the pipe function of a single job.
+func (e *Extension) runJob(ctx context.Context, j *job) ([]byte, error) {
+	lang := j.info.Language
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("fenced block transformer %q: %w", lang, err)
+	}
+	limit := e.sizeLimit(lang)
+	if limit.Input > 0 && len(j.content) > limit.Input {
+		return nil, &SizeError{Language: lang, Line: j.line, Size: len(j.content), Limit: limit.Input}
 	}
+	pipeFunc := recovering(j, j.pipeFunc)
+	if limit.Output > 0 {
+		pipeFunc = limitOutput(pipeFunc, j, limit.Output)
+	}
+	if d := e.timeout(lang); d > 0 {
+		recovered := pipeFunc
+		pipeFunc = func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+			return runWithTimeout(ctx, d, j, func(ctx context.Context) ([]byte, error) {
+				return recovered(ctx, src, info)
+			})
+		}
+	}
+	if cs, ok := e.ColorSchemes[lang]; ok {
+		light, err := e.runPresented(ctx, j, pipeFunc, cs.Light)
+		if err != nil {
+			return nil, err
+		}
+		dark, err := e.runPresented(ctx, j, pipeFunc, cs.Dark)
+		if err != nil {
+			return nil, err
+		}
+		return colorSchemes(light, dark, j.inline), nil
+	}
+	if densities := e.Srcsets[lang]; len(densities) > 0 {
+		return e.runSrcset(ctx, j, pipeFunc, densities)
+	}
+	return e.runPresented(ctx, j, pipeFunc, nil)
 }
 
-var pfKind = ast.NewNodeKind("PipefenceBlock")
+// runPresented runs pipeFunc for j, with the given options added to
+// those of the block, and returns the output as it is to be inlined
+// into the HTML.
+func (e *Extension) runPresented(ctx context.Context, j *job, pipeFunc PipeFuncCtx, options map[string]string) ([]byte, error) {
+	out, info, err := e.runVariant(ctx, j, pipeFunc, options)
+	if err != nil {
+		return nil, err
+	}
+	return e.present(out, info, j.inline)
+}
 
-// pfBlock is a fenced code block whose content needs to be
-// transformed.
-//
-// This is a thin wrapper around ast.FencedCodeBlock
-// so that we can register a special renderer for it.
-type pfBlock struct {
+// runVariant runs pipeFunc for j, with the given options added to
+// those of the block, and records the results other than the output
+// in j.  It returns the post-processed output and the Info passed to
+// the pipe.
+func (e *Extension) runVariant(ctx context.Context, j *job, pipeFunc PipeFuncCtx, options map[string]string) ([]byte, Info, error) {
+	lang := j.info.Language
+	info := j.info
+	if options != nil {
+		info.Options = maps.Clone(info.Options)
+		if info.Options == nil {
+			info.Options = make(map[string]string)
+		}
+		maps.Copy(info.Options, options)
+	}
+	start := time.Now()
+	runCtx := ctx
+	var endSpan func(PipeObservation)
+	if e.Tracer != nil {
+		runCtx, endSpan = e.Tracer.StartPipe(runCtx, lang)
+	}
+	res, cached, err := e.run(runCtx, pipeFunc, j.content, info, j.inline)
+	out := res.out
+	duration := time.Since(start)
+	j.assets = append(j.assets, res.assets...)
+	j.deps = append(j.deps, res.deps...)
+	j.warnings = append(j.warnings, res.warnings...)
+	j.duration += duration
+	if status := e.cacheStatus(cached, err); j.cache == "" || status != cacheHit {
+		// Blocks with several variants, such as ColorSchemes,
+		// count as cache hits only if all their variants are.
+		j.cache = status
+	}
+	obs := PipeObservation{
+		Language:   lang,
+		Duration:   duration,
+		InputSize:  len(j.content),
+		OutputSize: len(out),
+		CacheHit:   cached,
+		Err:        err,
+	}
+	if e.Metrics != nil {
+		e.Metrics.ObservePipe(obs)
+	}
+	if endSpan != nil {
+		endSpan(obs)
+	}
+	var (
+		terr *TimeoutError
+		perr *PanicError
+		serr *SizeError
+	)
+	if errors.As(err, &terr) || errors.As(err, &perr) || errors.As(err, &serr) {
+		return nil, info, err
+	}
+	if err != nil {
+		return nil, info, fmt.Errorf("fenced block transformer %q: %w", lang, err)
+	}
+	for _, post := range e.PostProcess {
+		out, err = post(lang, out)
+		if err != nil {
+			return nil, info, fmt.Errorf("fenced block transformer %q: post-processing: %w", lang, err)
+		}
+	}
+	if e.WarnOutputSize > 0 && len(out) > e.WarnOutputSize {
+		j.warnings = append(j.warnings, fmt.Sprintf("output of %d bytes exceeds %d bytes", len(out), e.WarnOutputSize))
+	}
+	return out, info, nil
+}
+
+// present returns the output of a pipe as it is to be inlined into
+// the HTML: image outputs are made accessible or written via the
+// AssetWriter, other outputs are sanitized and isolated.
+func (e *Extension) present(out []byte, info Info, inline bool) ([]byte, error) {
+	lang := info.Language
+	mimeType := e.outputType(lang, out)
+	binary := isBinary(mimeType)
+	var href []byte
+	if alt := altText(info); binary || mimeType == "image/svg+xml" {
+		if e.RequireAlt && alt == "" {
+			return nil, fmt.Errorf("fenced block transformer %q: %w", lang, ErrNoAltText)
+		}
+		if e.Accessible && !binary {
+			out = accessibleSVG(out, alt)
+		}
+		if e.LinkAssets && e.AssetWriter != nil {
+			url, err := e.AssetWriter.WriteAsset(assetName(out), out)
+			if err != nil {
+				return nil, fmt.Errorf("fenced block transformer %q: writing asset: %w", lang, err)
+			}
+			href = util.URLEscape([]byte(url), false)
+		}
+	}
+	switch {
+	case e.AssetWriter != nil && (binary || len(out) >= e.AssetMinSize):
+		var err error
+		out, err = e.externalize(out, info)
+		if err != nil {
+			return nil, fmt.Errorf("fenced block transformer %q: writing asset: %w", lang, err)
+		}
+	case binary:
+		out = dataURIImage(mimeType, out, info)
+	default:
+		if e.Sanitize != nil {
+			out = e.Sanitize(out)
+		}
+		iso, err := e.isolation(info)
+		if err != nil {
+			return nil, err
+		}
+		out = isolate(out, iso, inline)
+	}
+	if href != nil {
+		out = linkTag(href, out)
+	}
+	return out, nil
+}
+
+// job is a pending invocation of a pipe function, together with
+// its result.
+type job struct {
+	info     Info
+	line     int          // Line number of the block, starting at 1.
+	pos      text.Segment // Source range of the content.
+	content  []byte
+	pipeFunc PipeFuncCtx
+	output   []byte
+	err      error
+	assets   []string // Assets required by the output.
+	inline   bool     // Whether the job is for a code span.
+	warnings []string // Warnings to report.
+	ref      string   // Name of the block whose output to show.
+	target   *job     // Job of the block named ref.
+	deps     []string // Files the output depends on.
+	source   []byte   // Content without prelude and postlude.
+
+	// For annotations.
+	pipe     string
+	duration time.Duration
+	cache    string
+}
+
+// KindBlock is the node kind of Blocks.  It is shared by all
+// Extensions: each Block refers to the Extension which created it,
+// and is rendered according to that Extension, so that several
+// Extensions with different pipes can be used in one
+// goldmark.Markdown.
+var KindBlock = ast.NewNodeKind("PipefenceBlock")
+
+// kindInline is the node kind of piped code spans, shared likewise.
+var kindInline = ast.NewNodeKind("PipefenceInline")
+
+// Block is a fenced code block which is piped through a pipe
+// function.  The transformer of an Extension replaces such blocks
+// in the AST, so that other extensions and AST tools can find and
+// inspect them, e.g. to collect the figure captions.  Their kind is
+// KindBlock.
+type Block struct {
 	ast.FencedCodeBlock
+	job
+
+	ext *Extension // The Extension which created the block.
+
+	// figure is the figure number, or zero if not numbered.
+	figure int
 }
 
-func (b *pfBlock) IsRaw() bool        { return true }
-func (b *pfBlock) Kind() ast.NodeKind { return pfKind }
-func (b *pfBlock) RawContent(src []byte) []byte {
-	lines := b.Lines()
-	var buf bytes.Buffer
-	for i := 0; i < lines.Len(); i++ {
-		line := lines.At(i)
-		buf.Write(line.Value(src))
+// blockLine returns the line number of the opening code fence of fb,
+// starting at 1.
+func blockLine(fb *ast.FencedCodeBlock, src []byte) int {
+	var pos int
+	switch {
+	case fb.Info != nil:
+		pos = fb.Info.Segment.Start
+	case fb.Lines().Len() > 0:
+		// The fence is on the line before the content.
+		pos = fb.Lines().At(0).Start - 1
+	}
+	if pos < 0 {
+		pos = 0
 	}
-	return buf.Bytes()
+	return bytes.Count(src[:pos], []byte("\n")) + 1
 }
 
-// pfRenderer renders pfBlocks by piping them through one of the
-// PipeFuncs.
-type pfRenderer struct {
-	ext *Extension
+// blockSegment returns the source range of the content of fb.  For
+// empty blocks, it is the range of the info string.
+func blockSegment(fb *ast.FencedCodeBlock) text.Segment {
+	lines := fb.Lines()
+	if lines.Len() == 0 {
+		if fb.Info != nil {
+			return fb.Info.Segment
+		}
+		return text.Segment{}
+	}
+	return text.NewSegment(lines.At(0).Start, lines.At(lines.Len()-1).Stop)
 }
 
-func (r *pfRenderer) RegisterFuncs(registry renderer.NodeRendererFuncRegisterer) {
-	renderFenced := func(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
-		fb := node.(*pfBlock)
-		lang := string(fb.Language(src))
-		pipeFunc, ok := r.ext.PipeFuncs[lang]
-		if !ok {
-			return ast.WalkContinue, nil
+// PipeInfo returns the parsed info string of the block.  (Info is
+// the field of the embedded ast.FencedCodeBlock.)
+func (b *Block) PipeInfo() Info { return b.info }
+
+// Line returns the line of the opening code fence, starting at 1.
+func (b *Block) Line() int { return b.line }
+
+// Output returns the output of the pipe function, or nil if it
+// failed.
+func (b *Block) Output() []byte { return b.output }
+
+// Err returns the error of the pipe function, or nil.
+func (b *Block) Err() error { return b.err }
+
+// Figure returns the figure number of the block, or zero if it is
+// not a numbered figure.
+func (b *Block) Figure() int { return b.figure }
+
+func (b *Block) IsRaw() bool        { return true }
+func (b *Block) Kind() ast.NodeKind { return KindBlock }
+
+// RawContent returns the content of the block, which may share
+// memory with src.
+func (b *Block) RawContent(src []byte) []byte {
+	return segmentsValue(b.Lines(), src)
+}
+
+// segmentsValue returns the concatenated values of lines.  If the
+// lines are contiguous in src, as those of most fenced code blocks
+// are, it returns a sub-slice of src without copying, whose capacity
+// is limited so that appending to it does not modify src.
+func segmentsValue(lines *text.Segments, src []byte) []byte {
+	n := lines.Len()
+	if n == 0 {
+		return nil
+	}
+	contiguous := true
+	size := 0
+	for i := 0; i < n; i++ {
+		line := lines.At(i)
+		size += line.Len()
+		if line.Padding > 0 || i > 0 && line.Start != lines.At(i-1).Stop {
+			contiguous = false
 		}
+	}
+	if contiguous {
+		start, stop := lines.At(0).Start, lines.At(n-1).Stop
+		return src[start:stop:stop]
+	}
+	buf := make([]byte, 0, size)
+	for i := 0; i < n; i++ {
+		line := lines.At(i)
+		buf = line.ConcatPadding(buf)
+		buf = append(buf, src[line.Start:line.Stop]...)
+	}
+	return buf
+}
 
+// pfRenderer renders Blocks and piped code spans by writing the
+// output of their PipeFuncs, as configured in the Extensions which
+// created them.
+type pfRenderer struct{}
+
+func (r *pfRenderer) RegisterFuncs(registry renderer.NodeRendererFuncRegisterer) {
+	renderFenced := func(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 		if !entering {
 			return ast.WalkContinue, nil
 		}
 
-		content, err := pipeFunc(fb.RawContent(src))
-		if err != nil {
-			return ast.WalkStop, fmt.Errorf("fenced block transformer %q: %v", lang, err)
+		fb := node.(*Block)
+		e := fb.ext
+		if e.Annotate && fb.cache != "" && (fb.err == nil || e.ErrorMode != FailFast) {
+			writeAnnotation(w, &fb.job)
+			defer w.WriteString(annotationEnd)
+		}
+		if fb.err != nil {
+			switch e.ErrorMode {
+			case RenderOriginalBlock:
+				source := fb.source
+				if source == nil {
+					source = fb.RawContent(src)
+				}
+				writeOriginalBlock(w, fb.info.Language, source)
+				return ast.WalkSkipChildren, nil
+			case RenderErrorInline:
+				writeErrorBlock(w, fb.err)
+				return ast.WalkSkipChildren, nil
+			default:
+				return ast.WalkStop, fb.err
+			}
+		}
+		output := fb.output
+		if e.WrapElement != "" && fb.info.Attributes != nil {
+			output = wrapElement(e.WrapElement, fb.info.Attributes, output)
+		}
+		if f := e.figureOptions(fb.info.Language); f != nil {
+			writeFigure(w, f, fb.figure, fb.info.Options["caption"], output)
+		} else {
+			w.Write(output)
+		}
+		if summary := e.sourceSummary(fb.info); summary != "" {
+			source := fb.source
+			if source == nil {
+				source = fb.content
+			}
+			writeSourceDetails(w, summary, fb.info.Language, source)
 		}
-		w.Write(content)
 		return ast.WalkSkipChildren, nil
 	}
-	registry.Register(pfKind, renderFenced)
+	registry.Register(KindBlock, renderFenced)
+	registry.Register(kindInline, r.renderInline)
 }
@@ -2,10 +2,20 @@ package pipefence_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 
 	pipefence "github.com/gnoack/goldmark-pipefence"
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/text"
 )
 
 func TestPipefence(t *testing.T) {
@@ -52,3 +62,532 @@ func TestPipefence(t *testing.T) {
 		})
 	}
 }
+
+func TestPipefenceContext(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "faa"))
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"banana": func(ctx context.Context, a []byte, _ pipefence.Info) ([]byte, error) {
+				return []byte(ctx.Value(key{}).(string)), nil
+			},
+		},
+		Context: ctx,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	var buf bytes.Buffer
+	err := gmark.Convert([]byte("```banana\nfoo\n```\n"), &buf)
+	if err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got, want := buf.String(), "faa"; got != want {
+		t.Errorf("gmark.Convert() = %q, want %q", got, want)
+	}
+
+	cancel()
+	buf.Reset()
+	err = gmark.Convert([]byte("```banana\nfoo\n```\n"), &buf)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("gmark.Convert() after cancel: err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPipefenceInfo(t *testing.T) {
+	var got pipefence.Info
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"pikchr": func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+				got = info
+				return nil, nil
+			},
+		},
+	}))
+
+	input := "```pikchr width=400 caption=\"Two boxes\" dark\nbox\n```\n"
+	if err := gmark.Convert([]byte(input), io.Discard); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	want := pipefence.Info{
+		Raw:      `pikchr width=400 caption="Two boxes" dark`,
+		Language: "pikchr",
+		Options: map[string]string{
+			"width":   "400",
+			"caption": "Two boxes",
+			"dark":    "",
+		},
+		Line: 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PipeFuncCtx got info %+v, want %+v", got, want)
+	}
+}
+
+func TestPipefenceErrorMode(t *testing.T) {
+	for _, tt := range []struct {
+		Name    string
+		Mode    pipefence.ErrorMode
+		Want    string
+		WantErr bool
+	}{
+		{
+			Name:    "FailFast",
+			Mode:    pipefence.FailFast,
+			WantErr: true,
+		},
+		{
+			Name: "RenderOriginalBlock",
+			Mode: pipefence.RenderOriginalBlock,
+			Want: "<pre><code class=\"language-broken\">a&lt;b\n</code></pre>\n",
+		},
+		{
+			Name: "RenderErrorInline",
+			Mode: pipefence.RenderErrorInline,
+			Want: "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">" +
+				"fenced block transformer &quot;broken&quot;: &lt;oops&gt;</pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+				PipeFuncs: map[string]pipefence.PipeFunc{
+					"broken": func(a []byte) ([]byte, error) {
+						return nil, errors.New("<oops>")
+					},
+				},
+				ErrorMode: tt.Mode,
+			}))
+
+			var buf bytes.Buffer
+			err := gmark.Convert([]byte("```broken\na<b\n```\n"), &buf)
+			if (err != nil) != tt.WantErr {
+				t.Fatalf("gmark.Convert: err = %v, want error: %v", err, tt.WantErr)
+			}
+			if got := buf.String(); !tt.WantErr && got != tt.Want {
+				t.Errorf("gmark.Convert() = %q, want %q", got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestPipefenceDefaultPipe(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+			},
+		},
+		DefaultPipe: func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+			return []byte("<default lang=\"" + info.Language + "\">"), nil
+		},
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "ExplicitEntryWins",
+			Input: "```banana\nfoo\n```\n",
+			Want:  "faa\n",
+		},
+		{
+			Name:  "UnknownLanguage",
+			Input: "```unknown\nfoo\n```\n",
+			Want:  "<default lang=\"unknown\">",
+		},
+		{
+			Name:  "NoLanguage",
+			Input: "```\nfoo\n```\n",
+			Want:  "<default lang=\"\">",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestPipefenceNested(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+			},
+		},
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "List",
+			Input: "- one\n\n  ```banana\n  foo\n  ```\n- two\n",
+			Want:  "<ul>\n<li>\n<p>one</p>\nfaa\n</li>\n<li>\n<p>two</p>\n</li>\n</ul>\n",
+		},
+		{
+			Name:  "Blockquote",
+			Input: "> ```banana\n> foo\n> ```\n",
+			Want:  "<blockquote>\nfaa\n</blockquote>\n",
+		},
+		{
+			Name:  "NestedContainers",
+			Input: "> - ```banana\n>   foo\n>   ```\n>\n>   ```banana\n>   boo\n>   ```\n",
+			Want:  "<blockquote>\n<ul>\n<li>\nfaa\nbaa\n</li>\n</ul>\n</blockquote>\n",
+		},
+		{
+			Name:  "SiblingsAtTopLevel",
+			Input: "a\n\n```banana\nfoo\n```\n\nb\n",
+			Want:  "<p>a</p>\nfaa\n<p>b</p>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestPipefenceWorkers(t *testing.T) {
+	const n = 4
+	var wg sync.WaitGroup
+	wg.Add(n)
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				// Only returns once all n blocks are in flight.
+				wg.Done()
+				wg.Wait()
+				return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+			},
+		},
+		Workers: n,
+	}))
+
+	input := strings.Repeat("```banana\nfoo\n```\n", n-1) + "```banana\nboo\n```\n"
+	want := strings.Repeat("faa\n", n-1) + "baa\n"
+
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+}
+
+func TestPipefenceLogger(t *testing.T) {
+	var logs bytes.Buffer
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"broken": func(a []byte) ([]byte, error) {
+				return nil, errors.New("oops")
+			},
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+		Cache:     &pipefence.MemoryCache{},
+		Logger:    slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}))
+
+	if err := gmark.Convert([]byte("```broken\nfoo\n```\n"), io.Discard); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	for _, want := range []string{
+		`level=DEBUG msg="pipefence: cache miss" language=broken`,
+		`level=WARN msg="pipefence: pipe failed" language=broken line=1`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs = %q, want it to contain %q", logs.String(), want)
+		}
+	}
+}
+
+func TestPipefenceRegister(t *testing.T) {
+	ext := &pipefence.Extension{}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+	banana := func(_ context.Context, a []byte, _ pipefence.Info) ([]byte, error) {
+		return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+	}
+
+	convert := func() string {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte("```banana\nfoo\n```\n"), &buf); err != nil {
+			t.Errorf("gmark.Convert: %v", err)
+		}
+		return buf.String()
+	}
+
+	plain := "<pre><code class=\"language-banana\">foo\n</code></pre>\n"
+	if got := convert(); got != plain {
+		t.Errorf("before Register: got %q, want %q", got, plain)
+	}
+	ext.Register("banana", banana)
+	if got := convert(); got != "faa\n" {
+		t.Errorf("after Register: got %q, want %q", got, "faa\n")
+	}
+	ext.Unregister("banana")
+	if got := convert(); got != plain {
+		t.Errorf("after Unregister: got %q, want %q", got, plain)
+	}
+
+	// Registering concurrently with conversions must not race.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if got := convert(); got != plain && got != "faa\n" {
+					t.Errorf("concurrent conversion: got %q", got)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				ext.Register("banana", banana)
+				ext.Unregister("banana")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPipefenceOnError(t *testing.T) {
+	type report struct {
+		Lang    string
+		Content string
+	}
+	var reports []report
+	input := "# Doc\n\n```broken\nfirst\n```\n\n```banana\nfoo\n```\n\n```broken\nsecond\nblock\n```\n"
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) { return a, nil },
+			"broken": func(a []byte) ([]byte, error) { return nil, errors.New("oops") },
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+		OnError: func(err error, lang string, pos text.Segment) {
+			reports = append(reports, report{lang, string(pos.Value([]byte(input)))})
+		},
+	}))
+
+	if err := gmark.Convert([]byte(input), io.Discard); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	want := []report{
+		{"broken", "first\n"},
+		{"broken", "second\nblock\n"},
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("OnError reports = %+v, want %+v", reports, want)
+	}
+}
+
+func TestPipefenceSanitize(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"evil": func(a []byte) ([]byte, error) {
+				return []byte("<svg><script>alert(1)</script></svg>\n"), nil
+			},
+		},
+		Sanitize: func(b []byte) []byte {
+			return regexp.MustCompile(`<script>.*?</script>`).ReplaceAll(b, nil)
+		},
+	}))
+
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte("```evil\nfoo\n```\n"), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got, want := buf.String(), "<svg></svg>\n"; got != want {
+		t.Errorf("gmark.Convert() = %q, want %q", got, want)
+	}
+}
+
+func TestPipefencePostProcess(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"svg": func(a []byte) ([]byte, error) {
+				return []byte("<svg>  <g/>  </svg>\n"), nil
+			},
+			"bad": func(a []byte) ([]byte, error) {
+				return []byte("fail\n"), nil
+			},
+		},
+		PostProcess: []func(string, []byte) ([]byte, error){
+			func(lang string, out []byte) ([]byte, error) {
+				return bytes.ReplaceAll(out, []byte("  "), nil), nil
+			},
+			func(lang string, out []byte) ([]byte, error) {
+				if bytes.Contains(out, []byte("fail")) {
+					return nil, errors.New("refused")
+				}
+				return bytes.Replace(out, []byte("<svg>"), []byte(`<svg class="`+lang+`">`), 1), nil
+			},
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte("```svg\nfoo\n```\n"), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got, want := buf.String(), "<svg class=\"svg\"><g/></svg>\n"; got != want {
+		t.Errorf("gmark.Convert() = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := gmark.Convert([]byte("```bad\nfoo\n```\n"), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got, want := buf.String(), "post-processing: refused"; !strings.Contains(got, want) {
+		t.Errorf("gmark.Convert() = %q, want error %q", got, want)
+	}
+}
+
+type recordingMetrics struct {
+	mu  sync.Mutex
+	obs []pipefence.PipeObservation
+}
+
+func (m *recordingMetrics) ObservePipe(o pipefence.PipeObservation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.obs = append(m.obs, o)
+}
+
+func TestPipefenceMetrics(t *testing.T) {
+	metrics := &recordingMetrics{}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+			},
+		},
+		Cache:   &pipefence.MemoryCache{},
+		Metrics: metrics,
+	}))
+
+	input := "```banana\nfoo\n```\n\n```banana\nfoo\n```\n"
+	if err := gmark.Convert([]byte(input), io.Discard); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	if len(metrics.obs) != 2 {
+		t.Fatalf("got %d observations, want 2", len(metrics.obs))
+	}
+	for i, wantHit := range []bool{false, true} {
+		o := metrics.obs[i]
+		if o.Language != "banana" || o.InputSize != 4 || o.OutputSize != 4 || o.CacheHit != wantHit || o.Err != nil {
+			t.Errorf("observation %d = %+v, want banana, 4 bytes in and out, cache hit %v", i, o, wantHit)
+		}
+	}
+}
+
+type spanKey struct{}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (tr *recordingTracer) StartPipe(ctx context.Context, lang string) (context.Context, func(pipefence.PipeObservation)) {
+	return context.WithValue(ctx, spanKey{}, lang), func(o pipefence.PipeObservation) {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		tr.spans = append(tr.spans, fmt.Sprintf("%s hit=%v err=%v", lang, o.CacheHit, o.Err))
+	}
+}
+
+func TestPipefenceTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"span": func(ctx context.Context, _ []byte, _ pipefence.Info) ([]byte, error) {
+				// The pipe runs within its span.
+				return []byte(fmt.Sprint(ctx.Value(spanKey{}))), nil
+			},
+			"fail": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				return nil, errors.New("oops")
+			},
+		},
+		Cache:     &pipefence.MemoryCache{},
+		Tracer:    tracer,
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	var buf bytes.Buffer
+	input := "```span\nx\n```\n\n```span\nx\n```\n\n```fail\ny\n```\n"
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "spanspan") {
+		t.Errorf("gmark.Convert() = %q, want outputs with span context", buf.String())
+	}
+	want := []string{"span hit=false err=<nil>", "span hit=true err=<nil>", "fail hit=false err=oops"}
+	if !reflect.DeepEqual(tracer.spans, want) {
+		t.Errorf("spans = %q, want %q", tracer.spans, want)
+	}
+}
+
+func TestPipefenceMultipleExtensions(t *testing.T) {
+	fail := func([]byte) ([]byte, error) { return nil, errors.New("oops") }
+	gmark := goldmark.New(goldmark.WithExtensions(
+		&pipefence.Extension{
+			PipeFuncs: map[string]pipefence.PipeFunc{"a": fail},
+			ErrorMode: pipefence.RenderErrorInline,
+		},
+		&pipefence.Extension{
+			PipeFuncs: map[string]pipefence.PipeFunc{"b": fail},
+			ErrorMode: pipefence.RenderOriginalBlock,
+		},
+	))
+
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte("```a\nx\n```\n\n```b\ny\n```\n"), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	want := `<pre class="pipefence-error" style="border: 1px solid red; color: red; padding: 0.5em">fenced block transformer &quot;a&quot;: oops</pre>` + "\n" +
+		`<pre><code class="language-b">y` + "\n</code></pre>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert() = %q, want %q", got, want)
+	}
+}
+
+func TestPipefenceConcurrentExtensions(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+				PipeFuncs:       map[string]pipefence.PipeFunc{"upper": func(a []byte) ([]byte, error) { return bytes.ToUpper(a), nil }},
+				InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{"up": func(_ context.Context, a []byte, _ pipefence.Info) ([]byte, error) { return bytes.ToUpper(a), nil }},
+			}))
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte("```upper\nx\n```\n\n`up:y`\n"), &buf); err != nil {
+				t.Errorf("gmark.Convert: %v", err)
+			}
+			if got, want := buf.String(), "X\n<p>Y</p>\n"; got != want {
+				t.Errorf("gmark.Convert() = %q, want %q", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
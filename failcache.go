@@ -0,0 +1,53 @@
+package pipefence
+
+import (
+	"sync"
+	"time"
+)
+
+// failureCache remembers the errors of pipe invocations until they
+// expire.
+//
+// The zero value is ready to use.
+type failureCache struct {
+	mu      sync.Mutex
+	entries map[string]failure
+}
+
+type failure struct {
+	err     error
+	expires time.Time
+}
+
+// get returns the remembered error for key, if it has not expired.
+func (c *failureCache) get(key string, now time.Time) (error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(f.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return f.err, true
+}
+
+// set remembers err for key until now+ttl, and drops expired
+// entries.
+func (c *failureCache) set(key string, err error, now time.Time, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]failure)
+	}
+	for k, f := range c.entries {
+		if !now.Before(f.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = failure{err: err, expires: now.Add(ttl)}
+}
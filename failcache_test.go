@@ -0,0 +1,88 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestFailureTTL(t *testing.T) {
+	calls := 0
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"broken": func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				calls++
+				if bytes.HasPrefix(src, []byte("ok")) {
+					return src, nil
+				}
+				return nil, errors.New("syntax error")
+			},
+		},
+		ErrorMode:  pipefence.RenderErrorInline,
+		FailureTTL: 50 * time.Millisecond,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+	convert := func(input string) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(input), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		return buf.String()
+	}
+
+	bad := "```broken\nx\n```\n"
+	first := convert(bad)
+	if got := convert(bad); got != first {
+		t.Errorf("second conversion = %q, want %q", got, first)
+	}
+	if calls != 1 {
+		t.Errorf("pipe called %d times for identical failing blocks, want 1", calls)
+	}
+
+	// Blocks which succeed are not remembered.
+	convert("```broken\nok\n```\n")
+	convert("```broken\nok\n```\n")
+	if calls != 3 {
+		t.Errorf("pipe called %d times, want 3", calls)
+	}
+
+	// After the TTL, the pipe is retried.
+	time.Sleep(60 * time.Millisecond)
+	convert(bad)
+	if calls != 4 {
+		t.Errorf("pipe called %d times after the TTL, want 4", calls)
+	}
+}
+
+func TestFailureTTLCancelled(t *testing.T) {
+	calls := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"slow": func(ctx context.Context, _ []byte, _ pipefence.Info) ([]byte, error) {
+				calls++
+				cancel()
+				return nil, ctx.Err()
+			},
+		},
+		Context:    ctx,
+		ErrorMode:  pipefence.RenderErrorInline,
+		FailureTTL: time.Hour,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+	var buf bytes.Buffer
+	gmark.Convert([]byte("```slow\nx\n```\n"), &buf)
+
+	// Failures due to cancellation are not remembered.
+	ext.Context = context.Background()
+	gmark.Convert([]byte("```slow\nx\n```\n"), &buf)
+	if calls != 2 {
+		t.Errorf("pipe called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,60 @@
+package pipefence
+
+import (
+	"strconv"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// FigureOptions configures the wrapping of pipe outputs in
+// <figure> elements.
+//
+// The caption is taken from the caption option of the fenced code
+// block:
+//
+//	```dot caption="Deployment topology"
+//	digraph { a -> b }
+//	```
+type FigureOptions struct {
+	// Numbered enables auto-numbering of figures within a
+	// document.  Numbered figures always get a caption.
+	Numbered bool
+
+	// Label is the prefix for figure numbers.  If empty, "Figure"
+	// is used.
+	Label string
+}
+
+// figureOptions returns the figure options for the given language,
+// or nil if its blocks should not be wrapped.
+func (e *Extension) figureOptions(lang string) *FigureOptions {
+	if f, ok := e.Figures[lang]; ok {
+		return f
+	}
+	return e.Figure
+}
+
+// writeFigure writes content wrapped in a <figure> element.  Number
+// is the figure number, or zero if figures are not numbered.
+func writeFigure(w util.BufWriter, opts *FigureOptions, number int, caption string, content []byte) {
+	w.WriteString("<figure>\n")
+	w.Write(content)
+
+	if number == 0 && caption == "" {
+		w.WriteString("</figure>\n")
+		return
+	}
+	w.WriteString("<figcaption>")
+	if number > 0 {
+		label := opts.Label
+		if label == "" {
+			label = "Figure"
+		}
+		w.Write(util.EscapeHTML([]byte(label + " " + strconv.Itoa(number))))
+		if caption != "" {
+			w.WriteString(": ")
+		}
+	}
+	w.Write(util.EscapeHTML([]byte(caption)))
+	w.WriteString("</figcaption>\n</figure>\n")
+}
@@ -0,0 +1,81 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestFigure(t *testing.T) {
+	identity := func(a []byte) ([]byte, error) { return a, nil }
+
+	for _, tt := range []struct {
+		Name  string
+		Ext   *pipefence.Extension
+		Input string
+		Want  string
+	}{
+		{
+			Name: "NoFigureByDefault",
+			Ext: &pipefence.Extension{
+				PipeFuncs: map[string]pipefence.PipeFunc{"dot": identity},
+			},
+			Input: "```dot caption=\"Topology\"\n<svg/>\n```\n",
+			Want:  "<svg/>\n",
+		},
+		{
+			Name: "Caption",
+			Ext: &pipefence.Extension{
+				PipeFuncs: map[string]pipefence.PipeFunc{"dot": identity},
+				Figure:    &pipefence.FigureOptions{},
+			},
+			Input: "```dot caption=\"Deployment <topology>\"\n<svg/>\n```\n",
+			Want:  "<figure>\n<svg/>\n<figcaption>Deployment &lt;topology&gt;</figcaption>\n</figure>\n",
+		},
+		{
+			Name: "NoCaption",
+			Ext: &pipefence.Extension{
+				PipeFuncs: map[string]pipefence.PipeFunc{"dot": identity},
+				Figure:    &pipefence.FigureOptions{},
+			},
+			Input: "```dot\n<svg/>\n```\n",
+			Want:  "<figure>\n<svg/>\n</figure>\n",
+		},
+		{
+			Name: "Numbered",
+			Ext: &pipefence.Extension{
+				PipeFuncs: map[string]pipefence.PipeFunc{"dot": identity, "pikchr": identity},
+				Figures: map[string]*pipefence.FigureOptions{
+					"dot": {Numbered: true, Label: "Fig."},
+				},
+			},
+			Input: "```dot caption=A\n<a/>\n```\n\n```pikchr\n<p/>\n```\n\n```dot\n<b/>\n```\n",
+			Want: "<figure>\n<a/>\n<figcaption>Fig. 1: A</figcaption>\n</figure>\n" +
+				"<p/>\n" +
+				"<figure>\n<b/>\n<figcaption>Fig. 2</figcaption>\n</figure>\n",
+		},
+		{
+			Name: "PerLanguageDisable",
+			Ext: &pipefence.Extension{
+				PipeFuncs: map[string]pipefence.PipeFunc{"dot": identity},
+				Figure:    &pipefence.FigureOptions{},
+				Figures:   map[string]*pipefence.FigureOptions{"dot": nil},
+			},
+			Input: "```dot caption=A\n<svg/>\n```\n",
+			Want:  "<svg/>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			gmark := goldmark.New(goldmark.WithExtensions(tt.Ext))
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package gantt provides pipefence pipes rendering Gantt charts and
+// timelines as SVG.
+//
+// It is implemented in pure Go and needs no external tools.  The
+// syntax is a subset of the mermaid Gantt and timeline syntax, so
+// that simple charts do not need the mermaid toolchain:
+//
+//	title Release plan
+//	section Design
+//	Spec      :done, spec, 2024-01-01, 10d
+//	Review    :after spec, 5d
+//	section Build
+//	Implement :active, impl, 2024-01-16, 2w
+//	Release   :milestone, 2024-01-30, 0d
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"gantt":    gantt.Pipe,
+//			"timeline": gantt.TimelinePipe,
+//		},
+//	}
+//
+// The width of the chart area can be set with the width option of a
+// block.
+package gantt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark/util"
+)
+
+// Layout dimensions in SVG user units.
+const (
+	charWidth   = 7 // Approximate width of a character.
+	rowHeight   = 24
+	barHeight   = 16
+	padding     = 10
+	titleHeight = 28
+	axisHeight  = 20
+)
+
+// sectionColors are the background colors of alternating sections.
+var sectionColors = []string{"#f4f6fb", "#fbf8f1"}
+
+// Pipe is a pipefence.PipeFuncCtx rendering a Gantt chart.
+func Pipe(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	c, err := parseGantt(string(src))
+	if err != nil {
+		return nil, err
+	}
+	width := 600
+	if v, ok := info.Options["width"]; ok {
+		if width, err = strconv.Atoi(v); err != nil || width <= 0 {
+			return nil, fmt.Errorf("invalid width %q", v)
+		}
+	}
+	return c.render(width), nil
+}
+
+// textWidth estimates the width of a text.
+func textWidth(s string) int {
+	return utf8.RuneCountInString(s) * charWidth
+}
+
+func escape(s string) []byte {
+	return util.EscapeHTML([]byte(s))
+}
+
+// render renders the chart with a chart area of the given width.
+func (c *chart) render(width int) []byte {
+	start, end := c.tasks[0].start, c.tasks[0].end
+	labelWidth := 0
+	for _, t := range c.tasks {
+		if t.start.Before(start) {
+			start = t.start
+		}
+		if t.end.After(end) {
+			end = t.end
+		}
+		labelWidth = max(labelWidth, textWidth(t.name))
+	}
+	for _, s := range c.sections {
+		labelWidth = max(labelWidth, textWidth(s))
+	}
+	labelWidth += 2 * padding
+	days := max(end.Sub(start).Hours()/24, 1)
+	x := func(t time.Time) float64 {
+		return float64(labelWidth) + t.Sub(start).Hours()/24/days*float64(width)
+	}
+
+	// Rows: a header row per section, then its tasks.
+	type row struct {
+		label   string
+		section int
+		task    *task
+	}
+	var rows []row
+	for i := range c.tasks {
+		t := &c.tasks[i]
+		if t.section >= 0 && (i == 0 || c.tasks[i-1].section != t.section) {
+			rows = append(rows, row{label: c.sections[t.section], section: t.section})
+		}
+		rows = append(rows, row{label: t.name, section: t.section, task: t})
+	}
+
+	top := padding
+	if c.title != "" {
+		top += titleHeight
+	}
+	totalWidth := labelWidth + width + padding
+	height := top + len(rows)*rowHeight + axisHeight + padding
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" class="gantt" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		totalWidth, height, totalWidth, height)
+	if c.title != "" {
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle" font-size="16" font-weight="bold">%s</text>`+"\n",
+			totalWidth/2, padding+16, escape(c.title))
+	}
+
+	// Section backgrounds.
+	for i, r := range rows {
+		if r.task == nil {
+			n := 1
+			for i+n < len(rows) && rows[i+n].task != nil && rows[i+n].section == r.section {
+				n++
+			}
+			fmt.Fprintf(&buf, `<rect x="0" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+				top+i*rowHeight, totalWidth, n*rowHeight, sectionColors[r.section%len(sectionColors)])
+		}
+	}
+
+	// Axis with grid lines.
+	bottom := top + len(rows)*rowHeight
+	for _, tick := range ticks(start, end) {
+		tx := x(tick)
+		fmt.Fprintf(&buf, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#ddd"/>`+"\n", tx, top, tx, bottom)
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%d" text-anchor="middle" fill="#666">%s</text>`+"\n", tx, bottom+14, tick.Format("Jan 2"))
+	}
+
+	for i, r := range rows {
+		y := top + i*rowHeight
+		if r.task == nil {
+			fmt.Fprintf(&buf, `<text x="%d" y="%d" font-weight="bold">%s</text>`+"\n", padding, y+16, escape(r.label))
+			continue
+		}
+		fmt.Fprintf(&buf, `<text x="%d" y="%d">%s</text>`+"\n", padding, y+16, escape(r.label))
+
+		t := r.task
+		fill := "#4e79a7"
+		switch {
+		case t.done:
+			fill = "#a0b7cf"
+		case t.active:
+			fill = "#f28e2b"
+		}
+		stroke := ""
+		if t.crit {
+			stroke = ` stroke="#e15759" stroke-width="2"`
+		}
+		cy := float64(y + rowHeight/2)
+		if t.milestone {
+			mx := x(t.end)
+			const r = barHeight / 2
+			fmt.Fprintf(&buf, `<polygon points="%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="%s"%s/>`+"\n",
+				mx, cy-r, mx+r, cy, mx, cy+r, mx-r, cy, fill, stroke)
+			continue
+		}
+		fmt.Fprintf(&buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%d" rx="3" fill="%s"%s/>`+"\n",
+			x(t.start), cy-barHeight/2, x(t.end)-x(t.start), barHeight, fill, stroke)
+	}
+
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// ticks returns the dates of the axis ticks between start and end:
+// days, Mondays, or the first days of months or years, so that there
+// are at most 12.
+func ticks(start, end time.Time) []time.Time {
+	next := []func(time.Time) time.Time{
+		func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+		func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+		func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+		func(t time.Time) time.Time { return t.AddDate(0, 3, 0) },
+		func(t time.Time) time.Time { return t.AddDate(1, 0, 0) },
+	}
+	first := []func(time.Time) time.Time{
+		func(t time.Time) time.Time { return t },
+		func(t time.Time) time.Time { return t.AddDate(0, 0, (8-int(t.Weekday()))%7) },
+		func(t time.Time) time.Time { return firstOfMonth(t, 1) },
+		func(t time.Time) time.Time { return firstOfMonth(t, 3) },
+		func(t time.Time) time.Time { return firstOfMonth(t, 12) },
+	}
+	var result []time.Time
+	for i := range next {
+		result = result[:0]
+		for t := first[i](start); !t.After(end); t = next[i](t) {
+			result = append(result, t)
+		}
+		if len(result) <= 12 {
+			break
+		}
+	}
+	return result
+}
+
+// firstOfMonth returns the first day of the first month on or after
+// t whose index is a multiple of every.
+func firstOfMonth(t time.Time, every int) time.Time {
+	m := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if m.Before(t) {
+		m = m.AddDate(0, 1, 0)
+	}
+	for (int(m.Month())-1)%every != 0 {
+		m = m.AddDate(0, 1, 0)
+	}
+	return m
+}
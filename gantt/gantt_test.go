@@ -0,0 +1,106 @@
+package gantt_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/gantt"
+)
+
+func TestPipe(t *testing.T) {
+	for _, tt := range []struct {
+		Name    string
+		Input   string
+		Options map[string]string
+		Want    []string
+	}{
+		{
+			Name:  "Tasks",
+			Input: "gantt\nSpec   :done, spec, 2024-01-01, 10d\nReview :after spec, 5d\n",
+			Want: []string{
+				`<svg xmlns="http://www.w3.org/2000/svg" class="gantt" width="672" height="88" viewBox="0 0 672 88"`,
+				`<text x="10" y="26">Spec</text>`,
+				`<rect x="62.0" y="14.0" width="400.0" height="16" rx="3" fill="#a0b7cf"/>`,
+				`<rect x="462.0" y="38.0" width="200.0" height="16" rx="3" fill="#4e79a7"/>`,
+				`<line x1="342.0" y1="10" x2="342.0" y2="58" stroke="#ddd"/>`,
+				`<text x="342.0" y="72" text-anchor="middle" fill="#666">Jan 8</text>`,
+			},
+		},
+		{
+			Name: "Sections",
+			Input: "title Plan & <Schedule>\n" +
+				"section Design\nSpec :crit, 2024-01-01, 2024-01-03\n" +
+				"section Build\nCode :active, 1w\nShip :milestone, 2024-01-10\n",
+			Options: map[string]string{"width": "90"},
+			Want: []string{
+				`font-weight="bold">Plan &amp; &lt;Schedule&gt;</text>`,
+				`<rect x="0" y="38" width="162" height="48" fill="#f4f6fb"/>`,
+				`<text x="10" y="54" font-weight="bold">Design</text>`,
+				`<rect x="0" y="86" width="162" height="72" fill="#fbf8f1"/>`,
+				`<rect x="62.0" y="66.0" width="20.0" height="16" rx="3" fill="#4e79a7" stroke="#e15759" stroke-width="2"/>`,
+				`<rect x="82.0" y="114.0" width="70.0" height="16" rx="3" fill="#f28e2b"/>`,
+				`<polygon points="152.0,138.0 160.0,146.0 152.0,154.0 144.0,146.0" fill="#4e79a7"/>`,
+			},
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := gantt.Pipe(context.Background(), []byte(tt.Input), pipefence.Info{Options: tt.Options})
+			if err != nil {
+				t.Fatalf("Pipe(%q): %v", tt.Input, err)
+			}
+			for _, want := range tt.Want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Pipe(%q) = %s, want it to contain %s", tt.Input, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPipeErrors(t *testing.T) {
+	for _, tt := range []struct {
+		Input string
+		Want  string
+	}{
+		{Input: "title Empty\n", Want: "no tasks"},
+		{Input: "Spec\n", Want: `line 1: expected task definition, found "Spec"`},
+		{Input: "Spec : 5d\n", Want: `line 1: task "Spec" has no start`},
+		{Input: "Spec : after x, 5d\n", Want: `line 1: unknown task "x"`},
+		{Input: "Spec : 2024-01-01, 5y\n", Want: `line 1: parsing time "5y"`},
+		{Input: "Spec : 2024-01-01, -5d\n", Want: `line 1: invalid duration "-5d"`},
+		{Input: "Spec : 2024-01-05, 2024-01-01\n", Want: `line 1: task "Spec" ends before it starts`},
+		{Input: "dateFormat DD.MM.YYYY\n", Want: `line 1: unsupported date format "DD.MM.YYYY"`},
+	} {
+		_, err := gantt.Pipe(context.Background(), []byte(tt.Input), pipefence.Info{})
+		if err == nil || !strings.HasPrefix(err.Error(), tt.Want) {
+			t.Errorf("Pipe(%q) = %v, want %s", tt.Input, err, tt.Want)
+		}
+	}
+}
+
+func TestTimelinePipe(t *testing.T) {
+	input := "timeline\ntitle History\nsection Early\n2002 : LinkedIn\n2004 : Facebook : Google\n     : Flickr\nsection Later\n2005 : YouTube\n"
+	got, err := gantt.TimelinePipe(context.Background(), []byte(input), pipefence.Info{})
+	if err != nil {
+		t.Fatalf("TimelinePipe: %v", err)
+	}
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" class="timeline" width="440" height="186" viewBox="0 0 440 186"`,
+		`<text x="150" y="54" text-anchor="middle" font-weight="bold">Early</text>`,
+		`<text x="360" y="54" text-anchor="middle" font-weight="bold">Later</text>`,
+		`<rect x="154" y="62" width="132" height="28" rx="4" fill="#4e79a7"/>`,
+		`<rect x="294" y="62" width="132" height="28" rx="4" fill="#f28e2b"/>`,
+		`<text x="220" y="147" text-anchor="middle">Google</text>`,
+		`<text x="220" y="169" text-anchor="middle">Flickr</text>`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("TimelinePipe(%q) = %s, want it to contain %s", input, got, want)
+		}
+	}
+
+	if _, err := gantt.TimelinePipe(context.Background(), []byte(": orphan\n"), pipefence.Info{}); err == nil || err.Error() != "line 1: event without period" {
+		t.Errorf("TimelinePipe(orphan event) = %v, want error", err)
+	}
+}
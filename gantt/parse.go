@@ -0,0 +1,235 @@
+package gantt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayout is the format of dates in charts.
+const dateLayout = "2006-01-02"
+
+// task is a bar or milestone of a Gantt chart.
+type task struct {
+	name       string
+	section    int // Index of the section, or -1.
+	start, end time.Time
+	done       bool
+	active     bool
+	crit       bool
+	milestone  bool
+}
+
+// chart is a parsed Gantt chart.
+type chart struct {
+	title    string
+	sections []string
+	tasks    []task
+}
+
+// parseGantt parses a Gantt chart:
+//
+//	title Release plan
+//	section Design
+//	Spec   :done, spec, 2024-01-01, 10d
+//	Review :after spec, 5d
+//
+// Tasks are defined with optional tags (done, active, crit and
+// milestone), an optional id, a start and an end or duration.  The
+// start is a date or "after" an id; if it is missing, the task starts
+// after the previous one.  Durations are given in days or weeks.
+func parseGantt(src string) (*chart, error) {
+	c := &chart{}
+	ids := make(map[string]time.Time)
+	section := -1
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%%") || line == "gantt" {
+			continue
+		}
+		keyword, rest, _ := strings.Cut(line, " ")
+		switch keyword {
+		case "title":
+			c.title = strings.TrimSpace(rest)
+			continue
+		case "section":
+			c.sections = append(c.sections, strings.TrimSpace(rest))
+			section++
+			continue
+		case "dateFormat":
+			if f := strings.TrimSpace(rest); f != "YYYY-MM-DD" {
+				return nil, fmt.Errorf("line %d: unsupported date format %q", i+1, f)
+			}
+			continue
+		}
+
+		t, id, err := c.parseTask(line, ids)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		t.section = section
+		if id != "" {
+			ids[id] = t.end
+		}
+		c.tasks = append(c.tasks, t)
+	}
+	if len(c.tasks) == 0 {
+		return nil, fmt.Errorf("no tasks")
+	}
+	return c, nil
+}
+
+// parseTask parses a task definition.  It returns the task and its
+// id, if any.
+func (c *chart) parseTask(line string, ids map[string]time.Time) (task, string, error) {
+	name, spec, ok := strings.Cut(line, ":")
+	if !ok {
+		return task{}, "", fmt.Errorf("expected task definition, found %q", line)
+	}
+	t := task{name: strings.TrimSpace(name)}
+
+	var fields []string
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		switch f {
+		case "done":
+			t.done = true
+		case "active":
+			t.active = true
+		case "crit":
+			t.crit = true
+		case "milestone":
+			t.milestone = true
+		default:
+			fields = append(fields, f)
+		}
+	}
+
+	var id string
+	if len(fields) == 3 {
+		id, fields = fields[0], fields[1:]
+	}
+	switch len(fields) {
+	case 1:
+		if len(c.tasks) == 0 {
+			return task{}, "", fmt.Errorf("task %q has no start", t.name)
+		}
+		t.start = c.tasks[len(c.tasks)-1].end
+	case 2:
+		start, err := parseStart(fields[0], ids)
+		if err != nil {
+			return task{}, "", err
+		}
+		t.start = start
+	default:
+		return task{}, "", fmt.Errorf("invalid task %q", spec)
+	}
+
+	end, err := parseEnd(fields[len(fields)-1], t.start)
+	if err != nil {
+		return task{}, "", err
+	}
+	if end.Before(t.start) {
+		return task{}, "", fmt.Errorf("task %q ends before it starts", t.name)
+	}
+	t.end = end
+	return t, id, nil
+}
+
+// parseStart parses a start date, or "after" an id.
+func parseStart(s string, ids map[string]time.Time) (time.Time, error) {
+	if id, ok := strings.CutPrefix(s, "after "); ok {
+		end, ok := ids[strings.TrimSpace(id)]
+		if !ok {
+			return time.Time{}, fmt.Errorf("unknown task %q", strings.TrimSpace(id))
+		}
+		return end, nil
+	}
+	return time.Parse(dateLayout, s)
+}
+
+// parseEnd parses an end date, or a duration from start.
+func parseEnd(s string, start time.Time) (time.Time, error) {
+	if len(s) > 1 {
+		days := 0
+		switch s[len(s)-1] {
+		case 'd':
+			days = 1
+		case 'w':
+			days = 7
+		}
+		if days != 0 {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err != nil || n < 0 {
+				return time.Time{}, fmt.Errorf("invalid duration %q", s)
+			}
+			return start.AddDate(0, 0, n*days), nil
+		}
+	}
+	return time.Parse(dateLayout, s)
+}
+
+// period is a period of a timeline, with its events.
+type period struct {
+	name    string
+	section int // Index of the section, or -1.
+	events  []string
+}
+
+// timeline is a parsed timeline.
+type timeline struct {
+	title    string
+	sections []string
+	periods  []period
+}
+
+// parseTimeline parses a timeline:
+//
+//	title History
+//	section Early days
+//	2002 : LinkedIn
+//	2004 : Facebook : Google
+//	     : Flickr
+//
+// Lines starting with ":" add events to the previous period.
+func parseTimeline(src string) (*timeline, error) {
+	tl := &timeline{}
+	section := -1
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%%") || line == "timeline" {
+			continue
+		}
+		keyword, rest, _ := strings.Cut(line, " ")
+		switch keyword {
+		case "title":
+			tl.title = strings.TrimSpace(rest)
+			continue
+		case "section":
+			tl.sections = append(tl.sections, strings.TrimSpace(rest))
+			section++
+			continue
+		}
+
+		var parts []string
+		for j, part := range strings.Split(line, ":") {
+			if part = strings.TrimSpace(part); part != "" || j == 0 {
+				parts = append(parts, part)
+			}
+		}
+		if parts[0] == "" {
+			if len(tl.periods) == 0 {
+				return nil, fmt.Errorf("line %d: event without period", i+1)
+			}
+			p := &tl.periods[len(tl.periods)-1]
+			p.events = append(p.events, parts[1:]...)
+			continue
+		}
+		tl.periods = append(tl.periods, period{name: parts[0], section: section, events: parts[1:]})
+	}
+	if len(tl.periods) == 0 {
+		return nil, fmt.Errorf("no periods")
+	}
+	return tl, nil
+}
@@ -0,0 +1,90 @@
+package gantt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Timeline dimensions in SVG user units.
+const (
+	periodWidth  = 140
+	periodHeight = 28
+	eventHeight  = 22
+)
+
+// periodColors are the colors of the periods of alternating
+// sections, or of all periods without sections.
+var periodColors = []string{"#4e79a7", "#f28e2b", "#59a14f", "#b07aa1"}
+
+// TimelinePipe is a pipefence.PipeFuncCtx rendering a timeline.
+func TimelinePipe(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+	tl, err := parseTimeline(string(src))
+	if err != nil {
+		return nil, err
+	}
+	return tl.render(), nil
+}
+
+// render renders the periods side by side on a horizontal line, with
+// their events below.
+func (tl *timeline) render() []byte {
+	events := 0
+	for _, p := range tl.periods {
+		events = max(events, len(p.events))
+	}
+	top := padding
+	if tl.title != "" {
+		top += titleHeight
+	}
+	if len(tl.sections) > 0 {
+		top += rowHeight
+	}
+	width := len(tl.periods)*periodWidth + 2*padding
+	lineY := top + periodHeight + padding
+	height := lineY + padding + events*eventHeight + padding
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" class="timeline" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	if tl.title != "" {
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle" font-size="16" font-weight="bold">%s</text>`+"\n",
+			width/2, padding+16, escape(tl.title))
+	}
+
+	// Section labels above their periods.
+	for i, p := range tl.periods {
+		if p.section >= 0 && (i == 0 || tl.periods[i-1].section != p.section) {
+			n := 1
+			for i+n < len(tl.periods) && tl.periods[i+n].section == p.section {
+				n++
+			}
+			fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle" font-weight="bold">%s</text>`+"\n",
+				padding+i*periodWidth+n*periodWidth/2, top-8, escape(tl.sections[p.section]))
+		}
+	}
+
+	fmt.Fprintf(&buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#666" stroke-width="2"/>`+"\n",
+		padding, lineY, width-padding, lineY)
+	for i, p := range tl.periods {
+		color := periodColors[i%len(periodColors)]
+		if p.section >= 0 {
+			color = periodColors[p.section%len(periodColors)]
+		}
+		x := padding + i*periodWidth
+		cx := x + periodWidth/2
+		fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="%s"/>`+"\n",
+			x+4, top, periodWidth-8, periodHeight, color)
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle" fill="#fff">%s</text>`+"\n",
+			cx, top+periodHeight/2+4, escape(p.name))
+		fmt.Fprintf(&buf, `<circle cx="%d" cy="%d" r="5" fill="%s"/>`+"\n", cx, lineY, color)
+		for j, e := range p.events {
+			fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n",
+				cx, lineY+padding+j*eventHeight+15, escape(e))
+		}
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,197 @@
+// Package geojson provides a pipefence pipe for maps of GeoJSON
+// features.
+//
+// Maps are either rendered to static SVG on the server, in pure Go,
+// or embedded as interactive Leaflet maps with a tile layer in the
+// browser.
+//
+// Example:
+//
+//	m := &geojson.Map{Mode: geojson.ClientSide}
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"geojson": m.Pipe,
+//		},
+//	}
+//	// ... convert, then include ext.RequiredAssets(pc) in the page.
+//
+// The mode and the size can be overridden per block.  The mode
+// option is "server" or "client":
+//
+//	```geojson mode=server width=300 height=200
+//	{"type": "Point", "coordinates": [13.4, 52.5]}
+//	```
+//
+// Static maps show the features only, in the Web Mercator
+// projection, with the "name" properties of features as tooltips.
+package geojson
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Mode selects where maps are rendered.
+type Mode int
+
+const (
+	// ServerSide renders the features to a static SVG.
+	ServerSide Mode = iota
+
+	// ClientSide emits a container and a script showing the
+	// features on a Leaflet map.
+	ClientSide
+)
+
+// Defaults for the fields of Map.
+const (
+	DefaultScriptURL     = "https://unpkg.com/leaflet@1.9/dist/leaflet.js"
+	DefaultStylesheetURL = "https://unpkg.com/leaflet@1.9/dist/leaflet.css"
+	DefaultTileURL       = "https://tile.openstreetmap.org/{z}/{x}/{y}.png"
+	DefaultAttribution   = `&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors`
+)
+
+// Map renders GeoJSON maps.
+type Map struct {
+	// Mode selects server-side or client-side rendering.
+	Mode Mode
+
+	// Width and Height are the size of the map in pixels.  If zero,
+	// 600 by 400 is used.  In ClientSide mode, the map is as wide
+	// as its container and Width is ignored.  They can be
+	// overridden with the width and height options of a block.
+	Width, Height int
+
+	// TileURL and Attribution configure the tile layer in
+	// ClientSide mode.  If empty, DefaultTileURL and
+	// DefaultAttribution are used.
+	TileURL     string
+	Attribution string
+
+	// ScriptURL and StylesheetURL are the Leaflet files returned by
+	// Assets.  If empty, DefaultScriptURL and DefaultStylesheetURL
+	// are used.
+	ScriptURL     string
+	StylesheetURL string
+}
+
+// Pipe is a pipefence.PipeFuncCtx rendering a map of GeoJSON
+// features.
+func (m *Map) Pipe(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	mode := m.Mode
+	switch v := info.Options["mode"]; v {
+	case "":
+	case "server":
+		mode = ServerSide
+	case "client":
+		mode = ClientSide
+	default:
+		return nil, fmt.Errorf("invalid mode %q", v)
+	}
+	width, err := sizeOption(info, "width", m.Width, 600)
+	if err != nil {
+		return nil, err
+	}
+	height, err := sizeOption(info, "height", m.Height, 400)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj object
+	if err := json.Unmarshal(src, &obj); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	if mode == ClientSide {
+		pipefence.RequireAssets(ctx, m.files()...)
+		return m.embed(src, height, info)
+	}
+	shapes, err := obj.shapes("")
+	if err != nil {
+		return nil, err
+	}
+	return render(shapes, width, height), nil
+}
+
+// sizeOption returns the size from the given block option, or the
+// configured size, or the default.
+func sizeOption(info pipefence.Info, name string, size, def int) (int, error) {
+	if v, ok := info.Options[name]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid %s %q", name, v)
+		}
+		return n, nil
+	}
+	if size == 0 {
+		return def, nil
+	}
+	return size, nil
+}
+
+// embed returns a container with a script showing the features on a
+// Leaflet map.
+func (m *Map) embed(src []byte, height int, info pipefence.Info) ([]byte, error) {
+	var data bytes.Buffer
+	if err := json.Compact(&data, src); err != nil {
+		return nil, err
+	}
+	tiles, attribution := m.TileURL, m.Attribution
+	if tiles == "" {
+		tiles = DefaultTileURL
+	}
+	if attribution == "" {
+		attribution = DefaultAttribution
+	}
+	tilesJS, _ := json.Marshal(tiles)
+	attributionJS, _ := json.Marshal(map[string]string{"attribution": attribution})
+
+	sum := sha256.Sum256(src)
+	id := fmt.Sprintf("geojson-%s-%d", hex.EncodeToString(sum[:4]), info.Line)
+
+	var js bytes.Buffer
+	fmt.Fprintf(&js, "(function() {\n")
+	fmt.Fprintf(&js, "var m = L.map(%q);\n", id)
+	fmt.Fprintf(&js, "L.tileLayer(%s, %s).addTo(m);\n", tilesJS, attributionJS)
+	fmt.Fprintf(&js, "m.fitBounds(L.geoJSON(%s).addTo(m).getBounds(), {maxZoom: 14});\n", data.Bytes())
+	fmt.Fprintf(&js, "})();\n")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<div class=\"geojson\" id=\"%s\" style=\"height: %dpx\"></div>\n", id, height)
+	buf.WriteString("<script>\n")
+	// The data must not end the script element early.
+	buf.Write(bytes.ReplaceAll(js.Bytes(), []byte("</"), []byte(`<\/`)))
+	buf.WriteString("</script>\n")
+	return buf.Bytes(), nil
+}
+
+// Assets returns the URLs of the Leaflet files which need to be
+// included on pages with maps.  In ServerSide mode, no files are
+// needed, unless blocks select client-side rendering.  Pipe declares
+// the files with pipefence.RequireAssets for each map rendered on the
+// client, so that Extension.RequiredAssets reports them for the pages
+// which need them.
+func (m *Map) Assets() []string {
+	if m.Mode != ClientSide {
+		return nil
+	}
+	return m.files()
+}
+
+// files returns the Leaflet stylesheet and script.
+func (m *Map) files() []string {
+	css, js := m.StylesheetURL, m.ScriptURL
+	if css == "" {
+		css = DefaultStylesheetURL
+	}
+	if js == "" {
+		js = DefaultScriptURL
+	}
+	return []string{css, js}
+}
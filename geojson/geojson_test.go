@@ -0,0 +1,118 @@
+package geojson_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/geojson"
+	"github.com/yuin/goldmark"
+)
+
+func TestServerSide(t *testing.T) {
+	m := &geojson.Map{}
+	for _, tt := range []struct {
+		Name    string
+		Input   string
+		Options map[string]string
+		Want    []string
+	}{
+		{
+			Name:  "Point",
+			Input: `{"type": "Point", "coordinates": [13.4, 52.5]}`,
+			Want: []string{
+				`<svg xmlns="http://www.w3.org/2000/svg" class="geojson" width="600" height="400" viewBox="0 0 600 400">`,
+				`<circle cx="300.0" cy="200.0" r="5" fill-opacity="0.8"></circle>`,
+			},
+		},
+		{
+			Name:  "LineString",
+			Input: `{"type": "LineString", "coordinates": [[0, 0], [10, 0]]}`,
+			Want:  []string{`<path d="M10.0 200.0L590.0 200.0" fill="none"></path>`},
+		},
+		{
+			Name: "Feature",
+			Input: `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "<Square>"},
+				"geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]], [[0.5, 0.5], [0.6, 0.5], [0.5, 0.6], [0.5, 0.5]]]}}]}`,
+			Options: map[string]string{"width": "120", "height": "120"},
+			Want: []string{
+				`width="120" height="120"`,
+				`<path d="M10.0 110.0L110.0 110.0L110.0 10.0L10.0 110.0ZM60.0 60.0L70.0 60.0L60.0 50.0L60.0 60.0Z" fill-rule="evenodd"><title>&lt;Square&gt;</title></path>`,
+			},
+		},
+		{
+			Name:  "MultiPoint",
+			Input: `{"type": "GeometryCollection", "geometries": [{"type": "MultiPoint", "coordinates": [[0, 0], [1, 0]]}]}`,
+			Want:  []string{`<circle cx="10.0" cy="200.0"`, `<circle cx="590.0" cy="200.0"`},
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := m.Pipe(context.Background(), []byte(tt.Input), pipefence.Info{Options: tt.Options})
+			if err != nil {
+				t.Fatalf("Pipe(%q): %v", tt.Input, err)
+			}
+			for _, want := range tt.Want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Pipe(%q) = %s, want it to contain %s", tt.Input, got, want)
+				}
+			}
+		})
+	}
+
+	if got := m.Assets(); got != nil {
+		t.Errorf("m.Assets() = %q, want nil", got)
+	}
+}
+
+func TestClientSide(t *testing.T) {
+	m := &geojson.Map{Mode: geojson.ClientSide, TileURL: "https://tiles.example.com/{z}/{x}/{y}.png", Attribution: "Example"}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"geojson": m.Pipe,
+		},
+	}))
+
+	var buf bytes.Buffer
+	input := "```geojson height=300\n{\"type\": \"Point\",\n \"coordinates\": [1, 2]}\n```\n"
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	want := "<div class=\"geojson\" id=\"geojson-19cf64bd-1\" style=\"height: 300px\"></div>\n" +
+		"<script>\n(function() {\n" +
+		"var m = L.map(\"geojson-19cf64bd-1\");\n" +
+		"L.tileLayer(\"https://tiles.example.com/{z}/{x}/{y}.png\", {\"attribution\":\"Example\"}).addTo(m);\n" +
+		"m.fitBounds(L.geoJSON({\"type\":\"Point\",\"coordinates\":[1,2]}).addTo(m).getBounds(), {maxZoom: 14});\n" +
+		"})();\n</script>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+
+	want2 := []string{geojson.DefaultStylesheetURL, geojson.DefaultScriptURL}
+	if got := m.Assets(); !reflect.DeepEqual(got, want2) {
+		t.Errorf("m.Assets() = %q, want %q", got, want2)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	m := &geojson.Map{}
+	for _, tt := range []struct {
+		Input   string
+		Options map[string]string
+		Want    string
+	}{
+		{Input: `{`, Want: "invalid GeoJSON: unexpected end of JSON input"},
+		{Input: `{"type": "Circle"}`, Want: `unsupported GeoJSON type "Circle"`},
+		{Input: `{"type": "Point", "coordinates": [1]}`, Want: "invalid position [1]"},
+		{Input: `{"type": "LineString", "coordinates": [1, 2]}`, Want: "invalid LineString coordinates: "},
+		{Input: `{}`, Options: map[string]string{"mode": "map"}, Want: `invalid mode "map"`},
+		{Input: `{}`, Options: map[string]string{"width": "wide"}, Want: `invalid width "wide"`},
+	} {
+		_, err := m.Pipe(context.Background(), []byte(tt.Input), pipefence.Info{Options: tt.Options})
+		if err == nil || !strings.HasPrefix(err.Error(), tt.Want) {
+			t.Errorf("Pipe(%q) = %v, want %s", tt.Input, err, tt.Want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// object is a GeoJSON object: a feature collection, a feature or a
+// geometry.
+type object struct {
+	Type        string          `json:"type"`
+	Features    []object        `json:"features"`
+	Geometry    *object         `json:"geometry"`
+	Geometries  []object        `json:"geometries"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Properties  map[string]any  `json:"properties"`
+}
+
+// shape is a projected geometry.
+type shape struct {
+	kind  byte // 'p' for points, 'l' for lines, 'a' for polygons.
+	rings [][][2]float64
+	title string
+}
+
+// shapes returns the projected shapes of the object.  Title is the
+// name of the enclosing feature.
+func (o *object) shapes(title string) ([]shape, error) {
+	var (
+		point   []float64
+		line    [][]float64
+		lines   [][][]float64
+		polygon [][][][]float64
+	)
+	decode := func(v any) error {
+		if err := json.Unmarshal(o.Coordinates, v); err != nil {
+			return fmt.Errorf("invalid %s coordinates: %w", o.Type, err)
+		}
+		return nil
+	}
+
+	switch o.Type {
+	case "FeatureCollection":
+		var all []shape
+		for _, f := range o.Features {
+			s, err := f.shapes("")
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, s...)
+		}
+		return all, nil
+	case "Feature":
+		if o.Geometry == nil {
+			return nil, nil
+		}
+		name, _ := o.Properties["name"].(string)
+		return o.Geometry.shapes(name)
+	case "GeometryCollection":
+		var all []shape
+		for _, g := range o.Geometries {
+			s, err := g.shapes(title)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, s...)
+		}
+		return all, nil
+	case "Point":
+		if err := decode(&point); err != nil {
+			return nil, err
+		}
+		return newShapes('p', title, [][][]float64{{point}})
+	case "MultiPoint":
+		if err := decode(&line); err != nil {
+			return nil, err
+		}
+		lines = make([][][]float64, len(line))
+		for i, p := range line {
+			lines[i] = [][]float64{p}
+		}
+		return newShapes('p', title, lines)
+	case "LineString":
+		if err := decode(&line); err != nil {
+			return nil, err
+		}
+		return newShapes('l', title, [][][]float64{line})
+	case "MultiLineString":
+		if err := decode(&lines); err != nil {
+			return nil, err
+		}
+		return newShapes('l', title, lines)
+	case "Polygon":
+		if err := decode(&lines); err != nil {
+			return nil, err
+		}
+		return newShapes('a', title, lines)
+	case "MultiPolygon":
+		if err := decode(&polygon); err != nil {
+			return nil, err
+		}
+		var all []shape
+		for _, rings := range polygon {
+			s, err := newShapes('a', title, rings)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, s...)
+		}
+		return all, nil
+	}
+	return nil, fmt.Errorf("unsupported GeoJSON type %q", o.Type)
+}
+
+// newShapes returns the projected shapes for the given rings of
+// longitudes and latitudes.  Points and lines are one shape per
+// ring, polygons one shape with all rings.
+func newShapes(kind byte, title string, rings [][][]float64) ([]shape, error) {
+	projected := make([][][2]float64, len(rings))
+	for i, ring := range rings {
+		for _, pos := range ring {
+			if len(pos) < 2 {
+				return nil, fmt.Errorf("invalid position %v", pos)
+			}
+			projected[i] = append(projected[i], project(pos[0], pos[1]))
+		}
+	}
+	if kind == 'a' {
+		return []shape{{kind: kind, rings: projected, title: title}}, nil
+	}
+	shapes := make([]shape, len(projected))
+	for i, ring := range projected {
+		shapes[i] = shape{kind: kind, rings: [][][2]float64{ring}, title: title}
+	}
+	return shapes, nil
+}
+
+// maxLat is the latitude limit of the Web Mercator projection.
+const maxLat = 85.051129
+
+// project projects a position to Web Mercator coordinates, with y
+// pointing down.
+func project(lon, lat float64) [2]float64 {
+	lat = math.Max(-maxLat, math.Min(maxLat, lat))
+	y := math.Log(math.Tan(math.Pi/4 + lat*math.Pi/360))
+	return [2]float64{lon * math.Pi / 180, -y}
+}
+
+// padding is the space around the features in pixels.
+const padding = 10
+
+// render renders the shapes to an SVG of the given size, scaled to
+// fit.
+func render(shapes []shape, width, height int) []byte {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, s := range shapes {
+		for _, ring := range s.rings {
+			for _, p := range ring {
+				minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+				minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+			}
+		}
+	}
+	scale := math.Min(float64(width-2*padding)/(maxX-minX), float64(height-2*padding)/(maxY-minY))
+	if math.IsInf(scale, 0) || math.IsNaN(scale) {
+		// A single point, or no features at all.
+		scale = 1
+	}
+	// Center the features.
+	dx := float64(width)/2 - (minX+maxX)/2*scale
+	dy := float64(height)/2 - (minY+maxY)/2*scale
+	xy := func(p [2]float64) (float64, float64) {
+		return p[0]*scale + dx, p[1]*scale + dy
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" class="geojson" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	buf.WriteString(`<g fill="#3388ff" fill-opacity="0.2" stroke="#3388ff" stroke-width="2" stroke-linejoin="round" stroke-linecap="round">` + "\n")
+	for _, s := range shapes {
+		var title []byte
+		if s.title != "" {
+			title = []byte("<title>" + string(util.EscapeHTML([]byte(s.title))) + "</title>")
+		}
+		if s.kind == 'p' {
+			x, y := xy(s.rings[0][0])
+			fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="5" fill-opacity="0.8">%s</circle>`+"\n", x, y, title)
+			continue
+		}
+
+		var d bytes.Buffer
+		for _, ring := range s.rings {
+			for i, p := range ring {
+				x, y := xy(p)
+				cmd := 'L'
+				if i == 0 {
+					cmd = 'M'
+				}
+				fmt.Fprintf(&d, "%c%.1f %.1f", cmd, x, y)
+			}
+			if s.kind == 'a' {
+				d.WriteByte('Z')
+			}
+		}
+		attrs := ` fill-rule="evenodd"`
+		if s.kind == 'l' {
+			attrs = ` fill="none"`
+		}
+		fmt.Fprintf(&buf, `<path d="%s"%s>%s</path>`+"\n", d.Bytes(), attrs, title)
+	}
+	buf.WriteString("</g>\n</svg>\n")
+	return buf.Bytes()
+}
@@ -1,5 +1,5 @@
 module github.com/gnoack/goldmark-pipefence
 
-go 1.20
+go 1.21
 
 require github.com/yuin/goldmark v1.5.4
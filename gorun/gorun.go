@@ -0,0 +1,166 @@
+// Package gorun provides a pipefence pipe which compiles and runs Go
+// programs, for literate documentation that proves its examples
+// work.
+//
+// The pipe renders the program together with its captured standard
+// output.  Programs run with the permissions of the converting
+// process, so the pipe must only be used for trusted documents; it
+// is never enabled by default.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"go-run": gorun.Pipe(gorun.Options{Timeout: 5 * time.Second}),
+//		},
+//	}
+//
+// Each block is a complete program in package main:
+//
+//	```go-run
+//	package main
+//
+//	import "fmt"
+//
+//	func main() { fmt.Println("hello") }
+//	```
+//
+// Programs are built in a temporary module without network access,
+// so they can only import the standard library.  A build failure or
+// a non-zero exit status is reported as an error of the block.
+package gorun
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark/util"
+)
+
+// Defaults for the fields of Options.
+const (
+	DefaultTimeout   = 10 * time.Second
+	DefaultMaxOutput = 64 << 10
+)
+
+// Options configure the running of programs.
+type Options struct {
+	// GoCommand is the go binary used for building.  If empty, "go"
+	// is used.
+	GoCommand string
+
+	// Timeout limits the run time of a program, not including the
+	// build.  If zero, DefaultTimeout is used.
+	Timeout time.Duration
+
+	// MaxOutput limits the size of the captured output in bytes.
+	// If zero, DefaultMaxOutput is used.
+	MaxOutput int
+}
+
+// Pipe returns a pipe function which builds and runs a Go program,
+// and renders its source and its output.
+func Pipe(opts Options) pipefence.PipeFuncCtx {
+	if opts.GoCommand == "" {
+		opts.GoCommand = "go"
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxOutput == 0 {
+		opts.MaxOutput = DefaultMaxOutput
+	}
+	return func(ctx context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		out, err := run(ctx, src, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		buf.WriteString("<div class=\"go-run\">\n<pre><code class=\"language-go\">")
+		buf.Write(util.EscapeHTML(src))
+		buf.WriteString("</code></pre>\n<pre class=\"go-run-output\"><code>")
+		buf.Write(util.EscapeHTML(out))
+		buf.WriteString("</code></pre>\n</div>\n")
+		return buf.Bytes(), nil
+	}
+}
+
+// run builds the program in a temporary module and runs it, and
+// returns its standard output.
+func run(ctx context.Context, src []byte, opts Options) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "pipefence-gorun-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"go.mod":  "module example\n",
+		"main.go": string(src),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	bin := filepath.Join(dir, "main")
+	build := exec.CommandContext(ctx, opts.GoCommand, "build", "-o", bin, ".")
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off", "GOTOOLCHAIN=local", "CGO_ENABLED=0")
+	if out, err := build.CombinedOutput(); err != nil {
+		if msg := bytes.TrimSpace(out); len(msg) > 0 {
+			return nil, fmt.Errorf("build failed: %s", msg)
+		}
+		return nil, fmt.Errorf("build failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	stdout := &limitedBuffer{max: opts.MaxOutput}
+	stderr := &limitedBuffer{max: opts.MaxOutput}
+	cmd := exec.CommandContext(ctx, bin)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("program timed out after %v", opts.Timeout)
+	case err != nil:
+		if msg := bytes.TrimSpace(stderr.buf.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("program failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("program failed: %w", err)
+	case stdout.truncated:
+		return nil, fmt.Errorf("program output exceeds %d bytes", opts.MaxOutput)
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// limitedBuffer is a buffer which discards writes beyond its maximum
+// size.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.max - b.buf.Len(); len(p) > room {
+		p = p[:room]
+		b.truncated = true
+	}
+	b.buf.Write(p)
+	return n, nil
+}
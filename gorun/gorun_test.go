@@ -0,0 +1,70 @@
+package gorun_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/gorun"
+)
+
+func TestPipe(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds Go programs")
+	}
+	pipe := gorun.Pipe(gorun.Options{Timeout: 500 * time.Millisecond, MaxOutput: 100})
+
+	for _, tt := range []struct {
+		Name    string
+		Src     string
+		Want    string
+		WantErr string
+	}{
+		{
+			Name: "Hello",
+			Src:  "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"<hello>\") }\n",
+			Want: "<div class=\"go-run\">\n" +
+				"<pre><code class=\"language-go\">package main\n\nimport &quot;fmt&quot;\n\nfunc main() { fmt.Println(&quot;&lt;hello&gt;&quot;) }\n</code></pre>\n" +
+				"<pre class=\"go-run-output\"><code>&lt;hello&gt;\n</code></pre>\n" +
+				"</div>\n",
+		},
+		{
+			Name:    "BuildError",
+			Src:     "package main\n\nfunc main() { undefined() }\n",
+			WantErr: "build failed: ",
+		},
+		{
+			Name:    "ExitStatus",
+			Src:     "package main\n\nimport \"os\"\n\nfunc main() { os.Stderr.WriteString(\"oops\"); os.Exit(3) }\n",
+			WantErr: "program failed: exit status 3: oops",
+		},
+		{
+			Name:    "Timeout",
+			Src:     "package main\n\nimport \"time\"\n\nfunc main() { time.Sleep(time.Hour) }\n",
+			WantErr: "program timed out after 500ms",
+		},
+		{
+			Name:    "MaxOutput",
+			Src:     "package main\n\nimport (\n\t\"os\"\n\t\"strings\"\n)\n\nfunc main() { os.Stdout.WriteString(strings.Repeat(\"x\", 200)) }\n",
+			WantErr: "program output exceeds 100 bytes",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := pipe(context.Background(), []byte(tt.Src), pipefence.Info{})
+			if tt.WantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.WantErr) {
+					t.Errorf("pipe() = %v, want error %q", err, tt.WantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("pipe(): %v", err)
+			}
+			if string(got) != tt.Want {
+				t.Errorf("pipe() = %q, want %q", got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Package graphviz provides pipefence pipes for the graphviz layout
+// engines.
+//
+// The pipes run the graphviz binaries, which must be installed
+// separately, and clean up the resulting SVG so that it can be
+// inlined into HTML.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: graphviz.Pipes(graphviz.Options{Responsive: true}),
+//	}
+package graphviz
+
+import (
+	"context"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/svgpost"
+)
+
+// Engines are the graphviz layout engines supported by Pipes.
+var Engines = []string{"dot", "neato", "fdp", "sfdp", "circo", "twopi", "osage", "patchwork"}
+
+// Options configure the graphviz pipes.
+type Options struct {
+	// Responsive removes the fixed width and height from the SVG
+	// root element, so that the diagram scales with its container.
+	Responsive bool
+}
+
+// Pipe returns a pipe function which renders graphviz sources to
+// SVG using the given layout engine, e.g. "dot" or "neato".
+func Pipe(engine string, opts Options) pipefence.PipeFuncCtx {
+	run := pipefence.ExecPipe(engine, "-Tsvg")
+	cleanup := filter(opts)
+	return func(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		out, err := run(ctx, src, info)
+		if err != nil {
+			return nil, err
+		}
+		return cleanup(out), nil
+	}
+}
+
+// Pipes returns pipe functions for all Engines, keyed by the engine
+// name.  The result can be used as Extension.PipeFuncsCtx.
+func Pipes(opts Options) map[string]pipefence.PipeFuncCtx {
+	m := make(map[string]pipefence.PipeFuncCtx, len(Engines))
+	for _, e := range Engines {
+		m[e] = Pipe(e, opts)
+	}
+	return m
+}
+
+// filter returns the SVG post-processing for opts: stripping
+// everything before the <svg> root element and optionally making the
+// SVG responsive.
+func filter(opts Options) svgpost.Filter {
+	if !opts.Responsive {
+		return svgpost.StripProlog()
+	}
+	return svgpost.Chain(svgpost.StripProlog(), svgpost.Responsive())
+}
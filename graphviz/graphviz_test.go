@@ -0,0 +1,48 @@
+package graphviz
+
+import "testing"
+
+const dotOutput = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN"
+ "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<!-- Generated by graphviz version 2.43.0 (0)
+ -->
+<!-- Title: G Pages: 1 -->
+<svg width="62pt" height="116pt"
+ viewBox="0.00 0.00 62.00 116.00" xmlns="http://www.w3.org/2000/svg">
+<g id="graph0" class="graph"></g>
+</svg>
+`
+
+func TestFilter(t *testing.T) {
+	for _, tt := range []struct {
+		Name string
+		Opts Options
+		Want string
+	}{
+		{
+			Name: "StripProlog",
+			Want: `<svg width="62pt" height="116pt"
+ viewBox="0.00 0.00 62.00 116.00" xmlns="http://www.w3.org/2000/svg">
+<g id="graph0" class="graph"></g>
+</svg>
+`,
+		},
+		{
+			Name: "Responsive",
+			Opts: Options{Responsive: true},
+			Want: `<svg style="max-width: 100%; height: auto"
+ viewBox="0.00 0.00 62.00 116.00" xmlns="http://www.w3.org/2000/svg">
+<g id="graph0" class="graph"></g>
+</svg>
+`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			got := string(filter(tt.Opts)([]byte(dotOutput)))
+			if got != tt.Want {
+				t.Errorf("filter(%+v)() = %q, want %q", tt.Opts, got, tt.Want)
+			}
+		})
+	}
+}
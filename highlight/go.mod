@@ -0,0 +1,11 @@
+module github.com/gnoack/goldmark-pipefence/highlight
+
+go 1.21
+
+require (
+	github.com/alecthomas/chroma/v2 v2.14.0
+	github.com/gnoack/goldmark-pipefence v0.0.0-20261016025848-a08b4f0b800e
+	github.com/yuin/goldmark v1.5.4
+)
+
+require github.com/dlclark/regexp2 v1.11.0 // indirect
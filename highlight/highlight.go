@@ -0,0 +1,86 @@
+// Package highlight provides a pipefence pipe for syntax
+// highlighting with chroma.
+//
+// It is meant as the DefaultPipe, so that one Extension both pipes
+// the languages with registered pipes and highlights all other code
+// blocks:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: graphviz.Pipes(graphviz.Options{}),
+//		DefaultPipe:  highlight.Pipe(highlight.Options{Style: "github"}),
+//	}
+//
+// This package is a separate module, so that users of pipefence who
+// do not need it do not depend on chroma.
+package highlight
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Options configure the highlighting.
+type Options struct {
+	// Style is the name of the chroma style, e.g. "monokai".  If
+	// empty or unknown, chroma's fallback style is used.
+	Style string
+
+	// Classes emits CSS classes instead of inline styles.  The
+	// stylesheet can be written with WriteCSS.
+	Classes bool
+
+	// LineNumbers adds line numbers to all blocks.  It can be
+	// overridden with the linenos option of a block.
+	LineNumbers bool
+}
+
+// Pipe returns a pipe function which highlights the block content
+// according to its language.  Blocks in unknown languages are
+// rendered as plain text.
+func Pipe(opts Options) pipefence.PipeFuncCtx {
+	style := styleFor(opts)
+	return func(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		lexer := lexers.Get(info.Language)
+		if lexer == nil {
+			lexer = lexers.Fallback
+		}
+		lexer = chroma.Coalesce(lexer)
+
+		lineNumbers := opts.LineNumbers
+		if v, ok := info.Options["linenos"]; ok {
+			lineNumbers = v == "" || v == "true"
+		}
+		formatter := html.New(html.WithClasses(opts.Classes), html.WithLineNumbers(lineNumbers))
+
+		tokens, err := lexer.Tokenise(nil, string(src))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := formatter.Format(&buf, style, tokens); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+}
+
+// WriteCSS writes the stylesheet for the style in opts, for use with
+// Options.Classes.
+func WriteCSS(w io.Writer, opts Options) error {
+	return html.New(html.WithClasses(true)).WriteCSS(w, styleFor(opts))
+}
+
+func styleFor(opts Options) *chroma.Style {
+	if s := styles.Get(opts.Style); s != nil {
+		return s
+	}
+	return styles.Fallback
+}
@@ -0,0 +1,43 @@
+package highlight_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/highlight"
+	"github.com/yuin/goldmark"
+)
+
+func TestDefaultPipe(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"upper": func(a []byte) ([]byte, error) { return bytes.ToUpper(a), nil },
+		},
+		DefaultPipe: highlight.Pipe(highlight.Options{Classes: true}),
+	}))
+
+	var buf bytes.Buffer
+	input := "```upper\nfoo\n```\n\n```go\nfunc main() {}\n```\n"
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	got := buf.String()
+	if !strings.HasPrefix(got, "FOO\n") {
+		t.Errorf("gmark.Convert(%q) = %q, want the upper block first", input, got)
+	}
+	if !strings.Contains(got, `<span class="kd">func</span>`) {
+		t.Errorf("gmark.Convert(%q) = %q, want highlighted Go code", input, got)
+	}
+}
+
+func TestWriteCSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := highlight.WriteCSS(&buf, highlight.Options{Style: "monokai"}); err != nil {
+		t.Fatalf("WriteCSS: %v", err)
+	}
+	if !strings.Contains(buf.String(), ".chroma") {
+		t.Errorf("WriteCSS output has no .chroma rules: %q", buf.String())
+	}
+}
@@ -0,0 +1,112 @@
+package pipefence
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPOptions configure HTTPPipe.
+type HTTPOptions struct {
+	// Client is the HTTP client to use.  If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// Header holds additional request headers, e.g. for
+	// authentication.
+	Header http.Header
+
+	// ContentType is the request content type.  If empty,
+	// "text/plain; charset=utf-8" is used.
+	ContentType string
+
+	// Retries is the number of times a failed request is retried.
+	// Requests are retried on network errors, on 429 and on 5xx
+	// responses.
+	Retries int
+
+	// RetryBackoff is the delay before the first retry.  It
+	// doubles with each further retry.
+	RetryBackoff time.Duration
+
+	// MaxResponseSize limits the size of the response body in
+	// bytes.  If zero, DefaultMaxResponseSize is used.
+	MaxResponseSize int64
+}
+
+// DefaultMaxResponseSize is the response size limit of HTTPPipe if
+// HTTPOptions.MaxResponseSize is unset.
+const DefaultMaxResponseSize = 16 << 20
+
+// HTTPPipe returns a pipe function which POSTs the fenced code block
+// content to url and returns the response body.  This is useful
+// with rendering services such as Kroki.
+func HTTPPipe(url string, opts HTTPOptions) PipeFuncCtx {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	maxSize := opts.MaxResponseSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxResponseSize
+	}
+
+	return func(ctx context.Context, src []byte, _ Info) ([]byte, error) {
+		backoff := opts.RetryBackoff
+		for attempt := 0; ; attempt++ {
+			out, retry, err := httpPost(ctx, client, url, contentType, opts.Header, src, maxSize)
+			if err == nil || !retry || attempt >= opts.Retries {
+				return out, err
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// httpPost sends a single request.  It reports whether the request
+// may be retried if it failed.
+func httpPost(ctx context.Context, client *http.Client, url, contentType string, header http.Header, src []byte, maxSize int64) (out []byte, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(src))
+	if err != nil {
+		return nil, false, err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, true, fmt.Errorf("%s: reading response: %w", url, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		msg := body
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return nil, retry, fmt.Errorf("%s: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	if int64(len(body)) > maxSize {
+		return nil, false, fmt.Errorf("%s: response exceeds %d bytes", url, maxSize)
+	}
+	return body, false, nil
+}
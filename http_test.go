@@ -0,0 +1,75 @@
+package pipefence_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+func TestHTTPPipe(t *testing.T) {
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if failures > 0 {
+			failures--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte("<svg>" + string(body) + "</svg>"))
+	}))
+	defer srv.Close()
+
+	pipe := pipefence.HTTPPipe(srv.URL, pipefence.HTTPOptions{
+		Header:  http.Header{"Authorization": {"Bearer secret"}},
+		Retries: 2,
+	})
+	got, err := pipe(context.Background(), []byte("a->b"), pipefence.Info{})
+	if err != nil {
+		t.Fatalf("HTTPPipe: %v", err)
+	}
+	if want := "<svg>a->b</svg>"; string(got) != want {
+		t.Errorf("HTTPPipe() = %q, want %q", got, want)
+	}
+}
+
+func TestHTTPPipeErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bad":
+			http.Error(w, "syntax error in diagram", http.StatusBadRequest)
+		case "/big":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		}
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		Name    string
+		Path    string
+		MaxSize int64
+		WantErr string
+	}{
+		{"ClientError", "/bad", 0, "400 Bad Request: syntax error in diagram"},
+		{"TooLarge", "/big", 10, "response exceeds 10 bytes"},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			pipe := pipefence.HTTPPipe(srv.URL+tt.Path, pipefence.HTTPOptions{
+				MaxResponseSize: tt.MaxSize,
+				Retries:         3,
+			})
+			_, err := pipe(context.Background(), nil, pipefence.Info{})
+			if err == nil || !strings.Contains(err.Error(), tt.WantErr) {
+				t.Errorf("HTTPPipe: err = %v, want error containing %q", err, tt.WantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package pipefence
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// include returns the content of a block with the src option, read
+// from the file relative to e.IncludeRoot, and the path of the file.
+// The block itself must be empty.
+func (e *Extension) include(info Info, content []byte) ([]byte, string, error) {
+	name := info.Options["src"]
+	if len(bytes.TrimSpace(content)) > 0 {
+		return nil, "", fmt.Errorf("fenced block transformer %q: block with src=%q is not empty", info.Language, name)
+	}
+	data, path, err := readInclude(e.IncludeRoot, name)
+	if err != nil {
+		return nil, "", fmt.Errorf("fenced block transformer %q: include: %w", info.Language, err)
+	}
+	return data, path, nil
+}
+
+// errOutsideRoot is returned for included files outside of the
+// IncludeRoot.
+var errOutsideRoot = errors.New("outside of the include root")
+
+// readInclude reads the file name, which must be a relative
+// slash-separated path, below root, and returns its content and its
+// path.  Symbolic links are followed, as long as the file is below
+// root.
+func readInclude(root, name string) ([]byte, string, error) {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return nil, "", fmt.Errorf("%s: %w", name, errOutsideRoot)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, "", err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(realRoot, rel))
+	if err != nil {
+		return nil, "", err
+	}
+	if r, err := filepath.Rel(realRoot, path); err != nil || !filepath.IsLocal(r) {
+		return nil, "", fmt.Errorf("%s: %w", name, errOutsideRoot)
+	}
+	data, err := os.ReadFile(path)
+	return data, path, err
+}
@@ -0,0 +1,131 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	for name, content := range map[string]string{
+		"root/diagrams/arch.dot": "digraph {}\n",
+		"secret.txt":             "secret\n",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"echo": func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				return src, nil
+			},
+		},
+		IncludeRoot: root,
+		ErrorMode:   pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Name    string
+		Input   string
+		Want    string
+		WantErr string
+	}{
+		{
+			Name:  "Include",
+			Input: "```echo src=diagrams/arch.dot\n```\n",
+			Want:  "digraph {}\n",
+		},
+		{
+			Name:  "NoSrc",
+			Input: "```echo\ninline\n```\n",
+			Want:  "inline\n",
+		},
+		{
+			Name:    "NotEmpty",
+			Input:   "```echo src=diagrams/arch.dot\ninline\n```\n",
+			WantErr: "is not empty",
+		},
+		{
+			Name:    "Missing",
+			Input:   "```echo src=missing.dot\n```\n",
+			WantErr: "no such file",
+		},
+		{
+			Name:    "Traversal",
+			Input:   "```echo src=../secret.txt\n```\n",
+			WantErr: "outside of the include root",
+		},
+		{
+			Name:    "Absolute",
+			Input:   "```echo src=" + filepath.ToSlash(filepath.Join(dir, "secret.txt")) + "\n```\n",
+			WantErr: "outside of the include root",
+		},
+		{
+			Name:    "Symlink",
+			Input:   "```echo src=link.txt\n```\n",
+			WantErr: "outside of the include root",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			got := buf.String()
+			if tt.WantErr != "" {
+				if !strings.Contains(got, "pipefence-error") || !strings.Contains(got, tt.WantErr) {
+					t.Errorf("gmark.Convert(%q) = %q, want error containing %q", tt.Input, got, tt.WantErr)
+				}
+				return
+			}
+			if got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestIncludeOriginalBlock(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "arch.dot"), []byte("digraph {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"dot": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				return nil, errors.New("syntax error")
+			},
+		},
+		Preludes:    map[string][]byte{"dot": []byte("// prelude\n")},
+		IncludeRoot: root,
+		ErrorMode:   pipefence.RenderOriginalBlock,
+	}))
+
+	input := "```dot src=arch.dot\n```\n"
+	want := "<pre><code class=\"language-dot\">digraph {}\n</code></pre>\n"
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package pipefence
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// indentedAsFenced returns a detached fenced code block equivalent
+// to the indented code block cb, if its first line is a directive
+// such as "%%dot width=400" starting with e.IndentedCodeMarker.
+// The directive, minus the marker, becomes the info string.
+// Otherwise, indentedAsFenced returns nil.
+func (e *Extension) indentedAsFenced(cb *ast.CodeBlock, src []byte) *ast.FencedCodeBlock {
+	lines := cb.Lines()
+	if e.IndentedCodeMarker == "" || lines.Len() == 0 {
+		return nil
+	}
+	first := lines.At(0)
+	if first.Padding != 0 || !bytes.HasPrefix(first.Value(src), []byte(e.IndentedCodeMarker)) {
+		return nil
+	}
+
+	info := text.NewSegment(first.Start+len(e.IndentedCodeMarker), first.Stop)
+	info = info.TrimRightSpace(src)
+	fb := ast.NewFencedCodeBlock(ast.NewTextSegment(info))
+	rest := text.NewSegments()
+	for i := 1; i < lines.Len(); i++ {
+		rest.Append(lines.At(i))
+	}
+	fb.SetLines(rest)
+	return fb
+}
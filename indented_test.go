@@ -0,0 +1,58 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestIndentedCodeMarker(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"banana": func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+				return append([]byte(info.Options["id"]+":"), bytes.ReplaceAll(a, []byte("o"), []byte("a"))...), nil
+			},
+		},
+		IndentedCodeMarker: "%%",
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Directive",
+			Input: "    %%banana id=1\n    foo\n    boo\n",
+			Want:  "1:faa\nbaa\n",
+		},
+		{
+			Name:  "NoDirective",
+			Input: "    foo\n",
+			Want:  "<pre><code>foo\n</code></pre>\n",
+		},
+		{
+			Name:  "UnknownLanguage",
+			Input: "    %%unknown\n    foo\n",
+			Want:  "<pre><code>%%unknown\nfoo\n</code></pre>\n",
+		},
+		{
+			Name:  "FencedStillWorks",
+			Input: "```banana id=2\nfoo\n```\n",
+			Want:  "2:faa\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
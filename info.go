@@ -0,0 +1,154 @@
+package pipefence
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Info describes the info string of a fenced code block, i.e. the
+// text following the opening code fence.
+//
+// For the fenced code block
+//
+//	```pikchr width=400 caption="Two boxes" dark
+//
+// the Language is "pikchr" and the Options are
+// {"width": "400", "caption": "Two boxes", "dark": ""}.
+//
+// The info string may end in an attribute block in the syntax of
+// goldmark's attribute extension:
+//
+//	```dot {#topology .wide .dark data-zoom=2}
+//
+// which yields the Attributes
+// {"id": "topology", "class": "wide dark", "data-zoom": "2"}.
+type Info struct {
+	// Raw is the full info string, including the language.
+	Raw string
+
+	// Language is the first word of the info string.
+	Language string
+
+	// Options are the key=value pairs following the language.
+	// Values may be double-quoted to include spaces.  Words
+	// without a "=" are stored with an empty value.
+	Options map[string]string
+
+	// Attributes are the attributes from a trailing {...} block,
+	// or nil if there is none.  Multiple classes are joined with
+	// spaces under the "class" key.
+	Attributes map[string]string
+
+	// Path is the path of the Markdown document, if it was set
+	// with WithPath.  Pipes can use it to resolve relative
+	// includes.  It is only part of the cache key for the languages
+	// listed in Extension.PathDependent.
+	Path string
+
+	// Line is the line of the opening code fence in the Markdown
+	// document, starting at 1, for pointing error messages at the
+	// right place.  It is not part of the cache key.
+	Line int
+}
+
+// parseInfo parses a fenced code block info string.
+func parseInfo(raw string) Info {
+	info := Info{
+		Raw:     raw,
+		Options: make(map[string]string),
+	}
+	rest, attrs := cutAttributes(raw)
+	info.Attributes = attrs
+	words := splitInfo(rest)
+	if len(words) == 0 {
+		return info
+	}
+	info.Language = words[0]
+	for _, w := range words[1:] {
+		k, v, _ := strings.Cut(w, "=")
+		if uv, err := strconv.Unquote(v); err == nil {
+			v = uv
+		}
+		info.Options[k] = v
+	}
+	return info
+}
+
+// splitInfo splits s at white space, keeping double-quoted strings
+// together.
+func splitInfo(s string) []string {
+	var (
+		words  []string
+		word   strings.Builder
+		quoted bool
+		escape bool
+	)
+	for _, r := range s {
+		switch {
+		case escape:
+			escape = false
+		case quoted && r == '\\':
+			escape = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteRune(r)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// cutAttributes splits a trailing attribute block off the info
+// string s.  It returns the remaining info string and the parsed
+// attributes, or s and nil if there is no attribute block.
+func cutAttributes(s string) (string, map[string]string) {
+	trimmed := strings.TrimRightFunc(s, unicode.IsSpace)
+	if !strings.HasSuffix(trimmed, "}") {
+		return s, nil
+	}
+	for i := strings.Index(trimmed, " {"); i >= 0; {
+		block := []byte(trimmed[i+1:])
+		r := text.NewReader(block)
+		attrs, ok := parser.ParseAttributes(r)
+		if _, pos := r.Position(); ok && pos.Start == len(block) {
+			return trimmed[:i], attributeMap(attrs)
+		}
+		j := strings.Index(trimmed[i+1:], " {")
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return s, nil
+}
+
+// attributeMap converts goldmark attributes to strings.
+func attributeMap(attrs parser.Attributes) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		var v string
+		switch av := a.Value.(type) {
+		case []byte:
+			v = string(av)
+		case nil:
+		default:
+			v = fmt.Sprint(av)
+		}
+		m[string(bytes.ToLower(a.Name))] = v
+	}
+	return m
+}
@@ -0,0 +1,94 @@
+package pipefence
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// inlineJob returns a job for the code span cs if its content starts
+// with a prefix from e.InlinePipeFuncs followed by a colon, e.g.
+// `math:\frac{1}{2}`.  Otherwise, it returns nil.
+func (e *Extension) inlineJob(cs *ast.CodeSpan, src []byte) *job {
+	if len(e.InlinePipeFuncs) == 0 || cs.FirstChild() == nil {
+		return nil
+	}
+	var content bytes.Buffer
+	for c := cs.FirstChild(); c != nil; c = c.NextSibling() {
+		t, ok := c.(*ast.Text)
+		if !ok {
+			return nil
+		}
+		content.Write(t.Segment.Value(src))
+	}
+
+	prefix, rest, ok := strings.Cut(content.String(), ":")
+	if !ok || !e.permitted(prefix) {
+		return nil
+	}
+	pipeFunc, ok := e.InlinePipeFuncs[prefix]
+	if !ok {
+		return nil
+	}
+
+	first := cs.FirstChild().(*ast.Text).Segment
+	last := cs.LastChild().(*ast.Text).Segment
+	return &job{
+		info: Info{
+			Raw:      prefix,
+			Language: prefix,
+			Options:  map[string]string{},
+		},
+		line:     bytes.Count(src[:first.Start], []byte("\n")) + 1,
+		pos:      text.NewSegment(first.Start, last.Stop),
+		content:  []byte(rest),
+		pipeFunc: e.wrap(pipeFunc),
+		inline:   true,
+	}
+}
+
+// pfInline is a code span whose content needs to be transformed.
+type pfInline struct {
+	ast.BaseInline
+	job
+
+	// original is the code span content including the prefix.
+	original []byte
+
+	ext *Extension // The Extension which created the node.
+}
+
+func (n *pfInline) Kind() ast.NodeKind { return kindInline }
+func (n *pfInline) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, map[string]string{"Language": n.info.Language}, nil)
+}
+
+// renderInline renders a pfInline node.
+func (r *pfRenderer) renderInline(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*pfInline)
+	if n.err != nil {
+		switch n.ext.ErrorMode {
+		case RenderOriginalBlock:
+			w.WriteString("<code>")
+			w.Write(util.EscapeHTML(n.original))
+			w.WriteString("</code>")
+			return ast.WalkSkipChildren, nil
+		case RenderErrorInline:
+			w.WriteString(`<span class="pipefence-error" style="color: red">`)
+			w.Write(util.EscapeHTML([]byte(n.err.Error())))
+			w.WriteString("</span>")
+			return ast.WalkSkipChildren, nil
+		default:
+			return ast.WalkStop, n.err
+		}
+	}
+	w.Write(n.output)
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,64 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestInlinePipeFuncs(t *testing.T) {
+	for _, tt := range []struct {
+		Name  string
+		Mode  pipefence.ErrorMode
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Piped",
+			Input: "Half is `math:\\frac{1}{2}`.\n",
+			Want:  "<p>Half is <math>\\frac{1}{2}</math>.</p>\n",
+		},
+		{
+			Name:  "UnknownPrefix",
+			Input: "See `http://example.com`.\n",
+			Want:  "<p>See <code>http://example.com</code>.</p>\n",
+		},
+		{
+			Name:  "NoPrefix",
+			Input: "Plain `code`.\n",
+			Want:  "<p>Plain <code>code</code>.</p>\n",
+		},
+		{
+			Name:  "ErrorRenderOriginal",
+			Mode:  pipefence.RenderOriginalBlock,
+			Input: "Oops `broken:x<y`.\n",
+			Want:  "<p>Oops <code>broken:x&lt;y</code>.</p>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+				InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{
+					"math": func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+						return []byte("<" + info.Language + ">" + string(a) + "</" + info.Language + ">"), nil
+					},
+					"broken": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+						return nil, errors.New("oops")
+					},
+				},
+				ErrorMode: tt.Mode,
+			}))
+
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package pipefence
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// Isolation is the way in which pipe outputs are embedded into the
+// HTML.
+type Isolation int
+
+const (
+	// NoIsolation inlines outputs into the HTML.
+	NoIsolation Isolation = iota
+
+	// IsolateIframe embeds outputs with <iframe srcdoc>.  Iframes
+	// do not size to their content, so their size must be set with
+	// CSS on the pipefence-isolated class.
+	IsolateIframe
+
+	// IsolateShadowDOM embeds outputs in a declarative shadow DOM,
+	// i.e. a <template shadowrootmode="open"> in a host element.
+	IsolateShadowDOM
+)
+
+// isolations are the values of the isolate option.
+var isolations = map[string]Isolation{
+	"none":   NoIsolation,
+	"false":  NoIsolation,
+	"iframe": IsolateIframe,
+	"shadow": IsolateShadowDOM,
+}
+
+// isolation returns the Isolation of the block with the given Info.
+// The isolate option of the block, one of "none", "iframe" or
+// "shadow", overrides Extension.Isolate.
+func (e *Extension) isolation(info Info) (Isolation, error) {
+	v, ok := info.Options["isolate"]
+	if !ok {
+		return e.Isolate[info.Language], nil
+	}
+	iso, ok := isolations[v]
+	if !ok {
+		return NoIsolation, fmt.Errorf("fenced block transformer %q: unknown isolation %q", info.Language, v)
+	}
+	return iso, nil
+}
+
+// isolate embeds out as given by iso.
+func isolate(out []byte, iso Isolation, inline bool) []byte {
+	var buf bytes.Buffer
+	switch iso {
+	case IsolateIframe:
+		buf.WriteString(`<iframe class="pipefence-isolated" srcdoc="`)
+		buf.Write(util.EscapeHTML(out))
+		buf.WriteString(`"></iframe>`)
+	case IsolateShadowDOM:
+		elem := "div"
+		if inline {
+			elem = "span"
+		}
+		buf.WriteString("<" + elem + ` class="pipefence-isolated"><template shadowrootmode="open">`)
+		buf.Write(out)
+		buf.WriteString("</template></" + elem + ">")
+	default:
+		return out
+	}
+	if !inline {
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
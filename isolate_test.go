@@ -0,0 +1,64 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestIsolate(t *testing.T) {
+	cat := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		return src, nil
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx:    map[string]pipefence.PipeFuncCtx{"svg": cat, "html": cat},
+		InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{"svg": cat},
+		Isolate:         map[string]pipefence.Isolation{"svg": pipefence.IsolateShadowDOM},
+		ErrorMode:       pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "ShadowDOM",
+			Input: "```svg\n<svg id=\"a\"/>\n```\n",
+			Want:  "<div class=\"pipefence-isolated\"><template shadowrootmode=\"open\"><svg id=\"a\"/>\n</template></div>\n",
+		},
+		{
+			Name:  "Inline",
+			Input: "see `svg:<svg/>`\n",
+			Want:  "<p>see <span class=\"pipefence-isolated\"><template shadowrootmode=\"open\"><svg/></template></span></p>\n",
+		},
+		{
+			Name:  "IframeOption",
+			Input: "```html isolate=iframe\n<p class=\"x\">a & b</p>\n```\n",
+			Want:  "<iframe class=\"pipefence-isolated\" srcdoc=\"&lt;p class=&quot;x&quot;&gt;a &amp; b&lt;/p&gt;\n\"></iframe>\n",
+		},
+		{
+			Name:  "NoneOption",
+			Input: "```svg isolate=none\n<svg/>\n```\n",
+			Want:  "<svg/>\n",
+		},
+		{
+			Name:  "UnknownOption",
+			Input: "```html isolate=box\n<p/>\n```\n",
+			Want:  "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">fenced block transformer &quot;html&quot;: unknown isolation &quot;box&quot;</pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package pipefence
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limit restricts how often the pipe function of a language is
+// invoked.  The limits are shared by all conversions using the same
+// Extension.  Blocks exceeding a limit wait for their turn; the time
+// spent waiting does not count towards the Timeout.
+type Limit struct {
+	// Concurrency is the maximum number of concurrent invocations.
+	// Zero means no limit.
+	Concurrency int
+
+	// Interval is the minimum time between the starts of two
+	// invocations.  Zero means no limit.
+	Interval time.Duration
+}
+
+// limiter enforces a Limit.
+type limiter struct {
+	sem      chan struct{} // nil if concurrency is unlimited
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // Earliest start of the next invocation.
+}
+
+func newLimiter(l Limit) *limiter {
+	lim := &limiter{interval: l.Interval}
+	if l.Concurrency > 0 {
+		lim.sem = make(chan struct{}, l.Concurrency)
+	}
+	return lim
+}
+
+// acquire waits until an invocation may start.  On success, the
+// caller must call the returned release function when the
+// invocation is done.
+func (l *limiter) acquire(ctx context.Context) (release func(), err error) {
+	release = func() {}
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-l.sem }
+	}
+	if l.interval <= 0 {
+		return release, nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// limiter returns the limiter for lang, or nil if it is not
+// limited.
+func (e *Extension) limiter(lang string) *limiter {
+	l, ok := e.Limits[lang]
+	if !ok {
+		return nil
+	}
+	e.limitersMu.Lock()
+	defer e.limitersMu.Unlock()
+
+	if lim, ok := e.limiters[lang]; ok {
+		return lim
+	}
+	if e.limiters == nil {
+		e.limiters = make(map[string]*limiter)
+	}
+	lim := newLimiter(l)
+	e.limiters[lang] = lim
+	return lim
+}
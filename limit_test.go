@@ -0,0 +1,80 @@
+package pipefence_test
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"slow": func(a []byte) ([]byte, error) {
+				mu.Lock()
+				running++
+				if running > peak {
+					peak = running
+				}
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return a, nil
+			},
+		},
+		Workers: 8,
+		Limits: map[string]pipefence.Limit{
+			"slow": {Concurrency: 2},
+		},
+	}))
+
+	input := strings.Repeat("```slow\nfoo\n```\n\n", 8)
+	if err := gmark.Convert([]byte(input), io.Discard); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+}
+
+func TestLimitInterval(t *testing.T) {
+	var starts []time.Time
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"remote": func(a []byte) ([]byte, error) {
+				starts = append(starts, time.Now())
+				return a, nil
+			},
+		},
+		Limits: map[string]pipefence.Limit{
+			"remote": {Interval: 20 * time.Millisecond},
+		},
+		// The waiting time does not count towards the timeout.
+		Timeout: 15 * time.Millisecond,
+	}))
+
+	input := strings.Repeat("```remote\nfoo\n```\n\n", 3)
+	if err := gmark.Convert([]byte(input), io.Discard); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if len(starts) != 3 {
+		t.Fatalf("got %d invocations, want 3", len(starts))
+	}
+	for i := 1; i < len(starts); i++ {
+		if d := starts[i].Sub(starts[i-1]); d < 20*time.Millisecond {
+			t.Errorf("invocation %d started %v after the previous one, want at least 20ms", i, d)
+		}
+	}
+}
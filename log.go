@@ -0,0 +1,17 @@
+package pipefence
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardLogger is used when Extension.Logger is unset.
+var discardLogger = slog.New(discardHandler{})
+
+// discardHandler is a slog.Handler which drops all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
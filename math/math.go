@@ -0,0 +1,147 @@
+// Package math provides pipefence pipes for LaTeX math, rendered
+// with KaTeX.
+//
+// Formulas are either rendered to HTML and MathML on the server, by
+// running the KaTeX CLI, or emitted in containers which the KaTeX
+// JavaScript library renders in the browser.
+//
+// Example:
+//
+//	m := &math.Math{Mode: math.ClientSide}
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"math": m.Pipe,
+//		},
+//		InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{
+//			"math": m.InlinePipe,
+//		},
+//	}
+//	// ... convert, then include ext.RequiredAssets(pc) in the page.
+//
+// In ClientSide mode, the page needs to render the containers once
+// KaTeX is loaded:
+//
+//	document.querySelectorAll(".math").forEach(e =>
+//		katex.render(e.textContent, e, {
+//			displayMode: e.classList.contains("math-display"),
+//		}));
+package math
+
+import (
+	"bytes"
+	"context"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark/util"
+)
+
+// Mode selects where formulas are rendered.
+type Mode int
+
+const (
+	// ServerSide renders formulas using the KaTeX CLI.
+	ServerSide Mode = iota
+
+	// ClientSide emits the formulas in elements of the class "math",
+	// to be rendered by the KaTeX JavaScript library.
+	ClientSide
+)
+
+// The KaTeX files returned by Assets if no URLs are set.  The
+// stylesheet is needed in both modes.
+const (
+	DefaultScriptURL     = "https://cdn.jsdelivr.net/npm/katex@0.16/dist/katex.min.js"
+	DefaultStylesheetURL = "https://cdn.jsdelivr.net/npm/katex@0.16/dist/katex.min.css"
+)
+
+// Math renders LaTeX formulas.
+type Math struct {
+	// Mode selects server-side or client-side rendering.
+	Mode Mode
+
+	// Command is the KaTeX CLI binary used in ServerSide mode.
+	// If empty, "katex" is used.
+	Command string
+
+	// Format is the output format in ServerSide mode: "html",
+	// "mathml" or "htmlAndMathml".  If empty, the KaTeX default
+	// is used.
+	Format string
+
+	// ScriptURL and StylesheetURL are the KaTeX files returned by
+	// Assets.  If empty, DefaultScriptURL and DefaultStylesheetURL
+	// are used.
+	ScriptURL     string
+	StylesheetURL string
+}
+
+// Pipe is a pipefence.PipeFuncCtx rendering a formula in display
+// mode, for fenced code blocks.
+func (m *Math) Pipe(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	return m.render(ctx, src, info, true)
+}
+
+// InlinePipe is a pipefence.PipeFuncCtx rendering a formula in
+// inline mode, for Extension.InlinePipeFuncs.
+func (m *Math) InlinePipe(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	return m.render(ctx, src, info, false)
+}
+
+func (m *Math) render(ctx context.Context, src []byte, info pipefence.Info, display bool) ([]byte, error) {
+	pipefence.RequireAssets(ctx, m.Assets()...)
+	if m.Mode == ClientSide {
+		var buf bytes.Buffer
+		if display {
+			buf.WriteString(`<div class="math math-display">`)
+		} else {
+			buf.WriteString(`<span class="math math-inline">`)
+		}
+		buf.Write(util.EscapeHTML(bytes.TrimSpace(src)))
+		if display {
+			buf.WriteString("</div>\n")
+		} else {
+			buf.WriteString("</span>")
+		}
+		return buf.Bytes(), nil
+	}
+
+	cmd := m.Command
+	if cmd == "" {
+		cmd = "katex"
+	}
+	var args []string
+	if display {
+		args = append(args, "--display-mode")
+	}
+	if m.Format != "" {
+		args = append(args, "--format", m.Format)
+	}
+	out, err := pipefence.ExecPipe(cmd, args...)(ctx, src, info)
+	if err != nil {
+		return nil, err
+	}
+	if !display {
+		out = bytes.TrimRight(out, "\n")
+	}
+	return out, nil
+}
+
+// Assets returns the URLs of the files which need to be included on
+// pages with formulas: the KaTeX stylesheet, and in ClientSide mode
+// the KaTeX script.  Pipe and InlinePipe declare them with
+// pipefence.RequireAssets for each formula, so that
+// Extension.RequiredAssets reports them for the pages which need them.
+func (m *Math) Assets() []string {
+	css := m.StylesheetURL
+	if css == "" {
+		css = DefaultStylesheetURL
+	}
+	if m.Mode != ClientSide {
+		return []string{css}
+	}
+	js := m.ScriptURL
+	if js == "" {
+		js = DefaultScriptURL
+	}
+	return []string{css, js}
+}
@@ -0,0 +1,46 @@
+package math_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/math"
+	"github.com/yuin/goldmark"
+)
+
+func TestClientSide(t *testing.T) {
+	m := &math.Math{Mode: math.ClientSide}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"math": m.Pipe,
+		},
+		InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{
+			"math": m.InlinePipe,
+		},
+	}))
+
+	var buf bytes.Buffer
+	input := "Since `math:a<b`:\n\n```math\n\\frac{a}{b} < 1\n```\n"
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	want := "<p>Since <span class=\"math math-inline\">a&lt;b</span>:</p>\n" +
+		"<div class=\"math math-display\">\\frac{a}{b} &lt; 1</div>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+
+	wantAssets := []string{math.DefaultStylesheetURL, math.DefaultScriptURL}
+	if got := m.Assets(); !reflect.DeepEqual(got, wantAssets) {
+		t.Errorf("m.Assets() = %q, want %q", got, wantAssets)
+	}
+}
+
+func TestServerSideAssets(t *testing.T) {
+	m := &math.Math{StylesheetURL: "/katex.css"}
+	if got, want := m.Assets(), []string{"/katex.css"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("m.Assets() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+// Package mermaid provides a pipefence pipe for mermaid diagrams.
+//
+// Diagrams are either rendered to SVG on the server, by running the
+// mermaid CLI (mmdc), or left to the mermaid JavaScript library in
+// the browser.
+//
+// Example:
+//
+//	m := &mermaid.Mermaid{Mode: mermaid.ClientSide}
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"mermaid": m.Pipe,
+//		},
+//	}
+//	// ... convert, then include ext.RequiredAssets(pc) as <script> tags.
+package mermaid
+
+import (
+	"bytes"
+	"context"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark/util"
+)
+
+// Mode selects where diagrams are rendered.
+type Mode int
+
+const (
+	// ServerSide renders diagrams to SVG using the mermaid CLI.
+	ServerSide Mode = iota
+
+	// ClientSide emits the diagram source in a <pre class="mermaid">
+	// element, to be rendered by the mermaid JavaScript library.
+	ClientSide
+)
+
+// DefaultScriptURL is the mermaid library used in ClientSide mode if
+// no ScriptURL is set.
+const DefaultScriptURL = "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.min.js"
+
+// Mermaid renders mermaid diagrams.
+type Mermaid struct {
+	// Mode selects server-side or client-side rendering.
+	Mode Mode
+
+	// Command is the mermaid CLI binary used in ServerSide mode.
+	// If empty, "mmdc" is used.
+	Command string
+
+	// ScriptURL is the mermaid library URL returned by Assets.
+	// If empty, DefaultScriptURL is used.
+	ScriptURL string
+}
+
+// Pipe is a pipefence.PipeFuncCtx rendering a mermaid diagram.
+func (m *Mermaid) Pipe(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	if m.Mode == ClientSide {
+		pipefence.RequireAssets(ctx, m.Assets()...)
+		var buf bytes.Buffer
+		buf.WriteString(`<pre class="mermaid">`)
+		buf.Write(util.EscapeHTML(src))
+		buf.WriteString("</pre>\n")
+		return buf.Bytes(), nil
+	}
+
+	cmd := m.Command
+	if cmd == "" {
+		cmd = "mmdc"
+	}
+	run := pipefence.ExecPipe(cmd, "--input", "-", "--output", "-", "--outputFormat", "svg", "--quiet")
+	return run(ctx, src, info)
+}
+
+// Assets returns the URLs of the JavaScript files which need to be
+// included on pages with mermaid diagrams.  In ServerSide mode, no
+// scripts are needed.  Pipe declares them with pipefence.RequireAssets
+// for each diagram, so that Extension.RequiredAssets reports them for
+// the pages which need them.
+func (m *Mermaid) Assets() []string {
+	if m.Mode != ClientSide {
+		return nil
+	}
+	if m.ScriptURL != "" {
+		return []string{m.ScriptURL}
+	}
+	return []string{DefaultScriptURL}
+}
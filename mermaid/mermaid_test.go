@@ -0,0 +1,56 @@
+package mermaid_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/mermaid"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestClientSide(t *testing.T) {
+	m := &mermaid.Mermaid{Mode: mermaid.ClientSide}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"mermaid": m.Pipe,
+		},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	var buf bytes.Buffer
+	pc := parser.NewContext()
+	input := "```mermaid\ngraph TD\n  A-->B\n```\n"
+	if err := gmark.Convert([]byte(input), &buf, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	want := "<pre class=\"mermaid\">graph TD\n  A--&gt;B\n</pre>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+
+	if got, want := m.Assets(), []string{mermaid.DefaultScriptURL}; !reflect.DeepEqual(got, want) {
+		t.Errorf("m.Assets() = %q, want %q", got, want)
+	}
+	if got, want := ext.RequiredAssets(pc), []string{mermaid.DefaultScriptURL}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ext.RequiredAssets() = %q, want %q", got, want)
+	}
+
+	// Pages without diagrams need no script.
+	pc = parser.NewContext()
+	if err := gmark.Convert([]byte("text\n"), &buf, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got := ext.RequiredAssets(pc); got != nil {
+		t.Errorf("ext.RequiredAssets() = %q, want nil", got)
+	}
+}
+
+func TestServerSideAssets(t *testing.T) {
+	m := &mermaid.Mermaid{}
+	if got := m.Assets(); got != nil {
+		t.Errorf("m.Assets() = %q, want nil", got)
+	}
+}
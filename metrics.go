@@ -0,0 +1,39 @@
+package pipefence
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics receives measurements of pipe invocations, e.g. to export
+// them to a monitoring system.  See the prommetrics module for
+// a Prometheus adapter.
+//
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	ObservePipe(PipeObservation)
+}
+
+// PipeObservation describes a single pipe invocation.
+type PipeObservation struct {
+	Language   string
+	Duration   time.Duration
+	InputSize  int  // Size of the block content in bytes.
+	OutputSize int  // Size of the pipe output in bytes.
+	CacheHit   bool // Whether the output was taken from the Cache.
+	Err        error
+}
+
+// Tracer traces pipe invocations.  See the oteltrace module for an
+// OpenTelemetry implementation.
+//
+// Implementations must be safe for concurrent use.
+type Tracer interface {
+	// StartPipe is called before a pipe invocation for a block in
+	// lang, including invocations answered from the Cache.  The
+	// pipe function receives the returned context, so that spans
+	// of its own, e.g. of HTTP requests, become children of the
+	// pipe's span.  The returned function is called with the
+	// observation once the invocation has finished.
+	StartPipe(ctx context.Context, lang string) (context.Context, func(PipeObservation))
+}
@@ -0,0 +1,27 @@
+package pipefence
+
+// Middleware wraps a pipe function to add cross-cutting behavior,
+// such as timing, retries or logging.
+type Middleware func(PipeFuncCtx) PipeFuncCtx
+
+// Use adds middleware which wraps every pipe function, including
+// DefaultPipe and InlinePipeFuncs.  The first middleware added is
+// the outermost one.  It is safe to call Use while conversions are
+// running; the middleware applies to subsequent conversions.
+func (e *Extension) Use(mw ...Middleware) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.middleware = append(e.middleware, mw...)
+}
+
+// wrap applies the middleware to f.
+func (e *Extension) wrap(f PipeFuncCtx) PipeFuncCtx {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for i := len(e.middleware) - 1; i >= 0; i-- {
+		f = e.middleware[i](f)
+	}
+	return f
+}
@@ -0,0 +1,63 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestMiddleware(t *testing.T) {
+	tag := func(name string) pipefence.Middleware {
+		return func(next pipefence.PipeFuncCtx) pipefence.PipeFuncCtx {
+			return func(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+				out, err := next(ctx, src, info)
+				return []byte("<" + name + ">" + string(out) + "</" + name + ">"), err
+			}
+		}
+	}
+
+	ext := &pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				return bytes.ReplaceAll(bytes.TrimSpace(a), []byte("o"), []byte("a")), nil
+			},
+		},
+		InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{
+			"x": func(_ context.Context, a []byte, _ pipefence.Info) ([]byte, error) {
+				return a, nil
+			},
+		},
+	}
+	ext.Use(tag("outer"), tag("inner"))
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Block",
+			Input: "```banana\nfoo\n```\n",
+			Want:  "<outer><inner>faa</inner></outer>",
+		},
+		{
+			Name:  "Inline",
+			Input: "`x:y`\n",
+			Want:  "<p><outer><inner>y</inner></outer></p>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
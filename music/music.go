@@ -0,0 +1,175 @@
+// Package music provides pipefence pipes engraving music notation
+// in LilyPond and ABC syntax as SVG or PNG scores.
+//
+// The pipes run lilypond and abcm2ps respectively, which must be
+// installed separately.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"lilypond": music.LilyPond(music.Options{Crop: true}),
+//			"abc":      music.ABC(music.Options{}),
+//		},
+//	}
+//
+// The options can be overridden per block:
+//
+//	```lilypond format=png resolution=150 crop=false
+//	\relative { c' d e f g2 g }
+//	```
+//
+// Only LilyPond supports cropping and PNG output; abcm2ps already
+// renders each tune to an SVG sized to its content.
+package music
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Options configure the engraving of scores.
+type Options struct {
+	// Command is the binary to run.  If empty, "lilypond" or
+	// "abcm2ps" is used.
+	Command string
+
+	// Format is the output format, "svg" or "png".  If empty,
+	// "svg" is used.  It can be overridden with the format option
+	// of a block.
+	Format string
+
+	// Crop crops the score to its content instead of rendering
+	// full pages.  It can be overridden with the crop option of a
+	// block.
+	Crop bool
+
+	// Resolution is the resolution of PNG outputs in dots per
+	// inch.  If zero, the default of the command is used.  It can
+	// be overridden with the resolution option of a block.
+	Resolution int
+}
+
+// LilyPond returns a pipe function which engraves LilyPond scores.
+// Of multi-page scores, only the first page is returned.
+func LilyPond(opts Options) pipefence.PipeFuncCtx {
+	if opts.Command == "" {
+		opts.Command = "lilypond"
+	}
+	return func(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		o, err := blockOptions(opts, info)
+		if err != nil {
+			return nil, err
+		}
+		return run(ctx, o, src, func(dir string) []string {
+			args := []string{"-dbackend=svg"}
+			if o.Format == "png" {
+				args = []string{"--png"}
+				if o.Resolution != 0 {
+					args = append(args, "-dresolution="+strconv.Itoa(o.Resolution))
+				}
+			}
+			if o.Crop {
+				args = append(args, "-dcrop")
+			}
+			return append(args, "-dno-point-and-click", "-o", filepath.Join(dir, "score"), "-")
+		})
+	}
+}
+
+// ABC returns a pipe function which engraves ABC tunes.  Of
+// multiple tunes, only the first one is returned.
+func ABC(opts Options) pipefence.PipeFuncCtx {
+	if opts.Command == "" {
+		opts.Command = "abcm2ps"
+	}
+	return func(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		o, err := blockOptions(opts, info)
+		if err != nil {
+			return nil, err
+		}
+		if o.Format != "svg" {
+			return nil, fmt.Errorf("unsupported format %q for ABC", o.Format)
+		}
+		o.Crop = false
+		return run(ctx, o, src, func(dir string) []string {
+			return []string{"-g", "-O", filepath.Join(dir, "tune.svg"), "-"}
+		})
+	}
+}
+
+// run runs the command in a temporary directory with src as its
+// standard input, and returns the first output file.
+func run(ctx context.Context, o Options, src []byte, args func(dir string) []string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "pipefence-music-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, o.Command, args(dir)...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(src)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("%s: %w: %s", o.Command, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", o.Command, err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*."+o.Format))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if o.Crop == strings.HasSuffix(f, ".cropped."+o.Format) {
+			return os.ReadFile(f)
+		}
+	}
+	return nil, fmt.Errorf("%s: no %s output", o.Command, o.Format)
+}
+
+// blockOptions returns opts with the overrides from the options of
+// the block.
+func blockOptions(opts Options, info pipefence.Info) (Options, error) {
+	if v, ok := info.Options["format"]; ok {
+		opts.Format = v
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = "svg"
+	case "svg", "png":
+	default:
+		return opts, fmt.Errorf("unsupported format %q", opts.Format)
+	}
+	if v, ok := info.Options["crop"]; ok {
+		crop, err := strconv.ParseBool(v)
+		if v == "" {
+			crop, err = true, nil
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid crop option %q: %w", v, err)
+		}
+		opts.Crop = crop
+	}
+	if v, ok := info.Options["resolution"]; ok {
+		res, err := strconv.Atoi(v)
+		if err != nil || res <= 0 {
+			return opts, fmt.Errorf("invalid resolution %q", v)
+		}
+		opts.Resolution = res
+	}
+	return opts, nil
+}
@@ -0,0 +1,115 @@
+package music_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/music"
+	"github.com/yuin/goldmark"
+)
+
+// script writes an executable shell script and returns its path.
+func script(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cmd")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+content), 0o755); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+	return path
+}
+
+// fakeLilyPond writes a full and a cropped output, each listing the
+// arguments other than the output path, and the input.
+const fakeLilyPond = `ext=svg
+args=
+for a; do
+	case $prev in
+	-o) out=$a ;;
+	*) [ "$a" = -o ] || args="$args $a" ;;
+	esac
+	[ "$a" = --png ] && ext=png
+	prev=$a
+done
+in=$(cat)
+echo "<svg>full$args: $in</svg>" > "$out.$ext"
+echo "<svg>cropped$args: $in</svg>" > "$out.cropped.$ext"
+`
+
+// fakeABC writes one output per tune.
+const fakeABC = `out=${3%.svg}
+cat > /dev/null
+echo "<svg>$1 1</svg>" > "${out}001.svg"
+echo "<svg>$1 2</svg>" > "${out}002.svg"
+`
+
+func TestPipes(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"lilypond": music.LilyPond(music.Options{Command: script(t, fakeLilyPond), Crop: true}),
+			"abc":      music.ABC(music.Options{Command: script(t, fakeABC)}),
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	const errPrefix = "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">"
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "LilyPond",
+			Input: "```lilypond\n{ c' }\n```\n",
+			Want:  "<svg>cropped -dbackend=svg -dcrop -dno-point-and-click -: { c' }</svg>\n",
+		},
+		{
+			Name:  "LilyPondOptions",
+			Input: "```lilypond format=png resolution=150 crop=false\n{ c' }\n```\n",
+			Want:  "<svg>full --png -dresolution=150 -dno-point-and-click -: { c' }</svg>\n",
+		},
+		{
+			Name:  "ABC",
+			Input: "```abc\nX:1\n```\n",
+			Want:  "<svg>-g 1</svg>\n",
+		},
+		{
+			Name:  "ABCFormat",
+			Input: "```abc format=png\nX:1\n```\n",
+			Want:  errPrefix + "fenced block transformer &quot;abc&quot;: unsupported format &quot;png&quot; for ABC</pre>\n",
+		},
+		{
+			Name:  "InvalidCrop",
+			Input: "```lilypond crop=maybe\n{ c' }\n```\n",
+			Want: errPrefix + "fenced block transformer &quot;lilypond&quot;: invalid crop option &quot;maybe&quot;: " +
+				"strconv.ParseBool: parsing &quot;maybe&quot;: invalid syntax</pre>\n",
+		},
+		{
+			Name:  "InvalidResolution",
+			Input: "```lilypond resolution=0\n{ c' }\n```\n",
+			Want:  errPrefix + "fenced block transformer &quot;lilypond&quot;: invalid resolution &quot;0&quot;</pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestFailure(t *testing.T) {
+	pipe := music.ABC(music.Options{Command: script(t, "echo 'bad tune' >&2; exit 1")})
+	_, err := pipe(context.Background(), []byte("X:1\n"), pipefence.Info{})
+	if err == nil || !strings.HasSuffix(err.Error(), ": exit status 1: bad tune") {
+		t.Errorf("pipe() = %v, want exit status and stderr", err)
+	}
+}
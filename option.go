@@ -0,0 +1,97 @@
+package pipefence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Option configures an Extension created with New.
+type Option func(e *Extension) error
+
+// New returns an Extension configured with the given options:
+//
+//	ext := pipefence.New(
+//		pipefence.WithPipe("dot", pipefence.ExecPipe("dot", "-Tsvg")),
+//		pipefence.WithCache(&pipefence.MemoryCache{}),
+//		pipefence.WithErrorMode(pipefence.RenderErrorInline),
+//		pipefence.WithTimeout(10*time.Second),
+//	)
+//
+// Like regexp.MustCompile, New panics if any of the options is
+// invalid, so it suits options fixed in the program.  Use NewE for
+// options built from configuration.  The fields of the Extension may
+// still be set afterwards, for settings which have no option.
+func New(opts ...Option) *Extension {
+	e, err := NewE(opts...)
+	if err != nil {
+		panic(fmt.Sprintf("pipefence.New: %v", err))
+	}
+	return e
+}
+
+// NewE is like New, but returns an error if any of the options is
+// invalid.  The error reports all invalid options.
+func NewE(opts ...Option) (*Extension, error) {
+	e := &Extension{}
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WithPipe registers fn as the pipe function for lang, as Register
+// does.
+func WithPipe(lang string, fn PipeFuncCtx) Option {
+	return func(e *Extension) error {
+		if lang == "" {
+			return errors.New("WithPipe: empty language")
+		}
+		if fn == nil {
+			return fmt.Errorf("WithPipe: nil pipe function for %q", lang)
+		}
+		e.Register(lang, fn)
+		return nil
+	}
+}
+
+// WithCache sets the Cache.
+func WithCache(c Cache) Option {
+	return func(e *Extension) error {
+		if c == nil {
+			return errors.New("WithCache: nil cache")
+		}
+		e.Cache = c
+		return nil
+	}
+}
+
+// WithErrorMode sets the ErrorMode.
+func WithErrorMode(m ErrorMode) Option {
+	return func(e *Extension) error {
+		switch m {
+		case FailFast, RenderOriginalBlock, RenderErrorInline:
+		default:
+			return fmt.Errorf("WithErrorMode: unknown error mode %d", m)
+		}
+		e.ErrorMode = m
+		return nil
+	}
+}
+
+// WithTimeout sets the Timeout of pipe functions.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Extension) error {
+		if d <= 0 {
+			return fmt.Errorf("WithTimeout: non-positive timeout %v", d)
+		}
+		e.Timeout = d
+		return nil
+	}
+}
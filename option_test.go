@@ -0,0 +1,91 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestNew(t *testing.T) {
+	cache := &pipefence.MemoryCache{}
+	ext := pipefence.New(
+		pipefence.WithPipe("upper", func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+			return bytes.ToUpper(src), nil
+		}),
+		pipefence.WithPipe("fail", func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+			return nil, errors.New("fail")
+		}),
+		pipefence.WithCache(cache),
+		pipefence.WithErrorMode(pipefence.RenderOriginalBlock),
+		pipefence.WithTimeout(time.Minute),
+	)
+	if ext.Cache != cache || ext.Timeout != time.Minute {
+		t.Errorf("New() = %+v, want Cache and Timeout set", ext)
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	input := "```upper\nabc\n```\n\n```fail\nx\n```\n"
+	want := "ABC\n<pre><code class=\"language-fail\">x\n</code></pre>\n"
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		Name string
+		Opts []pipefence.Option
+		Want string
+	}{
+		{
+			Name: "EmptyLanguage",
+			Opts: []pipefence.Option{pipefence.WithPipe("", pipefence.ExecPipe("cat"))},
+			Want: "WithPipe: empty language",
+		},
+		{
+			Name: "NilPipe",
+			Opts: []pipefence.Option{pipefence.WithPipe("dot", nil)},
+			Want: `WithPipe: nil pipe function for "dot"`,
+		},
+		{
+			Name: "NilCache",
+			Opts: []pipefence.Option{pipefence.WithCache(nil)},
+			Want: "WithCache: nil cache",
+		},
+		{
+			Name: "ErrorMode",
+			Opts: []pipefence.Option{pipefence.WithErrorMode(42)},
+			Want: "WithErrorMode: unknown error mode 42",
+		},
+		{
+			Name: "Timeout",
+			Opts: []pipefence.Option{pipefence.WithTimeout(-time.Second)},
+			Want: "WithTimeout: non-positive timeout -1s",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			if _, err := pipefence.NewE(tt.Opts...); err == nil || err.Error() != tt.Want {
+				t.Errorf("NewE() = %v, want error %q", err, tt.Want)
+			}
+
+			defer func() {
+				got := fmt.Sprint(recover())
+				if !strings.Contains(got, tt.Want) {
+					t.Errorf("New() panicked with %q, want %q", got, tt.Want)
+				}
+			}()
+			pipefence.New(tt.Opts...)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/gnoack/goldmark-pipefence/oteltrace
+
+go 1.21
+
+require (
+	github.com/gnoack/goldmark-pipefence v0.0.0-20261016025848-a08b4f0b800e
+	github.com/yuin/goldmark v1.5.4
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
@@ -0,0 +1,73 @@
+// Package oteltrace provides a pipefence.Tracer creating an
+// OpenTelemetry span for each pipe invocation, so that the rendering
+// latency of web services can be attributed to specific tools.  This
+// package is a separate module, so that users of pipefence who do
+// not need it do not depend on the OpenTelemetry libraries.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		Tracer: oteltrace.New(nil),
+//	}
+//
+// The spans are children of the span in the context of the
+// conversion, which is set with pipefence.WithContext:
+//
+//	pc := parser.NewContext()
+//	pipefence.WithContext(pc, r.Context())
+//	err := md.Convert(src, w, parser.WithContext(pc))
+package oteltrace
+
+import (
+	"context"
+	"errors"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the OpenTelemetry tracer.
+const instrumentationName = "github.com/gnoack/goldmark-pipefence"
+
+// Tracer implements pipefence.Tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New returns a Tracer creating spans with tp.  If tp is nil, the
+// global TracerProvider is used.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// StartPipe implements pipefence.Tracer.  The spans are named
+// "pipefence <language>" and have the attributes
+// pipefence.language, pipefence.input_bytes,
+// pipefence.output_bytes and pipefence.cache_hit, as well as
+// process.exit.code for failed exec pipes.
+func (t *Tracer) StartPipe(ctx context.Context, lang string) (context.Context, func(pipefence.PipeObservation)) {
+	ctx, span := t.tracer.Start(ctx, "pipefence "+lang,
+		trace.WithAttributes(attribute.String("pipefence.language", lang)))
+	return ctx, func(o pipefence.PipeObservation) {
+		span.SetAttributes(
+			attribute.Int("pipefence.input_bytes", o.InputSize),
+			attribute.Int("pipefence.output_bytes", o.OutputSize),
+			attribute.Bool("pipefence.cache_hit", o.CacheHit),
+		)
+		var eerr *pipefence.ExecError
+		if errors.As(o.Err, &eerr) {
+			span.SetAttributes(attribute.Int("process.exit.code", eerr.ExitCode))
+		}
+		if o.Err != nil {
+			span.RecordError(o.Err)
+			span.SetStatus(codes.Error, o.Err.Error())
+		}
+		span.End()
+	}
+}
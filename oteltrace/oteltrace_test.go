@@ -0,0 +1,68 @@
+package oteltrace_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/oteltrace"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ pipefence.Tracer = (*oteltrace.Tracer)(nil)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"shout": pipefence.ExecPipe("tr", "a-z", "A-Z"),
+			"fail":  pipefence.ExecPipe("sh", "-c", "exit 3"),
+		},
+		Tracer:    oteltrace.New(tp),
+		ErrorMode: pipefence.RenderErrorInline,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	pc := parser.NewContext()
+	pipefence.WithContext(pc, ctx)
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte("```shout\nabc\n```\n\n```fail\nx\n```\n"), &buf, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	shout, fail := spans[0], spans[1]
+	if shout.Name() != "pipefence shout" || shout.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span %q with parent %v, want child of the request span", shout.Name(), shout.Parent().SpanID())
+	}
+	attrs := attribute.NewSet(shout.Attributes()...)
+	for key, want := range map[attribute.Key]attribute.Value{
+		"pipefence.language":     attribute.StringValue("shout"),
+		"pipefence.input_bytes":  attribute.IntValue(4),
+		"pipefence.output_bytes": attribute.IntValue(4),
+		"pipefence.cache_hit":    attribute.BoolValue(false),
+	} {
+		if got, _ := attrs.Value(key); got != want {
+			t.Errorf("attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+	if fail.Status().Code != codes.Error {
+		t.Errorf("status of failed span = %v, want error", fail.Status())
+	}
+	failAttrs := attribute.NewSet(fail.Attributes()...)
+	if got, _ := failAttrs.Value("process.exit.code"); got != attribute.IntValue(3) {
+		t.Errorf("process.exit.code = %v, want 3", got.Emit())
+	}
+}
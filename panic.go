@@ -0,0 +1,33 @@
+package pipefence
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned when a pipe function panicked.
+type PanicError struct {
+	Language string // Language of the fenced code block.
+	Line     int    // Line of the opening code fence, starting at 1.
+	Value    any    // The value passed to panic.
+	Stack    []byte // The stack trace of the panicking goroutine.
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("fenced block transformer %q at line %d: panic: %v", e.Language, e.Line, e.Value)
+}
+
+// recovering returns a pipe function which invokes f, but returns a
+// *PanicError instead of crashing if f panics.
+func recovering(j *job, f PipeFuncCtx) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, info Info) (out []byte, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				out = nil
+				err = &PanicError{Language: j.info.Language, Line: j.line, Value: v, Stack: debug.Stack()}
+			}
+		}()
+		return f(ctx, src, info)
+	}
+}
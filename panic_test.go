@@ -0,0 +1,44 @@
+package pipefence_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestPanic(t *testing.T) {
+	for _, tt := range []struct {
+		Name    string
+		Timeout time.Duration
+	}{
+		{Name: "NoTimeout"},
+		// With a timeout, the pipe function runs in its own goroutine.
+		{Name: "Timeout", Timeout: time.Minute},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+				PipeFuncs: map[string]pipefence.PipeFunc{
+					"buggy": func([]byte) ([]byte, error) {
+						var m map[string]int
+						m["boom"]++
+						return nil, nil
+					},
+				},
+				Timeout: tt.Timeout,
+			}))
+
+			err := gmark.Convert([]byte("text\n\n```buggy\nfoo\n```\n"), io.Discard)
+			var perr *pipefence.PanicError
+			if !errors.As(err, &perr) {
+				t.Fatalf("gmark.Convert: err = %v, want *PanicError", err)
+			}
+			if perr.Language != "buggy" || perr.Line != 3 || len(perr.Stack) == 0 {
+				t.Errorf("gmark.Convert: err = %+v, want language buggy, line 3 and a stack trace", perr)
+			}
+		})
+	}
+}
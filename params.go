@@ -0,0 +1,34 @@
+package pipefence
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"text/template"
+)
+
+// templateData is the data of the templates expanded in blocks in
+// ParamLanguages.
+type templateData struct {
+	Params map[string]any
+}
+
+// expandParams expands the template placeholders in content, such as
+// {{ .Params.version }}, with the document metadata params.
+func expandParams(info Info, content []byte, params map[string]any) ([]byte, error) {
+	tmpl, err := template.New(info.Language).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("fenced block transformer %q: %w", info.Language, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateData{Params: params}); err != nil {
+		return nil, fmt.Errorf("fenced block transformer %q: %w", info.Language, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// expandsParams reports whether blocks in lang have their parameters
+// expanded.
+func (e *Extension) expandsParams(lang string) bool {
+	return e.Params != nil && slices.Contains(e.ParamLanguages, lang)
+}
@@ -0,0 +1,61 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestParams(t *testing.T) {
+	metaKey := parser.NewContextKey()
+	echo := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		return src, nil
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{"dot": echo, "mermaid": echo},
+		Params: func(pc parser.Context) map[string]any {
+			m, _ := pc.Get(metaKey).(map[string]any)
+			return m
+		},
+		ParamLanguages: []string{"dot"},
+		ErrorMode:      pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Expanded",
+			Input: "```dot\nlabel=\"v{{ .Params.version }}\"\n```\n",
+			Want:  "label=\"v1.2\"\n",
+		},
+		{
+			Name:  "OtherLanguage",
+			Input: "```mermaid\nA{{hexagon}}\n```\n",
+			Want:  "A{{hexagon}}\n",
+		},
+		{
+			Name:  "MissingKey",
+			Input: "```dot\n{{ .Params.missing }}\n```\n",
+			Want:  "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">fenced block transformer &quot;dot&quot;: template: dot:1:10: executing &quot;dot&quot; at &lt;.Params.missing&gt;: map has no entry for key &quot;missing&quot;</pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			pc := parser.NewContext()
+			pc.Set(metaKey, map[string]any{"version": "1.2"})
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf, parser.WithContext(pc)); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
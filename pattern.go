@@ -0,0 +1,79 @@
+package pipefence
+
+import (
+	"path"
+	"regexp"
+)
+
+// patternPipe is a pipe function registered for a family of
+// languages.
+type patternPipe struct {
+	name     string // The pattern, for annotations.
+	match    func(lang string, info []byte) bool
+	pipeFunc PipeFuncCtx
+	piper    Piper // The Piper, if registered with RegisterPiper.
+}
+
+// RegisterGlob registers fn as the pipe function for all languages
+// matching the glob pattern, in the syntax of path.Match, e.g.
+// "plot-*".  The pipe function can inspect the matched language in
+// Info.Language.
+//
+// Languages registered exactly take precedence over patterns.
+// Patterns registered with RegisterGlob and RegisterRegexp, and
+// Pipers, are tried in the order in which they were registered, and
+// the first match wins.  It is safe to call RegisterGlob while
+// conversions are running.
+func (e *Extension) RegisterGlob(pattern string, fn PipeFuncCtx) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	e.registerPattern(patternPipe{
+		name: pattern,
+		match: func(lang string, _ []byte) bool {
+			ok, _ := path.Match(pattern, lang)
+			return ok
+		},
+		pipeFunc: fn,
+	})
+	return nil
+}
+
+// RegisterRegexp registers fn as the pipe function for all languages
+// matching re, e.g. regexp.MustCompile(`^diagram:`).  The precedence
+// rules are the same as for RegisterGlob.
+func (e *Extension) RegisterRegexp(re *regexp.Regexp, fn PipeFuncCtx) {
+	e.registerPattern(patternPipe{
+		name:     re.String(),
+		match:    func(lang string, _ []byte) bool { return re.MatchString(lang) },
+		pipeFunc: fn,
+	})
+}
+
+func (e *Extension) registerPattern(p patternPipe) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.patterns = append(e.patterns, p)
+}
+
+// lookupPattern returns the pipe function of the first pattern
+// matching lang, in a block with the raw info string info.
+func (e *Extension) lookupPattern(lang string, info []byte) (PipeFuncCtx, bool) {
+	p, ok := e.matchPattern(lang, info)
+	return p.pipeFunc, ok
+}
+
+// matchPattern returns the first pattern matching lang, in a block
+// with the raw info string info.
+func (e *Extension) matchPattern(lang string, info []byte) (patternPipe, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, p := range e.patterns {
+		if p.match(lang, info) {
+			return p, true
+		}
+	}
+	return patternPipe{}, false
+}
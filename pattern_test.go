@@ -0,0 +1,54 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestPatterns(t *testing.T) {
+	named := func(name string) pipefence.PipeFuncCtx {
+		return func(_ context.Context, _ []byte, info pipefence.Info) ([]byte, error) {
+			return []byte(name + "(" + info.Language + ")"), nil
+		}
+	}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"plot-special": named("exact"),
+		},
+	}
+	if err := ext.RegisterGlob("plot-*", named("glob")); err != nil {
+		t.Fatalf("RegisterGlob: %v", err)
+	}
+	ext.RegisterRegexp(regexp.MustCompile(`^diagram:`), named("regexp"))
+	// Shadowed by the earlier glob.
+	ext.RegisterRegexp(regexp.MustCompile(`^plot-`), named("late"))
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		Input string
+		Want  string
+	}{
+		{Input: "```plot-line\n```\n", Want: "glob(plot-line)"},
+		{Input: "```plot-special\n```\n", Want: "exact(plot-special)"},
+		{Input: "```diagram:seq\n```\n", Want: "regexp(diagram:seq)"},
+		{Input: "```plot-bar|diagram:x\n```\n", Want: "regexp(diagram:x)"},
+		{Input: "```go\n```\n", Want: "<pre><code class=\"language-go\"></code></pre>\n"},
+	} {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if got := buf.String(); got != tt.Want {
+			t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+		}
+	}
+
+	if err := ext.RegisterGlob("[", named("bad")); err == nil {
+		t.Errorf("RegisterGlob(%q) succeeded, want error", "[")
+	}
+}
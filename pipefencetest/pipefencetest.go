@@ -0,0 +1,89 @@
+// Package pipefencetest provides golden-file tests for pipefence
+// pipes.
+//
+// A test converts each Markdown fixture in a directory and compares
+// the resulting HTML with a golden file next to it:
+//
+//	func TestPipes(t *testing.T) {
+//		ext := &pipefence.Extension{PipeFuncsCtx: myPipes}
+//		pipefencetest.Golden(t, "testdata", ext)
+//	}
+//
+// For testdata/boxes.md, the expected output is testdata/boxes.html.
+// Running the test with the -pipefence.update flag rewrites the
+// golden files from the actual outputs:
+//
+//	go test -run TestPipes -pipefence.update
+package pipefencetest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+)
+
+var update = flag.Bool("pipefence.update", false, "update the pipefence golden files")
+
+// Golden converts each .md file in dir with goldmark and the given
+// extensions, and compares the output with the .html file of the
+// same name, in a subtest named after the fixture.  If the
+// -pipefence.update flag is set, the .html files are written instead.
+func Golden(t *testing.T, dir string, exts ...goldmark.Extender) {
+	t.Helper()
+
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		t.Fatalf("filepath.Glob: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("no .md fixtures in %s", dir)
+	}
+
+	gmark := goldmark.New(goldmark.WithExtensions(exts...))
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".md")
+		t.Run(name, func(t *testing.T) {
+			CompareGolden(t, gmark, fixture, strings.TrimSuffix(fixture, ".md")+".html")
+		})
+	}
+}
+
+// CompareGolden converts the Markdown file input with gmark and
+// compares the output with the file golden.  If the
+// -pipefence.update flag is set, golden is written instead.
+func CompareGolden(t *testing.T, gmark goldmark.Markdown, input, golden string) {
+	t.Helper()
+
+	src, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := gmark.Convert(src, &buf); err != nil {
+		t.Fatalf("gmark.Convert(%s): %v", input, err)
+	}
+	got := buf.Bytes()
+
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatalf("os.WriteFile: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %s does not exist; run the test with -pipefence.update to create it", golden)
+	}
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: output differs from %s\ngot:\n%s\nwant:\n%s", input, golden, got, want)
+	}
+}
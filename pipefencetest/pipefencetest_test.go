@@ -0,0 +1,54 @@
+package pipefencetest_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/pipefencetest"
+)
+
+var ext = &pipefence.Extension{
+	PipeFuncs: map[string]pipefence.PipeFunc{
+		"upper": func(a []byte) ([]byte, error) {
+			return bytes.ToUpper(a), nil
+		},
+	},
+}
+
+func TestGolden(t *testing.T) {
+	pipefencetest.Golden(t, "testdata", ext)
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	if err := flag.Set("pipefence.update", "true"); err != nil {
+		t.Fatalf("flag.Set: %v", err)
+	}
+	defer flag.Set("pipefence.update", "false")
+
+	dir := t.TempDir()
+	src, err := os.ReadFile(filepath.Join("testdata", "upper.md"))
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "upper.md"), src, 0o644); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	pipefencetest.Golden(t, dir, ext)
+
+	got, err := os.ReadFile(filepath.Join(dir, "upper.html"))
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "upper.html"))
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("updated golden file = %q, want %q", got, want)
+	}
+}
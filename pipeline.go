@@ -0,0 +1,70 @@
+package pipefence
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Compose returns a pipe function which feeds the content through
+// all given pipe functions in order, each one receiving the output
+// of the previous one.
+func Compose(stages ...PipeFuncCtx) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+		for _, f := range stages {
+			var err error
+			src, err = f(ctx, src, info)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return src, nil
+	}
+}
+
+// resolve returns the pipe function for lang, which may be a
+// pipeline of registered languages separated by "|", such as
+// "dot|svgo".
+//
+// If lang is a pipeline where some but not all stages are
+// registered, resolve returns an error naming the unknown stage.
+// The raw info string info of the block is passed to Pipers.
+func (e *Extension) resolve(lang string, info []byte) (PipeFuncCtx, bool, error) {
+	if f, ok := e.lookupExact(lang); ok || !strings.Contains(lang, "|") {
+		if !ok {
+			f, ok = e.lookup(lang, info)
+		}
+		return f, ok, nil
+	}
+
+	names := strings.Split(lang, "|")
+	stages := make([]PipeFuncCtx, len(names))
+	var unknown []string
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		f, ok := e.lookupRegistered(name, info)
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		stages[i] = withLanguage(f, name)
+	}
+	switch {
+	case len(unknown) == len(names):
+		// Not a pipeline of ours at all.
+		f, ok := e.lookup(lang, info)
+		return f, ok, nil
+	case len(unknown) > 0:
+		return nil, true, fmt.Errorf("fenced block transformer %q: unknown pipeline stage %q", lang, unknown[0])
+	}
+	return Compose(stages...), true, nil
+}
+
+// withLanguage returns a pipe function which invokes f with the
+// Language of the Info set to lang.
+func withLanguage(f PipeFuncCtx, lang string) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+		info.Language = lang
+		return f(ctx, src, info)
+	}
+}
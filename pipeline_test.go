@@ -0,0 +1,83 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestCompose(t *testing.T) {
+	upper := func(_ context.Context, a []byte, _ pipefence.Info) ([]byte, error) {
+		return bytes.ToUpper(a), nil
+	}
+	exclaim := func(_ context.Context, a []byte, _ pipefence.Info) ([]byte, error) {
+		return append(a, '!'), nil
+	}
+
+	got, err := pipefence.Compose(upper, exclaim)(context.Background(), []byte("foo"), pipefence.Info{})
+	if err != nil {
+		t.Fatalf("Compose(upper, exclaim): %v", err)
+	}
+	if string(got) != "FOO!" {
+		t.Errorf("Compose(upper, exclaim)(foo) = %q, want %q", got, "FOO!")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"banana": func(a []byte) ([]byte, error) {
+				return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+			},
+		},
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"tag": func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+				return []byte("<" + info.Language + ">" + string(a)), nil
+			},
+		},
+	}))
+
+	for _, tt := range []struct {
+		Name    string
+		Input   string
+		Want    string
+		WantErr string
+	}{
+		{
+			Name:  "TwoStages",
+			Input: "```banana|tag\nfoo\n```\n",
+			Want:  "<tag>faa\n",
+		},
+		{
+			Name:  "UnrelatedBars",
+			Input: "```a|b\nfoo\n```\n",
+			Want:  "<pre><code class=\"language-a|b\">foo\n</code></pre>\n",
+		},
+		{
+			Name:    "UnknownStage",
+			Input:   "```banana|svgo\nfoo\n```\n",
+			WantErr: `unknown pipeline stage "svgo"`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := gmark.Convert([]byte(tt.Input), &buf)
+			if tt.WantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.WantErr) {
+					t.Errorf("gmark.Convert(%q): err = %v, want error containing %q", tt.Input, err, tt.WantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
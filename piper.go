@@ -0,0 +1,134 @@
+package pipefence
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Piper is a pipe which decides itself which blocks it transforms.
+// Unlike pipe functions, Pipers suit sophisticated pipes which carry
+// state, such as a connection to a rendering service, and which
+// report the assets, dependencies and warnings of their outputs in
+// their Result.  Pipers may additionally implement VersionedPiper
+// and ValidatingPiper.
+type Piper interface {
+	// Match reports whether the Piper transforms blocks in the
+	// language lang, with the raw info string info.  Pipeline
+	// stages are matched with the info string of the whole block.
+	Match(lang string, info []byte) bool
+
+	// Pipe transforms a block.
+	Pipe(ctx context.Context, req Request) (Result, error)
+}
+
+// VersionedPiper is a Piper which reports the version of its
+// renderer.  The version is part of the cache keys of its outputs,
+// as with CacheVersions, so that upgrading the renderer invalidates
+// them.
+type VersionedPiper interface {
+	Piper
+	Version() string
+}
+
+// ValidatingPiper is a Piper which validates blocks before they are
+// transformed, e.g. to reject unknown options.  Validation also runs
+// in Validate, which does not run the pipes.  It does not run for
+// pipeline stages.
+type ValidatingPiper interface {
+	Piper
+	Validate(req Request) error
+}
+
+// Request is a block to be transformed by a Piper.
+type Request struct {
+	Source []byte // Content of the block.
+	Info   Info
+}
+
+// Result is the transformed block returned by a Piper.
+type Result struct {
+	Output []byte
+
+	// Assets are the URLs of the assets the output requires, as
+	// passed to RequireAssets.
+	Assets []string
+
+	// Dependencies are the files the output depends on, as passed
+	// to DependOn.
+	Dependencies []string
+
+	// Warnings are reported like those passed to Warnf.
+	Warnings []string
+}
+
+// RegisterPiper registers p for the blocks it matches.  Languages
+// registered exactly take precedence over Pipers, and Pipers are
+// tried in the order in which they were registered, together with
+// patterns registered with RegisterGlob and RegisterRegexp.  It is
+// safe to call RegisterPiper while conversions are running.
+func (e *Extension) RegisterPiper(p Piper) {
+	e.registerPattern(patternPipe{
+		name:     fmt.Sprintf("%T", p),
+		match:    p.Match,
+		pipeFunc: piperFunc(p),
+		piper:    p,
+	})
+}
+
+// piperFunc returns a pipe function invoking p.
+func piperFunc(p Piper) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+		res, err := p.Pipe(ctx, Request{Source: src, Info: info})
+		if err != nil {
+			return nil, err
+		}
+		RequireAssets(ctx, res.Assets...)
+		DependOn(ctx, res.Dependencies...)
+		for _, w := range res.Warnings {
+			Warnf(ctx, "%s", w)
+		}
+		return res.Output, nil
+	}
+}
+
+// matchPiper returns the Piper resolved for a single language, unless
+// the language is registered exactly.
+func (e *Extension) matchPiper(lang string, info []byte) (Piper, bool) {
+	if _, ok := e.lookupExact(lang); ok {
+		return nil, false
+	}
+	p, ok := e.matchPattern(lang, info)
+	return p.piper, ok && p.piper != nil
+}
+
+// validatePiper validates a block with the given Info and content
+// if its Piper is a ValidatingPiper.
+func (e *Extension) validatePiper(info Info, content []byte) error {
+	p, ok := e.matchPiper(info.Language, []byte(info.Raw))
+	if !ok {
+		return nil
+	}
+	v, ok := p.(ValidatingPiper)
+	if !ok {
+		return nil
+	}
+	if err := v.Validate(Request{Source: content, Info: info}); err != nil {
+		return fmt.Errorf("fenced block transformer %q: %w", info.Language, err)
+	}
+	return nil
+}
+
+// piperVersion returns the version of the VersionedPiper resolved for
+// the stage of a block with the given Info.
+func (e *Extension) piperVersion(stage string, info Info) (string, bool) {
+	p, ok := e.matchPiper(strings.TrimSpace(stage), []byte(info.Raw))
+	if !ok {
+		return "", false
+	}
+	v, ok := p.(VersionedPiper)
+	if !ok {
+		return "", false
+	}
+	return v.Version(), true
+}
@@ -0,0 +1,102 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+// chartPiper renders blocks in chart-* languages with a "render"
+// word in their info string.
+type chartPiper struct {
+	version string
+	calls   int
+}
+
+func (p *chartPiper) Match(lang string, info []byte) bool {
+	return strings.HasPrefix(lang, "chart-") && bytes.Contains(info, []byte("render"))
+}
+
+func (p *chartPiper) Pipe(_ context.Context, req pipefence.Request) (pipefence.Result, error) {
+	p.calls++
+	return pipefence.Result{
+		Output:   []byte("<svg>" + req.Info.Language + ":" + strings.TrimSpace(string(req.Source)) + "</svg>\n"),
+		Assets:   []string{"/chart.css"},
+		Warnings: []string{"rendered with " + p.version},
+	}, nil
+}
+
+func (p *chartPiper) Version() string { return p.version }
+
+func (p *chartPiper) Validate(req pipefence.Request) error {
+	if _, ok := req.Info.Options["bad"]; ok {
+		return errors.New("unknown option bad")
+	}
+	return nil
+}
+
+func TestPiper(t *testing.T) {
+	piper := &chartPiper{version: "v1"}
+	var warnings []string
+	ext := &pipefence.Extension{
+		Cache:     &pipefence.MemoryCache{},
+		ErrorMode: pipefence.RenderErrorInline,
+		OnWarning: func(w pipefence.Warning) { warnings = append(warnings, w.String()) },
+	}
+	ext.RegisterPiper(piper)
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	input := "```chart-pie render\n1 2\n```\n\n```chart-bar\n3\n```\n\n```chart-bar render bad\n4\n```\n"
+	want := "<svg>chart-pie:1 2</svg>\n" +
+		"<pre><code class=\"language-chart-bar\">3\n</code></pre>\n" +
+		"<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">fenced block transformer &quot;chart-bar&quot;: unknown option bad</pre>\n"
+	convert := func() parser.Context {
+		t.Helper()
+		pc := parser.NewContext()
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(input), &buf, parser.WithContext(pc)); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+		}
+		return pc
+	}
+
+	pc := convert()
+	if got, want := ext.RequiredAssets(pc), []string{"/chart.css"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RequiredAssets() = %v, want %v", got, want)
+	}
+	if want := []string{"line 1: chart-pie: rendered with v1"}; !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %v, want %v", warnings, want)
+	}
+
+	// The Result of the cached output is reported again.
+	pc = convert()
+	if piper.calls != 1 {
+		t.Errorf("Pipe called %d times with the same version, want 1", piper.calls)
+	}
+	if got, want := ext.RequiredAssets(pc), []string{"/chart.css"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RequiredAssets() from the Cache = %v, want %v", got, want)
+	}
+	if want := []string{"line 1: chart-pie: rendered with v1", "line 1: chart-pie: rendered with v1"}; !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %v, want %v", warnings, want)
+	}
+	piper.version = "v2"
+	convert()
+	if piper.calls != 2 {
+		t.Errorf("Pipe called %d times after a version change, want 2", piper.calls)
+	}
+
+	issues := ext.Validate([]byte(input))
+	if len(issues) != 1 || issues[0].Line != 9 {
+		t.Errorf("Validate() = %v, want one issue in line 9", issues)
+	}
+}
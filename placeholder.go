@@ -0,0 +1,190 @@
+package pipefence
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	// placeholdersKey is the parser.Context key marking a conversion
+	// which renders placeholders instead of running the pipes.
+	placeholdersKey = parser.NewContextKey()
+
+	// pendingKey is the parser.Context key for the Pending blocks of
+	// a conversion.
+	pendingKey = parser.NewContextKey()
+)
+
+// placeholderPrefix starts the HTML comments which stand in for the
+// outputs of pending blocks.
+const placeholderPrefix = "<!--pipefence:"
+
+// Pending is a block or code span whose pipe has not run yet, as
+// recorded in conversions with placeholders.
+type Pending struct {
+	ID     string // Identifier of the placeholder.
+	Info   Info   // Parsed info string of the block.
+	Source []byte // Content of the block.
+	Line   int    // Line of the block, starting at 1.
+
+	job *job
+
+	// original is the code span including the prefix, or nil for
+	// blocks.
+	original []byte
+}
+
+// Placeholder returns the HTML comment standing in for the output of
+// the block in the converted HTML.
+func (p Pending) Placeholder() string {
+	return placeholder(p.ID)
+}
+
+func placeholder(id string) string {
+	return placeholderPrefix + id + "-->"
+}
+
+// WithPlaceholders makes a single conversion render unique
+// placeholders instead of running the pipe functions, and record the
+// blocks for PendingBlocks.  Once goldmark has finished, the caller
+// transforms the blocks, e.g. in a batch or on remote renderers, and
+// substitutes the outputs with ResolvePlaceholders:
+//
+//	pc := parser.NewContext()
+//	pipefence.WithPlaceholders(pc)
+//	err := md.Convert(src, &buf, parser.WithContext(pc))
+//	...
+//	results, err := ext.RunPending(ctx, pipefence.PendingBlocks(pc))
+//	...
+//	html := pipefence.ResolvePlaceholders(buf.Bytes(), results)
+//
+// Blocks whose pipe can not be resolved at all still render
+// according to the ErrorMode.  Assets declared with RequireAssets are
+// not reported by RequiredAssets for pending blocks.
+func WithPlaceholders(pc parser.Context) {
+	pc.Set(placeholdersKey, true)
+}
+
+// PendingBlocks returns the blocks and code spans of a conversion
+// with placeholders, in document order.
+func PendingBlocks(pc parser.Context) []Pending {
+	pending, _ := pc.Get(pendingKey).([]Pending)
+	return pending
+}
+
+// addPending replaces the outputs of the jobs with placeholders and
+// records them as pending in pc.  Originals holds the original
+// code spans of inline jobs.
+func addPending(pc parser.Context, jobs []*job, originals map[*job][]byte) {
+	var nonce [6]byte
+	rand.Read(nonce[:])
+	prefix := hex.EncodeToString(nonce[:]) + "-"
+
+	pending, _ := pc.Get(pendingKey).([]Pending)
+	for _, j := range jobs {
+		if j.err != nil {
+			// Failed to resolve at transform time.
+			continue
+		}
+		id := prefix + strconv.Itoa(len(pending)+1)
+		j.output = []byte(placeholder(id))
+		pending = append(pending, Pending{
+			ID:       id,
+			Info:     j.info,
+			Source:   j.content,
+			Line:     j.line,
+			job:      j,
+			original: originals[j],
+		})
+	}
+	pc.Set(pendingKey, pending)
+}
+
+// RunPending runs the pipe functions of the pending blocks of e, as
+// a conversion would, and returns their outputs keyed by ID.  Failed
+// blocks are handled according to the ErrorMode: with FailFast,
+// RunPending returns the error of the first failed block.
+func (e *Extension) RunPending(ctx context.Context, pending []Pending) (map[string][]byte, error) {
+	jobs := make([]*job, len(pending))
+	for i, p := range pending {
+		jobs[i] = p.job
+	}
+	e.runAll(ctx, jobs)
+	e.reportWarnings(nil, nil, jobs)
+
+	results := make(map[string][]byte, len(pending))
+	for _, p := range pending {
+		j := p.job
+		annotate := e.Annotate && p.original == nil && j.cache != ""
+		if j.err == nil && !annotate {
+			results[p.ID] = j.output
+			continue
+		}
+		if j.err != nil && e.ErrorMode == FailFast {
+			return nil, j.err
+		}
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		if annotate {
+			writeAnnotation(w, j)
+		}
+		switch {
+		case j.err == nil:
+			w.Write(j.output)
+		case e.ErrorMode == RenderOriginalBlock && p.original != nil:
+			w.WriteString("<code>")
+			w.Write(util.EscapeHTML(p.original))
+			w.WriteString("</code>")
+		case e.ErrorMode == RenderOriginalBlock:
+			writeOriginalBlock(w, j.info.Language, j.content)
+		case p.original != nil:
+			w.WriteString(`<span class="pipefence-error" style="color: red">`)
+			w.Write(util.EscapeHTML([]byte(j.err.Error())))
+			w.WriteString("</span>")
+		default:
+			writeErrorBlock(w, j.err)
+		}
+		if annotate {
+			w.WriteString(annotationEnd)
+		}
+		w.Flush()
+		results[p.ID] = buf.Bytes()
+	}
+	return results, nil
+}
+
+// ResolvePlaceholders returns html with the placeholders of pending
+// blocks replaced by their outputs, keyed by the IDs of the blocks.
+// Placeholders without a result are left as they are.
+func ResolvePlaceholders(html []byte, results map[string][]byte) []byte {
+	var buf bytes.Buffer
+	for {
+		i := bytes.Index(html, []byte(placeholderPrefix))
+		if i < 0 {
+			break
+		}
+		rest := html[i+len(placeholderPrefix):]
+		end := bytes.Index(rest, []byte("-->"))
+		if end < 0 {
+			break
+		}
+		out, ok := results[string(rest[:end])]
+		if !ok {
+			buf.Write(html[:i+len(placeholderPrefix)])
+			html = rest
+			continue
+		}
+		buf.Write(html[:i])
+		buf.Write(out)
+		html = rest[end+len("-->"):]
+	}
+	buf.Write(html)
+	return buf.Bytes()
+}
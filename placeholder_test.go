@@ -0,0 +1,134 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestPlaceholders(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		errorMode pipefence.ErrorMode
+		input     string
+		want      string
+	}{
+		{
+			name:  "blocks",
+			input: "# Title\n\n```upper\nabc\n```\n\ntext `up:x` text\n",
+			want:  "<h1>Title</h1>\nABC\n<p>text X text</p>\n",
+		},
+		{
+			name:      "render original",
+			errorMode: pipefence.RenderOriginalBlock,
+			input:     "```fail\n<x>\n```\n\n`fail:y`\n",
+			want:      "<pre><code class=\"language-fail\">&lt;x&gt;\n</code></pre>\n<p><code>fail:y</code></p>\n",
+		},
+		{
+			name:      "render error",
+			errorMode: pipefence.RenderErrorInline,
+			input:     "```fail\nx\n```\n",
+			want:      "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">fenced block transformer &quot;fail&quot;: boom</pre>\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			called := 0
+			upper := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				called++
+				return bytes.ToUpper(src), nil
+			}
+			fail := func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				return nil, errors.New("boom")
+			}
+			ext := &pipefence.Extension{
+				PipeFuncsCtx:    map[string]pipefence.PipeFuncCtx{"upper": upper, "fail": fail},
+				InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{"up": upper, "fail": fail},
+				ErrorMode:       tt.errorMode,
+			}
+			gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+			pc := parser.NewContext()
+			pipefence.WithPlaceholders(pc)
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.input), &buf, parser.WithContext(pc)); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if called != 0 {
+				t.Errorf("pipe function called %d times during conversion", called)
+			}
+			pending := pipefence.PendingBlocks(pc)
+			for _, p := range pending {
+				if !strings.Contains(buf.String(), p.Placeholder()) {
+					t.Errorf("HTML %q does not contain placeholder %q", buf.String(), p.Placeholder())
+				}
+			}
+
+			results, err := ext.RunPending(context.Background(), pending)
+			if err != nil {
+				t.Fatalf("RunPending: %v", err)
+			}
+			if got := string(pipefence.ResolvePlaceholders(buf.Bytes(), results)); got != tt.want {
+				t.Errorf("ResolvePlaceholders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPendingFailFast(t *testing.T) {
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"fail": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				return nil, errors.New("boom")
+			},
+		},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	pc := parser.NewContext()
+	pipefence.WithPlaceholders(pc)
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte("```fail\nx\n```\n"), &buf, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	pending := pipefence.PendingBlocks(pc)
+	if len(pending) != 1 || pending[0].Info.Language != "fail" || pending[0].Line != 1 || string(pending[0].Source) != "x\n" {
+		t.Fatalf("PendingBlocks() = %+v, want one block", pending)
+	}
+	if _, err := ext.RunPending(context.Background(), pending); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("RunPending() = %v, want error containing %q", err, "boom")
+	}
+}
+
+func TestResolvePlaceholders(t *testing.T) {
+	for _, tt := range []struct {
+		html    string
+		results map[string][]byte
+		want    string
+	}{
+		{
+			html:    "a<!--pipefence:1-->b<!--pipefence:2-->c",
+			results: map[string][]byte{"1": []byte("X"), "2": []byte("Y")},
+			want:    "aXbYc",
+		},
+		{
+			html:    "a<!--pipefence:1--><!--pipefence:unknown-->",
+			results: map[string][]byte{"1": []byte("X")},
+			want:    "aX<!--pipefence:unknown-->",
+		},
+		{
+			html:    "<!--pipefence:1",
+			results: map[string][]byte{"1": []byte("X")},
+			want:    "<!--pipefence:1",
+		},
+	} {
+		if got := string(pipefence.ResolvePlaceholders([]byte(tt.html), tt.results)); got != tt.want {
+			t.Errorf("ResolvePlaceholders(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
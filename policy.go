@@ -0,0 +1,25 @@
+package pipefence
+
+import (
+	"slices"
+	"strings"
+)
+
+// permitted reports whether fenced code blocks in lang may be piped,
+// according to Untrusted, Allow and Deny.  For pipelines such as
+// "dot|svgo", every stage must be permitted.
+func (e *Extension) permitted(lang string) bool {
+	if e.Untrusted {
+		return false
+	}
+	for _, stage := range strings.Split(lang, "|") {
+		stage = strings.TrimSpace(stage)
+		if e.Allow != nil && !slices.Contains(e.Allow, stage) {
+			return false
+		}
+		if slices.Contains(e.Deny, stage) {
+			return false
+		}
+	}
+	return true
+}
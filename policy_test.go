@@ -0,0 +1,66 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestPolicy(t *testing.T) {
+	pipes := map[string]pipefence.PipeFunc{
+		"banana": func(a []byte) ([]byte, error) {
+			return bytes.ReplaceAll(a, []byte("o"), []byte("a")), nil
+		},
+		"cherry": func(a []byte) ([]byte, error) {
+			return bytes.ToUpper(a), nil
+		},
+	}
+	input := "```banana\nfoo\n```\n\n```cherry\nfoo\n```\n"
+	plainBanana := "<pre><code class=\"language-banana\">foo\n</code></pre>\n"
+	plainCherry := "<pre><code class=\"language-cherry\">foo\n</code></pre>\n"
+
+	for _, tt := range []struct {
+		Name string
+		Ext  *pipefence.Extension
+		Want string
+	}{
+		{
+			Name: "Default",
+			Ext:  &pipefence.Extension{PipeFuncs: pipes},
+			Want: "faa\nFOO\n",
+		},
+		{
+			Name: "Allow",
+			Ext:  &pipefence.Extension{PipeFuncs: pipes, Allow: []string{"cherry"}},
+			Want: plainBanana + "FOO\n",
+		},
+		{
+			Name: "EmptyAllow",
+			Ext:  &pipefence.Extension{PipeFuncs: pipes, Allow: []string{}},
+			Want: plainBanana + plainCherry,
+		},
+		{
+			Name: "Deny",
+			Ext:  &pipefence.Extension{PipeFuncs: pipes, Deny: []string{"cherry"}},
+			Want: "faa\n" + plainCherry,
+		},
+		{
+			Name: "Untrusted",
+			Ext:  &pipefence.Extension{PipeFuncs: pipes, Untrusted: true},
+			Want: plainBanana + plainCherry,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			gmark := goldmark.New(goldmark.WithExtensions(tt.Ext))
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package pipefence
+
+// frame returns content with the prelude and postlude of lang.
+func (e *Extension) frame(lang string, content []byte) []byte {
+	pre, post := e.Preludes[lang], e.Postludes[lang]
+	if len(pre) == 0 && len(post) == 0 {
+		return content
+	}
+	framed := make([]byte, 0, len(pre)+len(content)+len(post))
+	framed = append(framed, pre...)
+	framed = append(framed, content...)
+	return append(framed, post...)
+}
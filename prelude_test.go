@@ -0,0 +1,63 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestPreludes(t *testing.T) {
+	var got []byte
+	echo := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		got = src
+		return []byte("out\n"), nil
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{"gnuplot": echo, "other": echo},
+		Preludes:     map[string][]byte{"gnuplot": []byte("set terminal svg\n")},
+		Postludes:    map[string][]byte{"gnuplot": []byte("unset output\n")},
+	}))
+
+	for _, tt := range []struct {
+		Name    string
+		Input   string
+		WantSrc string
+		Want    string
+	}{
+		{
+			Name:    "Framed",
+			Input:   "```gnuplot\nplot sin(x)\n```\n",
+			WantSrc: "set terminal svg\nplot sin(x)\nunset output\n",
+			Want:    "out\n",
+		},
+		{
+			Name:    "OtherLanguage",
+			Input:   "```other\nx\n```\n",
+			WantSrc: "x\n",
+			Want:    "out\n",
+		},
+		{
+			Name:    "ShowSource",
+			Input:   "```gnuplot showsource\nplot sin(x)\n```\n",
+			WantSrc: "set terminal svg\nplot sin(x)\nunset output\n",
+			Want: "out\n<details class=\"pipefence-source\">\n<summary>Source</summary>\n" +
+				"<pre><code class=\"language-gnuplot\">plot sin(x)\n</code></pre>\n</details>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if string(got) != tt.WantSrc {
+				t.Errorf("pipe got %q, want %q", got, tt.WantSrc)
+			}
+			if buf.String() != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, buf.String(), tt.Want)
+			}
+		})
+	}
+}
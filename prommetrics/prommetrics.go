@@ -0,0 +1,108 @@
+// Package prommetrics exports pipefence metrics to Prometheus.  This
+// package is a separate module, so that users of pipefence who do
+// not need it do not depend on the Prometheus client library.
+//
+// Metrics is a prometheus.Collector, which is registered like any
+// other:
+//
+//	m := prommetrics.New([]string{"dot", "mermaid"})
+//	ext := &pipefence.Extension{Metrics: m}
+//	prometheus.MustRegister(m)
+//
+// The language label only takes the languages passed to New, as
+// documents could otherwise create any number of series by using
+// made-up languages.  Pipe invocations for other languages, such as
+// those handled by a DefaultPipe, are counted as OtherLanguage.
+package prommetrics
+
+import (
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBuckets are the upper bounds of the duration histogram
+// buckets, in seconds.
+var DefaultBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// OtherLanguage is the language label of invocations for languages
+// which were not passed to New.
+const OtherLanguage = "other"
+
+// Metrics implements pipefence.Metrics and prometheus.Collector.
+type Metrics struct {
+	languages map[string]bool
+
+	invocations *prometheus.CounterVec
+	durations   *prometheus.HistogramVec
+	inBytes     *prometheus.CounterVec
+	outBytes    *prometheus.CounterVec
+}
+
+// New returns Metrics for the given languages, using DefaultBuckets.
+func New(languages []string) *Metrics {
+	return NewWithBuckets(languages, DefaultBuckets)
+}
+
+// NewWithBuckets returns Metrics for the given languages, whose
+// duration histogram uses the given bucket upper bounds in seconds.
+func NewWithBuckets(languages []string, buckets []float64) *Metrics {
+	m := &Metrics{
+		languages: make(map[string]bool),
+		invocations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pipefence_pipe_invocations_total",
+			Help: "Number of pipe invocations.",
+		}, []string{"language", "cache", "result"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pipefence_pipe_duration_seconds",
+			Help:    "Duration of pipe invocations.",
+			Buckets: buckets,
+		}, []string{"language"}),
+		inBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pipefence_pipe_input_bytes_total",
+			Help: "Total size of pipe inputs.",
+		}, []string{"language"}),
+		outBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pipefence_pipe_output_bytes_total",
+			Help: "Total size of pipe outputs.",
+		}, []string{"language"}),
+	}
+	for _, lang := range languages {
+		m.languages[lang] = true
+	}
+	return m
+}
+
+// ObservePipe implements pipefence.Metrics.
+func (m *Metrics) ObservePipe(o pipefence.PipeObservation) {
+	lang := o.Language
+	if !m.languages[lang] {
+		lang = OtherLanguage
+	}
+	cache, result := "miss", "ok"
+	if o.CacheHit {
+		cache = "hit"
+	}
+	if o.Err != nil {
+		result = "error"
+	}
+	m.invocations.WithLabelValues(lang, cache, result).Inc()
+	m.durations.WithLabelValues(lang).Observe(o.Duration.Seconds())
+	m.inBytes.WithLabelValues(lang).Add(float64(o.InputSize))
+	m.outBytes.WithLabelValues(lang).Add(float64(o.OutputSize))
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.invocations.Describe(ch)
+	m.durations.Describe(ch)
+	m.inBytes.Describe(ch)
+	m.outBytes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.invocations.Collect(ch)
+	m.durations.Collect(ch)
+	m.inBytes.Collect(ch)
+	m.outBytes.Collect(ch)
+}
@@ -0,0 +1,58 @@
+package prommetrics_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/prommetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var (
+	_ pipefence.Metrics    = (*prommetrics.Metrics)(nil)
+	_ prometheus.Collector = (*prommetrics.Metrics)(nil)
+)
+
+func TestMetrics(t *testing.T) {
+	m := prommetrics.NewWithBuckets([]string{"dot"}, []float64{0.1, 1})
+	m.ObservePipe(pipefence.PipeObservation{Language: "dot", Duration: 50 * time.Millisecond, InputSize: 10, OutputSize: 100})
+	m.ObservePipe(pipefence.PipeObservation{Language: "dot", Duration: 500 * time.Millisecond, InputSize: 10, OutputSize: 100, CacheHit: true})
+	m.ObservePipe(pipefence.PipeObservation{Language: `we"ird`, Duration: 2 * time.Second, Err: errors.New("oops")})
+	m.ObservePipe(pipefence.PipeObservation{Language: "made-up", Duration: 2 * time.Second, Err: errors.New("oops")})
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m)
+	want := `# HELP pipefence_pipe_duration_seconds Duration of pipe invocations.
+# TYPE pipefence_pipe_duration_seconds histogram
+pipefence_pipe_duration_seconds_bucket{language="dot",le="0.1"} 1
+pipefence_pipe_duration_seconds_bucket{language="dot",le="1"} 2
+pipefence_pipe_duration_seconds_bucket{language="dot",le="+Inf"} 2
+pipefence_pipe_duration_seconds_sum{language="dot"} 0.55
+pipefence_pipe_duration_seconds_count{language="dot"} 2
+pipefence_pipe_duration_seconds_bucket{language="other",le="0.1"} 0
+pipefence_pipe_duration_seconds_bucket{language="other",le="1"} 0
+pipefence_pipe_duration_seconds_bucket{language="other",le="+Inf"} 2
+pipefence_pipe_duration_seconds_sum{language="other"} 4
+pipefence_pipe_duration_seconds_count{language="other"} 2
+# HELP pipefence_pipe_input_bytes_total Total size of pipe inputs.
+# TYPE pipefence_pipe_input_bytes_total counter
+pipefence_pipe_input_bytes_total{language="dot"} 20
+pipefence_pipe_input_bytes_total{language="other"} 0
+# HELP pipefence_pipe_invocations_total Number of pipe invocations.
+# TYPE pipefence_pipe_invocations_total counter
+pipefence_pipe_invocations_total{cache="hit",language="dot",result="ok"} 1
+pipefence_pipe_invocations_total{cache="miss",language="dot",result="ok"} 1
+pipefence_pipe_invocations_total{cache="miss",language="other",result="error"} 2
+# HELP pipefence_pipe_output_bytes_total Total size of pipe outputs.
+# TYPE pipefence_pipe_output_bytes_total counter
+pipefence_pipe_output_bytes_total{language="dot"} 200
+pipefence_pipe_output_bytes_total{language="other"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,486 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Level is an error correction level.
+type Level int
+
+// The error correction levels, recovering from about 7%, 15%, 25%
+// and 30% damage.
+const (
+	L Level = iota
+	M
+	Q
+	H
+)
+
+// formatBits are the error correction level bits of the format
+// information.
+var formatBits = [...]int{L: 1, M: 0, Q: 3, H: 2}
+
+// blockSpec describes the error correction blocks of a version and
+// level: ec codewords per block, and count blocks of size data
+// codewords, followed by count2 blocks of size data+1.
+type blockSpec struct {
+	ec, count, data, count2 int
+}
+
+// blockSpecs are indexed by version and level, for versions 1 to
+// maxVersion.
+var blockSpecs = [...][4]blockSpec{
+	1:  {{7, 1, 19, 0}, {10, 1, 16, 0}, {13, 1, 13, 0}, {17, 1, 9, 0}},
+	2:  {{10, 1, 34, 0}, {16, 1, 28, 0}, {22, 1, 22, 0}, {28, 1, 16, 0}},
+	3:  {{15, 1, 55, 0}, {26, 1, 44, 0}, {18, 2, 17, 0}, {22, 2, 13, 0}},
+	4:  {{20, 1, 80, 0}, {18, 2, 32, 0}, {26, 2, 24, 0}, {16, 4, 9, 0}},
+	5:  {{26, 1, 108, 0}, {24, 2, 43, 0}, {18, 2, 15, 2}, {22, 2, 11, 2}},
+	6:  {{18, 2, 68, 0}, {16, 4, 27, 0}, {24, 4, 19, 0}, {28, 4, 15, 0}},
+	7:  {{20, 2, 78, 0}, {18, 4, 31, 0}, {18, 2, 14, 4}, {26, 4, 13, 1}},
+	8:  {{24, 2, 97, 0}, {22, 2, 38, 2}, {22, 4, 18, 2}, {26, 4, 14, 2}},
+	9:  {{30, 2, 116, 0}, {22, 3, 36, 2}, {20, 4, 16, 4}, {24, 4, 12, 4}},
+	10: {{18, 2, 68, 2}, {26, 4, 43, 1}, {24, 6, 19, 2}, {28, 6, 15, 2}},
+}
+
+const maxVersion = len(blockSpecs) - 1
+
+// alignmentPositions are the alignment pattern coordinates per
+// version.
+var alignmentPositions = [...][]int{
+	2: {6, 18}, 3: {6, 22}, 4: {6, 26}, 5: {6, 30}, 6: {6, 34},
+	7: {6, 22, 38}, 8: {6, 24, 42}, 9: {6, 26, 46}, 10: {6, 28, 50},
+}
+
+// remainderBits are the number of unused bits after the codewords
+// per version.
+var remainderBits = [...]int{1: 0, 2: 7, 3: 7, 4: 7, 5: 7, 6: 7, 7: 0, 8: 0, 9: 0, 10: 0}
+
+// ErrTooLong is returned for texts which do not fit into the largest
+// supported QR code.
+var ErrTooLong = errors.New("text too long for a QR code")
+
+// dataCodewords returns the number of data codewords of a version
+// and level.
+func (s blockSpec) dataCodewords() int {
+	return s.count*s.data + s.count2*(s.data+1)
+}
+
+// code is a QR code symbol.
+type code struct {
+	size       int
+	modules    [][]bool // Dark modules, indexed by row and column.
+	isFunction [][]bool
+}
+
+// encode encodes text in byte mode as a QR code of the smallest
+// version which fits.
+func encode(text []byte, level Level) (*code, error) {
+	version := 0
+	for v := 1; v <= maxVersion; v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(text) <= 8*blockSpecs[v][level].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLong, len(text))
+	}
+	spec := blockSpecs[version][level]
+
+	// Data bits: byte mode indicator, character count, data,
+	// terminator and padding.
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	if version >= 10 {
+		bits.append(len(text), 16)
+	} else {
+		bits.append(len(text), 8)
+	}
+	for _, b := range text {
+		bits.append(int(b), 8)
+	}
+	capacity := 8 * spec.dataCodewords()
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	c := newCode(version)
+	c.drawCodewords(interleave(bits.bytes(), spec))
+	c.applyBestMask(level)
+	return c, nil
+}
+
+// interleave splits data into blocks, adds the error correction
+// codewords and interleaves the blocks.
+func interleave(data []byte, spec blockSpec) []byte {
+	var blocks, ecBlocks [][]byte
+	divisor := rsDivisor(spec.ec)
+	for i := 0; i < spec.count+spec.count2; i++ {
+		n := spec.data
+		if i >= spec.count {
+			n++
+		}
+		block := data[:n]
+		data = data[n:]
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+	}
+
+	var out []byte
+	for i := 0; i <= spec.data; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ec; i++ {
+		for _, b := range ecBlocks {
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
+
+// bitBuffer is a sequence of bits.
+type bitBuffer []bool
+
+// append appends the n low bits of v, most significant first.
+func (b *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 == 1)
+	}
+}
+
+// bytes packs the bits into bytes.
+func (b bitBuffer) bytes() []byte {
+	out := make([]byte, (len(b)+7)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the
+// given degree, without the leading coefficient.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return result
+}
+
+// rsRemainder returns the Reed-Solomon error correction codewords
+// for data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+func newCode(version int) *code {
+	size := 4*version + 17
+	c := &code{size: size}
+	c.modules = make([][]bool, size)
+	c.isFunction = make([][]bool, size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.isFunction[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(3, size-4)
+	c.drawFinder(size-4, 3)
+
+	pos := alignmentPositions[version]
+	last := len(pos) - 1
+	for i, r := range pos {
+		for j, col := range pos {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			c.drawAlignment(r, col)
+		}
+	}
+
+	// Reserve the format information areas; they are drawn once
+	// the mask is known.
+	c.drawFormat(0, 0)
+	c.drawVersion(version)
+	return c
+}
+
+func (c *code) setFunction(row, col int, dark bool) {
+	c.modules[row][col] = dark
+	c.isFunction[row][col] = true
+}
+
+// drawFinder draws a finder pattern and its separator around the
+// given center.
+func (c *code) drawFinder(row, col int) {
+	for dr := -4; dr <= 4; dr++ {
+		for dc := -4; dc <= 4; dc++ {
+			r, cc := row+dr, col+dc
+			if r < 0 || r >= c.size || cc < 0 || cc >= c.size {
+				continue
+			}
+			dist := max(abs(dr), abs(dc))
+			c.setFunction(r, cc, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *code) drawAlignment(row, col int) {
+	for dr := -2; dr <= 2; dr++ {
+		for dc := -2; dc <= 2; dc++ {
+			c.setFunction(row+dr, col+dc, max(abs(dr), abs(dc)) != 1)
+		}
+	}
+}
+
+// formatInfo returns the 15 format information bits.
+func formatInfo(level Level, mask int) int {
+	data := formatBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawFormat draws both copies of the format information.
+func (c *code) drawFormat(level Level, mask int) {
+	bits := formatInfo(level, mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(i, 8, bit(i))
+	}
+	c.setFunction(7, 8, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(8, 7, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(8, 14-i, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(8, c.size-1-i, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(c.size-15+i, 8, bit(i))
+	}
+	c.setFunction(c.size-8, 8, true)
+}
+
+// versionInfo returns the 18 version information bits.
+func versionInfo(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// drawVersion draws both copies of the version information, which
+// only versions 7 and up have.
+func (c *code) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	bits := versionInfo(version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 == 1
+		a, b := c.size-11+i%3, i/3
+		c.setFunction(b, a, dark)
+		c.setFunction(a, b, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order.
+func (c *code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				col := right - j
+				row := vert
+				if (right+1)&2 == 0 {
+					row = c.size - 1 - vert
+				}
+				if c.isFunction[row][col] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[row][col] = data[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// masked reports whether the mask pattern inverts the module.
+func masked(mask, row, col int) bool {
+	switch mask {
+	case 0:
+		return (row+col)%2 == 0
+	case 1:
+		return row%2 == 0
+	case 2:
+		return col%3 == 0
+	case 3:
+		return (row+col)%3 == 0
+	case 4:
+		return (row/2+col/3)%2 == 0
+	case 5:
+		return row*col%2+row*col%3 == 0
+	case 6:
+		return (row*col%2+row*col%3)%2 == 0
+	default:
+		return ((row+col)%2+row*col%3)%2 == 0
+	}
+}
+
+// applyMask inverts the data modules according to the mask.  Applying
+// it twice undoes it.
+func (c *code) applyMask(mask int) {
+	for r := 0; r < c.size; r++ {
+		for col := 0; col < c.size; col++ {
+			if !c.isFunction[r][col] && masked(mask, r, col) {
+				c.modules[r][col] = !c.modules[r][col]
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask with the lowest penalty.
+func (c *code) applyBestMask(level Level) {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(level, mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormat(level, best)
+}
+
+// penalty scores the symbol according to the rules of the standard;
+// lower scores are easier to scan.
+func (c *code) penalty() int {
+	p := 0
+	at := func(transpose bool, i, j int) bool {
+		if transpose {
+			return c.modules[j][i]
+		}
+		return c.modules[i][j]
+	}
+	finderLike := []bool{true, false, true, true, true, false, true}
+	for _, transpose := range []bool{false, true} {
+		for i := 0; i < c.size; i++ {
+			run := 1
+			for j := 1; j <= c.size; j++ {
+				if j < c.size && at(transpose, i, j) == at(transpose, i, j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += 3 + run - 5
+				}
+				run = 1
+			}
+			for j := 0; j+7 <= c.size; j++ {
+				match := true
+				for k, dark := range finderLike {
+					if at(transpose, i, j+k) != dark {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				lightBefore, lightAfter := true, true
+				for k := 1; k <= 4; k++ {
+					if j-k >= 0 && at(transpose, i, j-k) {
+						lightBefore = false
+					}
+					if j+6+k < c.size && at(transpose, i, j+6+k) {
+						lightAfter = false
+					}
+				}
+				if lightBefore || lightAfter {
+					p += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for r := 0; r < c.size; r++ {
+		for col := 0; col < c.size; col++ {
+			if c.modules[r][col] {
+				dark++
+			}
+			if r+1 < c.size && col+1 < c.size {
+				v := c.modules[r][col]
+				if c.modules[r][col+1] == v && c.modules[r+1][col] == v && c.modules[r+1][col+1] == v {
+					p += 3
+				}
+			}
+		}
+	}
+	total := c.size * c.size
+	p += abs(dark*20-total*10) / total * 10
+	return p
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,119 @@
+// Package qrcode provides a pipefence pipe rendering URLs or other
+// texts as QR codes in SVG.
+//
+// It is implemented in pure Go and needs no external tools.  Texts
+// are encoded in byte mode, in QR code versions 1 to 10, which
+// holds up to 271 bytes at the lowest error correction level.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"qrcode": qrcode.Pipe(qrcode.Options{}),
+//		},
+//	}
+//
+// The size in pixels and the error correction level ("L", "M", "Q"
+// or "H") can be set with block options:
+//
+//	```qrcode size=120 level=H
+//	https://example.com/
+//	```
+package qrcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// quietZone is the width of the light border around the symbol in
+// modules, as required by the standard.
+const quietZone = 4
+
+// Options are the defaults for the block options.
+type Options struct {
+	// Size is the width and height of the SVG in pixels.  If zero,
+	// 200 is used.
+	Size int
+
+	// Level is the error correction level.  The zero value is L;
+	// use M for the usual default.
+	Level Level
+}
+
+// Pipe returns a pipe function rendering the block content, without
+// the trailing line break, as a QR code.
+func Pipe(opts Options) pipefence.PipeFuncCtx {
+	return func(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		size := opts.Size
+		if size == 0 {
+			size = 200
+		}
+		if s, ok := info.Options["size"]; ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid size %q", s)
+			}
+			size = n
+		}
+		level := opts.Level
+		if l, ok := info.Options["level"]; ok {
+			var err error
+			if level, err = ParseLevel(l); err != nil {
+				return nil, err
+			}
+		}
+		return SVG(bytes.TrimRight(src, "\r\n"), level, size)
+	}
+}
+
+// ParseLevel parses an error correction level "L", "M", "Q" or "H".
+func ParseLevel(s string) (Level, error) {
+	switch s {
+	case "L", "l":
+		return L, nil
+	case "M", "m":
+		return M, nil
+	case "Q", "q":
+		return Q, nil
+	case "H", "h":
+		return H, nil
+	}
+	return 0, fmt.Errorf("invalid error correction level %q", s)
+}
+
+// SVG encodes text as a QR code and renders it as an SVG of the
+// given size in pixels.
+func SVG(text []byte, level Level, size int) ([]byte, error) {
+	c, err := encode(text, level)
+	if err != nil {
+		return nil, err
+	}
+
+	dim := c.size + 2*quietZone
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, dim, dim)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", dim, dim)
+	buf.WriteString(`<path fill="#000" d="`)
+	for r := 0; r < c.size; r++ {
+		for col := 0; col < c.size; col++ {
+			if !c.modules[r][col] {
+				continue
+			}
+			// Draw horizontal runs of dark modules as one rectangle.
+			n := 1
+			for col+n < c.size && c.modules[r][col+n] {
+				n++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", col+quietZone, r+quietZone, n, n)
+			col += n - 1
+		}
+	}
+	buf.WriteString("\"/>\n</svg>\n")
+	return buf.Bytes(), nil
+}
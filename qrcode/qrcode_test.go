@@ -0,0 +1,186 @@
+package qrcode_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/qrcode"
+	"github.com/yuin/goldmark"
+)
+
+// modules decodes the dark modules from the path of a rendered QR
+// code.
+func modules(t *testing.T, svg []byte) []string {
+	t.Helper()
+	m := regexp.MustCompile(`viewBox="0 0 (\d+) \d+"`).FindSubmatch(svg)
+	if m == nil {
+		t.Fatalf("no viewBox in %s", svg)
+	}
+	var dim int
+	fmt.Sscan(string(m[1]), &dim)
+	size := dim - 8
+	grid := make([][]byte, size)
+	for i := range grid {
+		grid[i] = bytes.Repeat([]byte("."), size)
+	}
+	for _, run := range regexp.MustCompile(`M(\d+) (\d+)h(\d+)`).FindAllSubmatch(svg, -1) {
+		var x, y, n int
+		fmt.Sscan(string(run[1])+" "+string(run[2])+" "+string(run[3]), &x, &y, &n)
+		for i := 0; i < n; i++ {
+			grid[y-4][x-4+i] = '#'
+		}
+	}
+	rows := make([]string, size)
+	for i, r := range grid {
+		rows[i] = string(r)
+	}
+	return rows
+}
+
+func TestSVG(t *testing.T) {
+	svg, err := qrcode.SVG([]byte("https://example.com/"), qrcode.M, 100)
+	if err != nil {
+		t.Fatalf("SVG: %v", err)
+	}
+	if !bytes.HasPrefix(svg, []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100" viewBox="0 0 33 33"`)) {
+		t.Errorf("SVG header = %s", svg)
+	}
+
+	// Generated with an independent encoder, using the same mask.
+	want := []string{
+		"#######.###.#.#...#######",
+		"#.....#..######.#.#.....#",
+		"#.###.#.##..##..#.#.###.#",
+		"#.###.#....####...#.###.#",
+		"#.###.#..##...#...#.###.#",
+		"#.....#.##..#.#...#.....#",
+		"#######.#.#.#.#.#.#######",
+		"..........###.###........",
+		"#.#...##..#...#.#..#..#.#",
+		".###.#.#.#.###.#.###.#.##",
+		".###.###.#.#...#.#..###.#",
+		"#.###..#.#....#.#..#.#...",
+		"####.##.####.####.##....#",
+		"..#....#.##..#.##.##...##",
+		"###.#.#.##.#...####..##.#",
+		"..##.#.#.#...#..##.###...",
+		"###.###.#..###..#####..#.",
+		"........#...#...#...#...#",
+		"#######.#.#.###.#.#.#...#",
+		"#.....#...###.###...#....",
+		"#.###.#......########...#",
+		"#.###.#...#..##..#..#.##.",
+		"#.###.#.####...###.###.##",
+		"#.....#..##..#.######....",
+		"#######.#..###..#.#..#..#",
+	}
+	if got := modules(t, svg); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("modules =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSVGVersions(t *testing.T) {
+	for _, tt := range []struct {
+		Len   int
+		Level qrcode.Level
+		Size  int
+	}{
+		{Len: 1, Level: qrcode.L, Size: 21},
+		{Len: 17, Level: qrcode.L, Size: 21},
+		{Len: 18, Level: qrcode.L, Size: 25},
+		{Len: 17, Level: qrcode.H, Size: 29},
+		{Len: 140, Level: qrcode.L, Size: 45},
+		{Len: 271, Level: qrcode.L, Size: 57},
+		{Len: 119, Level: qrcode.H, Size: 57},
+	} {
+		t.Run(fmt.Sprintf("%d/%d", tt.Len, tt.Level), func(t *testing.T) {
+			svg, err := qrcode.SVG(bytes.Repeat([]byte("a"), tt.Len), tt.Level, 200)
+			if err != nil {
+				t.Fatalf("SVG: %v", err)
+			}
+			got := modules(t, svg)
+			if len(got) != tt.Size {
+				t.Errorf("size = %d, want %d", len(got), tt.Size)
+			}
+			// Finder pattern and timing pattern.
+			if got[0][:8] != "#######." || got[6][:8] != "#######." {
+				t.Errorf("no finder pattern in %v", got)
+			}
+			for i := 8; i < tt.Size-8; i++ {
+				if (got[6][i] == '#') != (i%2 == 0) {
+					t.Errorf("no timing pattern in %v", got)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSVGTooLong(t *testing.T) {
+	_, err := qrcode.SVG(bytes.Repeat([]byte("a"), 120), qrcode.H, 200)
+	if !errors.Is(err, qrcode.ErrTooLong) {
+		t.Errorf("SVG(120 bytes, H) = %v, want ErrTooLong", err)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"qrcode": qrcode.Pipe(qrcode.Options{Level: qrcode.M}),
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Default",
+			Input: "```qrcode\nhttps://example.com/\n```\n",
+			Want:  `<svg xmlns="http://www.w3.org/2000/svg" width="200" height="200" viewBox="0 0 33 33"`,
+		},
+		{
+			Name:  "Options",
+			Input: "```qrcode size=64 level=H\nhttps://example.com/\n```\n",
+			Want:  `<svg xmlns="http://www.w3.org/2000/svg" width="64" height="64" viewBox="0 0 37 37"`,
+		},
+		{
+			Name:  "InvalidLevel",
+			Input: "```qrcode level=X\nhi\n```\n",
+			Want:  `invalid error correction level &quot;X&quot;`,
+		},
+		{
+			Name:  "InvalidSize",
+			Input: "```qrcode size=-1\nhi\n```\n",
+			Want:  `invalid size &quot;-1&quot;`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+			if !strings.Contains(buf.String(), tt.Want) {
+				t.Errorf("Convert(%q) = %s, want %s", tt.Input, buf.String(), tt.Want)
+			}
+		})
+	}
+
+	// The block content is encoded without its trailing line break.
+	want, err := qrcode.SVG([]byte("https://example.com/"), qrcode.M, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := qrcode.Pipe(qrcode.Options{Level: qrcode.M})(context.Background(), []byte("https://example.com/\n"), pipefence.Info{})
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Pipe() = %s, %v, want %s", got, err, want)
+	}
+}
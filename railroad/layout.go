@@ -0,0 +1,208 @@
+package railroad
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// Layout dimensions in SVG user units.
+const (
+	charWidth   = 8  // Width of a character.
+	boxHeight   = 22 // Height of terminal and nonterminal boxes.
+	gap         = 10 // Horizontal gap between sequence items.
+	vgap        = 10 // Vertical gap between alternatives.
+	radius      = 10 // Radius of the curves.
+	padding     = 10 // Padding around the diagrams.
+	titleHeight = 24 // Height of the rule name above a diagram.
+	ruleSpacing = 20 // Vertical space between rules.
+)
+
+// canvas collects the SVG elements of the diagrams.
+type canvas struct {
+	lines  bytes.Buffer // Path data of the tracks.
+	shapes bytes.Buffer
+	texts  bytes.Buffer
+}
+
+// node is an element of a railroad diagram.  Its track enters on the
+// left at the baseline and leaves on the right at the baseline; up
+// and down are its extents above and below the baseline.
+type node interface {
+	width() int
+	up() int
+	down() int
+	draw(c *canvas, x, y int)
+}
+
+// box is a terminal, drawn as a rounded box, or a nonterminal.
+type box struct {
+	text     string
+	terminal bool
+}
+
+func (b *box) width() int { return utf8.RuneCountInString(b.text)*charWidth + 2*gap }
+func (b *box) up() int    { return boxHeight / 2 }
+func (b *box) down() int  { return boxHeight / 2 }
+
+func (b *box) draw(c *canvas, x, y int) {
+	rx := 0
+	if b.terminal {
+		rx = boxHeight / 2
+	}
+	fmt.Fprintf(&c.shapes, `<rect x="%d" y="%d" width="%d" height="%d" rx="%d"/>`+"\n",
+		x, y-boxHeight/2, b.width(), boxHeight, rx)
+	fmt.Fprintf(&c.texts, `<text x="%d" y="%d" text-anchor="middle">%s</text>`+"\n",
+		x+b.width()/2, y+4, util.EscapeHTML([]byte(b.text)))
+}
+
+// skip is an empty track.
+type skip struct{}
+
+func (skip) width() int             { return 0 }
+func (skip) up() int                { return 0 }
+func (skip) down() int              { return 0 }
+func (skip) draw(*canvas, int, int) {}
+
+// sequence is a series of nodes.
+type sequence struct {
+	items []node
+}
+
+// newSequence returns a node for the given items.
+func newSequence(items []node) node {
+	switch len(items) {
+	case 0:
+		return skip{}
+	case 1:
+		return items[0]
+	}
+	return &sequence{items: items}
+}
+
+func (s *sequence) width() int {
+	w := gap * (len(s.items) - 1)
+	for _, item := range s.items {
+		w += item.width()
+	}
+	return w
+}
+
+func (s *sequence) up() int {
+	u := 0
+	for _, item := range s.items {
+		u = max(u, item.up())
+	}
+	return u
+}
+
+func (s *sequence) down() int {
+	d := 0
+	for _, item := range s.items {
+		d = max(d, item.down())
+	}
+	return d
+}
+
+func (s *sequence) draw(c *canvas, x, y int) {
+	for i, item := range s.items {
+		if i > 0 {
+			fmt.Fprintf(&c.lines, "M%d %dh%d ", x, y, gap)
+			x += gap
+		}
+		item.draw(c, x, y)
+		x += item.width()
+	}
+}
+
+// choice is a set of alternatives.  The first one is on the
+// baseline, the others branch off below.
+type choice struct {
+	items []node
+}
+
+// optional returns a choice between skipping n and n.
+func optional(n node) node {
+	return &choice{items: []node{skip{}, n}}
+}
+
+// offsets returns the baselines of the alternatives relative to the
+// baseline of the choice.
+func (ch *choice) offsets() []int {
+	offs := make([]int, len(ch.items))
+	for i := 1; i < len(ch.items); i++ {
+		offs[i] = max(offs[i-1]+ch.items[i-1].down()+vgap+ch.items[i].up(), 2*radius)
+	}
+	return offs
+}
+
+func (ch *choice) width() int {
+	w := 0
+	for _, item := range ch.items {
+		w = max(w, item.width())
+	}
+	return w + 4*radius
+}
+
+func (ch *choice) up() int { return ch.items[0].up() }
+
+func (ch *choice) down() int {
+	last := len(ch.items) - 1
+	return ch.offsets()[last] + ch.items[last].down()
+}
+
+func (ch *choice) draw(c *canvas, x, y int) {
+	w := ch.width()
+	for i, off := range ch.offsets() {
+		item := ch.items[i]
+		if i == 0 {
+			fmt.Fprintf(&c.lines, "M%d %dh%d ", x, y, 2*radius)
+		} else {
+			fmt.Fprintf(&c.lines, "M%d %da%d %d 0 0 1 %d %dv%d a%d %d 0 0 0 %d %d ",
+				x, y, radius, radius, radius, radius, off-2*radius, radius, radius, radius, radius)
+		}
+		item.draw(c, x+2*radius, y+off)
+		fmt.Fprintf(&c.lines, "M%d %d", x+2*radius+item.width(), y+off)
+		if pad := w - 4*radius - item.width(); pad > 0 {
+			fmt.Fprintf(&c.lines, "h%d", pad)
+		}
+		if i == 0 {
+			fmt.Fprintf(&c.lines, " h%d ", 2*radius)
+		} else {
+			fmt.Fprintf(&c.lines, " a%d %d 0 0 0 %d %dv%d a%d %d 0 0 1 %d %d ",
+				radius, radius, radius, -radius, -(off - 2*radius), radius, radius, radius, -radius)
+		}
+	}
+}
+
+// oneOrMore is a repetition of a node, with a track looping back
+// below it.
+type oneOrMore struct {
+	item node
+}
+
+// loop returns the offset of the loop track relative to the
+// baseline.
+func (o *oneOrMore) loop() int {
+	return max(o.item.down()+vgap, 2*radius)
+}
+
+func (o *oneOrMore) width() int { return o.item.width() + 2*radius }
+func (o *oneOrMore) up() int    { return o.item.up() }
+func (o *oneOrMore) down() int  { return o.loop() }
+
+func (o *oneOrMore) draw(c *canvas, x, y int) {
+	iw := o.item.width()
+	fmt.Fprintf(&c.lines, "M%d %dh%d ", x, y, radius)
+	o.item.draw(c, x+radius, y)
+	fmt.Fprintf(&c.lines, "M%d %dh%d ", x+radius+iw, y, radius)
+
+	// The loop, drawn clockwise from the end of the item.
+	fmt.Fprintf(&c.lines, "M%d %da%d %d 0 0 1 %d %dv%d a%d %d 0 0 1 %d %dh%d a%d %d 0 0 1 %d %dv%d a%d %d 0 0 1 %d %d ",
+		x+radius+iw, y, radius, radius, radius, radius, o.loop()-2*radius,
+		radius, radius, -radius, radius, -iw,
+		radius, radius, -radius, -radius, -(o.loop() - 2*radius),
+		radius, radius, radius, -radius)
+}
@@ -0,0 +1,212 @@
+package railroad
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// rule is a grammar rule.
+type rule struct {
+	name string
+	expr node
+}
+
+// token is a lexical token of a grammar.
+type token struct {
+	kind rune // 'i' for identifiers, 's' for strings, else the operator.
+	text string
+	line int
+}
+
+// tokenize splits src into tokens, skipping comments.
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	line := 1
+	for len(src) > 0 {
+		r, n := utf8.DecodeRuneInString(src)
+		switch {
+		case r == '\n':
+			line++
+			src = src[n:]
+		case unicode.IsSpace(r):
+			src = src[n:]
+		case strings.HasPrefix(src, "(*"):
+			end := strings.Index(src, "*)")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[:end], "\n")
+			src = src[end+2:]
+		case strings.HasPrefix(src, "::="):
+			toks = append(toks, token{kind: '=', text: "::=", line: line})
+			src = src[3:]
+		case r == '"' || r == '\'':
+			end := strings.IndexRune(src[1:], r)
+			if end < 0 || strings.Contains(src[1:end+1], "\n") {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			toks = append(toks, token{kind: 's', text: src[1 : end+1], line: line})
+			src = src[end+2:]
+		case r == '_' || unicode.IsLetter(r):
+			end := strings.IndexFunc(src, func(r rune) bool {
+				return r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if end < 0 {
+				end = len(src)
+			}
+			toks = append(toks, token{kind: 'i', text: src[:end], line: line})
+			src = src[end:]
+		case strings.ContainsRune("=:|,;.()[]{}?*+", r):
+			if r == ':' {
+				r = '='
+			}
+			toks = append(toks, token{kind: r, text: src[:n], line: line})
+			src = src[n:]
+		default:
+			return nil, fmt.Errorf("line %d: unexpected character %q", line, r)
+		}
+	}
+	return toks, nil
+}
+
+// parser parses EBNF grammars.
+type parser struct {
+	toks []token
+}
+
+// parse parses the rules of an EBNF grammar.
+func parse(src string) ([]rule, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	var rules []rule
+	for len(p.toks) > 0 {
+		name := p.toks[0]
+		if name.kind != 'i' || len(p.toks) < 2 || p.toks[1].kind != '=' {
+			return nil, fmt.Errorf("line %d: expected rule definition, found %q", name.line, name.text)
+		}
+		p.toks = p.toks[2:]
+		expr, err := p.choice()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == ';' || p.peek() == '.' {
+			p.toks = p.toks[1:]
+		}
+		rules = append(rules, rule{name: name.text, expr: expr})
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no rules")
+	}
+	return rules, nil
+}
+
+// peek returns the kind of the next token, or 0 at the end.
+func (p *parser) peek() rune {
+	if len(p.toks) == 0 {
+		return 0
+	}
+	return p.toks[0].kind
+}
+
+// atRuleStart reports whether the next tokens start a new rule, for
+// grammars without rule terminators.
+func (p *parser) atRuleStart() bool {
+	return len(p.toks) >= 2 && p.toks[0].kind == 'i' && p.toks[1].kind == '='
+}
+
+func (p *parser) choice() (node, error) {
+	var items []node
+	for {
+		seq, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, seq)
+		if p.peek() != '|' {
+			break
+		}
+		p.toks = p.toks[1:]
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return &choice{items: items}, nil
+}
+
+func (p *parser) sequence() (node, error) {
+	var items []node
+	for {
+		switch p.peek() {
+		case 'i', 's', '(', '[', '{':
+			if p.atRuleStart() {
+				return newSequence(items), nil
+			}
+		default:
+			return newSequence(items), nil
+		}
+		item, err := p.postfix()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.peek() == ',' {
+			p.toks = p.toks[1:]
+		}
+	}
+}
+
+func (p *parser) postfix() (node, error) {
+	n, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case '?':
+			n = optional(n)
+		case '*':
+			n = optional(&oneOrMore{item: n})
+		case '+':
+			n = &oneOrMore{item: n}
+		default:
+			return n, nil
+		}
+		p.toks = p.toks[1:]
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.toks[0]
+	p.toks = p.toks[1:]
+	switch tok.kind {
+	case 'i':
+		return &box{text: tok.text}, nil
+	case 's':
+		return &box{text: tok.text, terminal: true}, nil
+	}
+
+	closing := map[rune]rune{'(': ')', '[': ']', '{': '}'}[tok.kind]
+	n, err := p.choice()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != closing {
+		if len(p.toks) == 0 {
+			return nil, fmt.Errorf("line %d: missing %q", tok.line, closing)
+		}
+		return nil, fmt.Errorf("line %d: expected %q, found %q", p.toks[0].line, closing, p.toks[0].text)
+	}
+	p.toks = p.toks[1:]
+	switch tok.kind {
+	case '[':
+		return optional(n), nil
+	case '{':
+		return optional(&oneOrMore{item: n}), nil
+	}
+	return n, nil
+}
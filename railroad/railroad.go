@@ -0,0 +1,81 @@
+// Package railroad provides a pipefence pipe rendering EBNF grammars
+// as SVG railroad diagrams.
+//
+// It is implemented in pure Go and needs no external tools.  It
+// accepts the common EBNF dialects:
+//
+//	digit  = "0" | "1" | "2" ;
+//	number ::= ["-"] digit {digit}
+//	list   : "(" (number ("," number)*)? ")"
+//
+// Rules are defined with "=", "::=" or ":", and may be terminated
+// with ";" or ".".  Alternatives are separated with "|", sequence
+// items optionally with ",".  Options are written as [...] or with
+// "?", repetitions as {...} or with "*" and "+".  Terminals are
+// quoted with "..." or '...'; comments are written as (* ... *).
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"ebnf": railroad.Pipe,
+//		},
+//	}
+package railroad
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark/util"
+)
+
+// Pipe is a pipefence.PipeFuncCtx converting an EBNF grammar to
+// railroad diagrams in SVG.
+func Pipe(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+	return Render(src)
+}
+
+// Render converts an EBNF grammar to railroad diagrams, one per
+// rule, in a single SVG.
+func Render(src []byte) ([]byte, error) {
+	rules, err := parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var c canvas
+	width, y := 0, padding
+	for _, r := range rules {
+		fmt.Fprintf(&c.texts, `<text x="%d" y="%d" font-weight="bold">%s</text>`+"\n",
+			padding, y+12, util.EscapeHTML([]byte(r.name)))
+		y += titleHeight + r.expr.up()
+
+		// Start and end markers, and the diagram between them.
+		x := padding
+		w := r.expr.width()
+		fmt.Fprintf(&c.lines, "M%d %dv16 M%d %dh%d ", x, y-8, x, y, gap)
+		r.expr.draw(&c, x+gap, y)
+		fmt.Fprintf(&c.lines, "M%d %dh%d v-8 v16 ", x+gap+w, y, gap)
+
+		width = max(width, 2*gap+w)
+		y += r.expr.down() + ruleSpacing
+	}
+	width += 2 * padding
+	height := y - ruleSpacing + padding
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" class="railroad" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="13">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&buf, `<path d="%s" stroke="currentColor" stroke-width="1.5" fill="none"/>`+"\n", strings.TrimSpace(c.lines.String()))
+	buf.WriteString(`<g stroke="currentColor" stroke-width="1.5" fill="none">` + "\n")
+	buf.Write(c.shapes.Bytes())
+	buf.WriteString("</g>\n")
+	buf.WriteString(`<g fill="currentColor">` + "\n")
+	buf.Write(c.texts.Bytes())
+	buf.WriteString("</g>\n</svg>\n")
+	return buf.Bytes(), nil
+}
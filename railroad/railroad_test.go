@@ -0,0 +1,142 @@
+package railroad_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/railroad"
+	"github.com/yuin/goldmark"
+)
+
+func TestRenderTerminal(t *testing.T) {
+	got, err := railroad.Render([]byte(`a = "x" ;`))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	want := `<svg xmlns="http://www.w3.org/2000/svg" class="railroad" width="68" height="66" viewBox="0 0 68 66" font-family="monospace" font-size="13">
+<path d="M10 37v16 M10 45h10 M48 45h10 v-8 v16" stroke="currentColor" stroke-width="1.5" fill="none"/>
+<g stroke="currentColor" stroke-width="1.5" fill="none">
+<rect x="20" y="34" width="28" height="22" rx="11"/>
+</g>
+<g fill="currentColor">
+<text x="10" y="22" font-weight="bold">a</text>
+<text x="34" y="49" text-anchor="middle">x</text>
+</g>
+</svg>
+`
+	if string(got) != want {
+		t.Errorf("Render(terminal) = %s, want %s", got, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  []string
+	}{
+		{
+			Name:  "Nonterminal",
+			Input: "a ::= b",
+			Want:  []string{`<rect x="20" y="34" width="28" height="22" rx="0"/>`},
+		},
+		{
+			Name:  "Sequence",
+			Input: `a = "x", y`,
+			Want: []string{
+				`M48 45h10`,
+				`<rect x="58" y="34" width="28" height="22" rx="0"/>`,
+			},
+		},
+		{
+			Name:  "Choice",
+			Input: `a = "x" | "y"`,
+			// The second alternative branches off 32 units below.
+			Want: []string{
+				`M20 45a10 10 0 0 1 10 10v12 a10 10 0 0 0 10 10`,
+				`<rect x="40" y="66" width="28" height="22" rx="11"/>`,
+			},
+		},
+		{
+			Name:  "Optional",
+			Input: `a = ["x"]`,
+			Want: []string{
+				`M20 34h20 M40 34h28 h20`,
+				`<rect x="40" y="44" width="28" height="22" rx="11"/>`,
+			},
+		},
+		{
+			Name:  "OneOrMore",
+			Input: `a = "x"+`,
+			Want: []string{
+				`M20 45h10 M58 45h10`,
+				`M58 45a10 10 0 0 1 10 10v1 a10 10 0 0 1 -10 10h-28 a10 10 0 0 1 -10 -10v-1 a10 10 0 0 1 10 -10`,
+			},
+		},
+		{
+			Name: "Rules",
+			Input: "(* Numbers. *)\n" +
+				"digit = '0' | '1' .\n" +
+				"number : digit {digit}\n",
+			Want: []string{
+				`<text x="10" y="22" font-weight="bold">digit</text>`,
+				`font-weight="bold">number</text>`,
+			},
+		},
+		{
+			Name:  "Escaping",
+			Input: `a = "<&>"`,
+			Want:  []string{`>&lt;&amp;&gt;</text>`},
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, err := railroad.Render([]byte(tt.Input))
+			if err != nil {
+				t.Fatalf("Render(%q): %v", tt.Input, err)
+			}
+			for _, want := range tt.Want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Render(%q) = %s, want it to contain %s", tt.Input, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	for _, tt := range []struct {
+		Input string
+		Want  string
+	}{
+		{Input: "", Want: "no rules"},
+		{Input: `"x" = a`, Want: `line 1: expected rule definition, found "x"`},
+		{Input: "a = (b\n", Want: `line 1: missing ')'`},
+		{Input: "a = [b}\n", Want: `line 1: expected ']', found "}"`},
+		{Input: "a = \"b\n", Want: "line 1: unterminated string"},
+		{Input: "\na = b (* c", Want: "line 2: unterminated comment"},
+		{Input: "a = b # c", Want: `line 1: unexpected character '#'`},
+	} {
+		_, err := railroad.Render([]byte(tt.Input))
+		if err == nil || err.Error() != tt.Want {
+			t.Errorf("Render(%q) = %v, want %s", tt.Input, err, tt.Want)
+		}
+	}
+}
+
+func TestPipe(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"ebnf": railroad.Pipe,
+		},
+	}))
+
+	var buf bytes.Buffer
+	if err := gmark.Convert([]byte("```ebnf\na = \"x\" ;\n```\n"), &buf); err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), `<svg xmlns="http://www.w3.org/2000/svg" class="railroad"`) {
+		t.Errorf("Convert() = %s, want SVG", buf.String())
+	}
+}
@@ -0,0 +1,139 @@
+// Package rediscache provides a pipefence.Cache which stores pipe
+// outputs in Redis, so that CI machines can share rendered outputs
+// between pipeline runs.  This package is a separate module, so that
+// users of pipefence who do not need it do not depend on the Redis
+// client library.
+//
+//	cache := rediscache.New(rediscache.Options{
+//		Addr: "redis.internal:6379",
+//		TTL:  30 * 24 * time.Hour,
+//	})
+//	defer cache.Close()
+//	ext := &pipefence.Extension{Cache: cache}
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/redis/go-redis/v9"
+)
+
+// Options configure a Cache.
+type Options struct {
+	// Addr is the host:port of the Redis server.  If empty,
+	// "localhost:6379" is used.
+	Addr string
+
+	// Password, if set, is used to authenticate.
+	Password string
+
+	// DB is the number of the database to select.
+	DB int
+
+	// Prefix is prepended to the cache keys, e.g. "pipefence:".
+	Prefix string
+
+	// TTL is the time after which entries expire.  If zero, they
+	// do not expire.
+	TTL time.Duration
+
+	// Timeout limits connecting to the server and each request.
+	// If zero, one second is used.
+	Timeout time.Duration
+
+	// MaxIdle is the number of idle connections kept open.  If
+	// zero, two are kept.
+	MaxIdle int
+}
+
+// Cache is a pipefence.StatsCache backed by Redis.
+//
+// Errors, such as an unreachable server, are treated as cache misses,
+// so that pages are still rendered.
+type Cache struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	stats  pipefence.CacheStats
+	errors int
+}
+
+// New returns a Cache using the server described by opts.  It
+// connects on first use.
+func New(opts Options) *Cache {
+	if opts.Addr == "" {
+		opts.Addr = "localhost:6379"
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.MaxIdle == 0 {
+		opts.MaxIdle = 2
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:         opts.Addr,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		DialTimeout:  opts.Timeout,
+		ReadTimeout:  opts.Timeout,
+		WriteTimeout: opts.Timeout,
+		MaxIdleConns: opts.MaxIdle,
+		MaxRetries:   -1,
+	})
+	return &Cache{client: client, prefix: opts.Prefix, ttl: opts.TTL}
+}
+
+// Get implements pipefence.Cache.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	v, err := c.client.Get(context.Background(), c.prefix+key).Bytes()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.errors++
+		}
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	return v, true
+}
+
+// Set implements pipefence.Cache.
+func (c *Cache) Set(key string, value []byte) {
+	if err := c.client.Set(context.Background(), c.prefix+key, value, c.ttl).Err(); err != nil {
+		c.mu.Lock()
+		c.errors++
+		c.mu.Unlock()
+	}
+}
+
+// Stats implements pipefence.StatsCache.  Failed requests count as
+// misses.
+func (c *Cache) Stats() pipefence.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// Errors returns the number of failed requests.
+func (c *Cache) Errors() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.errors
+}
+
+// Close closes the connections.  The Cache must not be used
+// afterwards.
+func (c *Cache) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,85 @@
+package rediscache_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/rediscache"
+)
+
+var _ pipefence.StatsCache = (*rediscache.Cache)(nil)
+
+func TestCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	c := rediscache.New(rediscache.Options{
+		Addr:     server.Addr(),
+		Password: "secret",
+		DB:       3,
+		Prefix:   "pf:",
+		TTL:      time.Hour,
+	})
+	defer c.Close()
+
+	if _, ok := c.Get("abc"); ok {
+		t.Errorf("Get(abc) on empty cache: ok = true, want false")
+	}
+	value := "<svg>\r\n$5\r\n</svg>"
+	c.Set("abc", []byte(value))
+	c.Set("empty", nil)
+	if got, ok := c.Get("abc"); !ok || string(got) != value {
+		t.Errorf("Get(abc) = %q, %v, want %q, true", got, ok, value)
+	}
+	if got, ok := c.Get("empty"); !ok || len(got) != 0 {
+		t.Errorf("Get(empty) = %q, %v, want empty, true", got, ok)
+	}
+
+	if got, want := c.Stats(), (pipefence.CacheStats{Hits: 2, Misses: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if c.Errors() != 0 {
+		t.Errorf("Errors() = %d, want 0", c.Errors())
+	}
+
+	db := server.DB(3)
+	if got, err := db.Get("pf:abc"); err != nil || got != value {
+		t.Errorf("server value of pf:abc = %q, %v, want %q", got, err, value)
+	}
+	if got := db.TTL("pf:abc"); got != time.Hour {
+		t.Errorf("server TTL of pf:abc = %v, want %v", got, time.Hour)
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	// Failures count as misses.
+	c := rediscache.New(rediscache.Options{Addr: server.Addr(), Password: "wrong"})
+	defer c.Close()
+	c.Set("abc", []byte("x"))
+	if _, ok := c.Get("abc"); ok {
+		t.Errorf("Get(abc) with wrong password: ok = true, want false")
+	}
+	if got, want := c.Stats(), (pipefence.CacheStats{Misses: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if c.Errors() != 2 {
+		t.Errorf("Errors() = %d, want 2", c.Errors())
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("net.Listen: %v", err)
+	}
+	unreachable := l.Addr().String()
+	l.Close()
+	c = rediscache.New(rediscache.Options{Addr: unreachable, Timeout: 100 * time.Millisecond})
+	defer c.Close()
+	if _, ok := c.Get("abc"); ok || c.Errors() != 1 {
+		t.Errorf("Get on unreachable server: ok = %v, Errors() = %d, want false, 1", ok, c.Errors())
+	}
+}
@@ -0,0 +1,87 @@
+package pipefence
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// refPrefix is the prefix of image destinations referencing named
+// blocks, as in ![topology](#pipefence:topology).
+const refPrefix = "#pipefence:"
+
+// refJob returns a job for img if it references a named block, and
+// nil otherwise.
+func refJob(img *ast.Image, src []byte) *job {
+	name, ok := strings.CutPrefix(string(img.Destination), refPrefix)
+	if !ok || name == "" {
+		return nil
+	}
+	line := 1
+	for n := img.Parent(); n != nil; n = n.Parent() {
+		if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+			line = bytes.Count(src[:n.Lines().At(0).Start], []byte("\n")) + 1
+			break
+		}
+	}
+	return &job{
+		info:   Info{Options: map[string]string{}},
+		line:   line,
+		inline: true,
+		ref:    name,
+	}
+}
+
+// linkReferences links the jobs referencing named blocks to the jobs
+// of these blocks.  Blocks are named with the name option, and
+// referenced with the ref option or with images such as
+// ![topology](#pipefence:topology).  References to unknown names
+// fail.  It returns warnings about duplicate names.
+func linkReferences(jobs []*job) []Warning {
+	var (
+		named = make(map[string]*job)
+		warns []Warning
+	)
+	for _, j := range jobs {
+		name := j.info.Options["name"]
+		if name == "" || j.ref != "" {
+			continue
+		}
+		if _, ok := named[name]; ok {
+			warns = append(warns, Warning{
+				Line:     j.line,
+				Language: j.info.Language,
+				Message:  fmt.Sprintf("duplicate block name %q, references use the first block", name),
+			})
+			continue
+		}
+		named[name] = j
+	}
+	for _, j := range jobs {
+		if j.ref == "" {
+			continue
+		}
+		target, ok := named[j.ref]
+		if !ok {
+			j.err = fmt.Errorf("reference to unknown block %q", j.ref)
+			continue
+		}
+		j.target = target
+		if j.info.Language == "" {
+			j.info.Language = target.info.Language
+		}
+	}
+	return warns
+}
+
+// fillReferences copies the results of the referenced blocks to the
+// jobs referencing them.
+func fillReferences(jobs []*job) {
+	for _, j := range jobs {
+		if t := j.target; t != nil {
+			j.output, j.err, j.assets = t.output, t.err, t.assets
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestReferences(t *testing.T) {
+	calls := 0
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"upper": func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				calls++
+				return bytes.ToUpper(src), nil
+			},
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		Name      string
+		Input     string
+		Want      string
+		WantCalls int
+	}{
+		{
+			Name:      "FenceReference",
+			Input:     "```upper ref=x\n```\n\n```upper name=x\nabc\n```\n",
+			Want:      "ABC\nABC\n",
+			WantCalls: 1,
+		},
+		{
+			Name:      "ImageReference",
+			Input:     "```upper name=x\nabc\n```\n\nSee ![the diagram](#pipefence:x).\n",
+			Want:      "ABC\n<p>See ABC\n.</p>\n",
+			WantCalls: 1,
+		},
+		{
+			Name:      "UnknownName",
+			Input:     "```upper ref=y\n```\n",
+			Want:      "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">reference to unknown block &quot;y&quot;</pre>\n",
+			WantCalls: 0,
+		},
+		{
+			Name:      "OtherImage",
+			Input:     "![x](#other)\n",
+			Want:      "<p><img src=\"#other\" alt=\"x\"></p>\n",
+			WantCalls: 0,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			calls = 0
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+			if calls != tt.WantCalls {
+				t.Errorf("pipe called %d times, want %d", calls, tt.WantCalls)
+			}
+		})
+	}
+}
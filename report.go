@@ -0,0 +1,120 @@
+package pipefence
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/parser"
+)
+
+// Report describes the blocks piped in a conversion, e.g. for build
+// summaries of site generators.
+type Report struct {
+	// Blocks are the piped blocks and code spans, in document
+	// order.
+	Blocks []BlockReport
+
+	// Duration is the time spent running the pipes.  With Workers,
+	// it is less than the sum of the durations of the blocks.
+	Duration time.Duration
+}
+
+// BlockReport describes a single piped block or code span.
+type BlockReport struct {
+	Language   string
+	Line       int  // Line number of the block, starting at 1.
+	Inline     bool // Whether the block is a code span.
+	InputSize  int  // Size of the content in bytes.
+	OutputSize int  // Size of the output in bytes.
+	Duration   time.Duration
+	CacheHit   bool // Whether the output or error was remembered.
+	Duplicate  bool // Whether the output is that of an identical block.
+	Err        error
+}
+
+// Failed returns the number of failed blocks.
+func (r Report) Failed() int {
+	n := 0
+	for _, b := range r.Blocks {
+		if b.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// CacheHits returns the number of blocks whose output or error was
+// remembered.
+func (r Report) CacheHits() int {
+	n := 0
+	for _, b := range r.Blocks {
+		if b.CacheHit {
+			n++
+		}
+	}
+	return n
+}
+
+// String returns a summary such as "rendered 42 blocks in 3.1s (40
+// cached, 1 failed)".
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rendered %d blocks in %v", len(r.Blocks), r.Duration.Round(time.Millisecond))
+	var details []string
+	if n := r.CacheHits(); n > 0 {
+		details = append(details, fmt.Sprintf("%d cached", n))
+	}
+	if n := r.Failed(); n > 0 {
+		details = append(details, fmt.Sprintf("%d failed", n))
+	}
+	if len(details) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+	}
+	return b.String()
+}
+
+// reportKey is the parser.Context key for the Report of a
+// conversion.
+var reportKey = parser.NewContextKey()
+
+// addReport records the results of the given jobs, which ran for d,
+// in the Report of the conversion.
+func (e *Extension) addReport(pc parser.Context, jobs []*job, d time.Duration) {
+	r, _ := pc.Get(reportKey).(*Report)
+	if r == nil {
+		r = &Report{}
+		pc.Set(reportKey, r)
+	}
+	for _, j := range jobs {
+		r.Blocks = append(r.Blocks, BlockReport{
+			Language:   j.info.Language,
+			Line:       j.line,
+			Inline:     j.inline,
+			InputSize:  len(j.content),
+			OutputSize: len(j.output),
+			Duration:   j.duration,
+			CacheHit:   j.cache == cacheHit || j.cache == cacheFailure,
+			Duplicate:  j.cache == cacheDuplicate,
+			Err:        j.err,
+		})
+	}
+	r.Duration += d
+}
+
+// Report returns the Report of a conversion.  It is called after
+// the conversion, with its parser.Context:
+//
+//	pc := parser.NewContext()
+//	err := md.Convert(src, w, parser.WithContext(pc))
+//	log.Printf("%s: %v", path, ext.Report(pc))
+//
+// With WithPlaceholders, the pending blocks are not reported, as
+// they run later in RunPending.
+func (e *Extension) Report(pc parser.Context) Report {
+	r, _ := pc.Get(reportKey).(*Report)
+	if r == nil {
+		return Report{}
+	}
+	return *r
+}
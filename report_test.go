@@ -0,0 +1,106 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestReport(t *testing.T) {
+	upper := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		return bytes.ToUpper(src), nil
+	}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"upper": upper,
+			"fail": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				return nil, errors.New("fail")
+			},
+		},
+		InlinePipeFuncs: map[string]pipefence.PipeFuncCtx{"up": upper},
+		Cache:           &pipefence.MemoryCache{},
+		Deduplicate:     true,
+		ErrorMode:       pipefence.RenderErrorInline,
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+	src := []byte("```upper\nabc\n```\n\n```fail\nx\n```\n\n```upper\nabc\n```\n\n`up:de`\n")
+
+	if err := gmark.Convert(src, io.Discard); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	pc := parser.NewContext()
+	if err := gmark.Convert(src, io.Discard, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	report := ext.Report(pc)
+
+	for i := range report.Blocks {
+		report.Blocks[i].Duration = 0
+		if report.Blocks[i].Err != nil {
+			report.Blocks[i].Err = errors.Unwrap(report.Blocks[i].Err)
+		}
+	}
+	want := []pipefence.BlockReport{
+		{Language: "upper", Line: 1, InputSize: 4, OutputSize: 4, CacheHit: true},
+		{Language: "fail", Line: 5, InputSize: 2, Err: errors.New("fail")},
+		{Language: "upper", Line: 9, InputSize: 4, OutputSize: 4, Duplicate: true},
+		{Language: "up", Line: 13, Inline: true, InputSize: 2, OutputSize: 2, CacheHit: true},
+	}
+	if len(report.Blocks) != len(want) {
+		t.Fatalf("got %d blocks, want %d: %+v", len(report.Blocks), len(want), report.Blocks)
+	}
+	for i, got := range report.Blocks {
+		w := want[i]
+		if (got.Err == nil) != (w.Err == nil) || got.Err != nil && got.Err.Error() != w.Err.Error() {
+			t.Errorf("block %d: Err = %v, want %v", i, got.Err, w.Err)
+		}
+		got.Err, w.Err = nil, nil
+		if got != w {
+			t.Errorf("block %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if got, want := report.Failed(), 1; got != want {
+		t.Errorf("Failed() = %d, want %d", got, want)
+	}
+	if got, want := report.CacheHits(), 2; got != want {
+		t.Errorf("CacheHits() = %d, want %d", got, want)
+	}
+}
+
+func TestReportString(t *testing.T) {
+	for _, tt := range []struct {
+		Name   string
+		Report pipefence.Report
+		Want   string
+	}{
+		{
+			Name: "Empty",
+			Want: "rendered 0 blocks in 0s",
+		},
+		{
+			Name: "CachedAndFailed",
+			Report: pipefence.Report{
+				Blocks: []pipefence.BlockReport{
+					{Language: "dot", CacheHit: true},
+					{Language: "dot", Err: errors.New("fail")},
+					{Language: "dot"},
+				},
+				Duration: 3123456789 * time.Nanosecond,
+			},
+			Want: "rendered 3 blocks in 3.123s (1 cached, 1 failed)",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			if got := tt.Report.String(); got != tt.Want {
+				t.Errorf("String() = %q, want %q", got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package pipefence
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/yuin/goldmark/parser"
+)
+
+// requirementsKey is the context key for the requirements of the
+// running pipe.
+type requirementsKey struct{}
+
+// requirements collects the assets and dependencies declared by a
+// pipe.
+type requirements struct {
+	mu     sync.Mutex
+	assets []string
+	deps   []string
+}
+
+func (r *requirements) urls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.assets
+}
+
+func (r *requirements) dependencies() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.deps
+}
+
+// RequireAssets declares that the output of the running pipe needs
+// the given page-level assets, such as the URLs of scripts or
+// stylesheets.  They are reported by RequiredAssets.  Pipe
+// functions call it with the context they were passed; outside of
+// pipe functions it does nothing.  The assets are stored in the
+// Cache together with the output.
+func RequireAssets(ctx context.Context, urls ...string) {
+	r, ok := ctx.Value(requirementsKey{}).(*requirements)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.assets = append(r.assets, urls...)
+}
+
+// requiredKey is the parser.Context key for the assets required by
+// a conversion.
+var requiredKey = parser.NewContextKey()
+
+// addRequiredAssets records the assets required by the given jobs
+// in document order: those of Requires for their languages, those
+// of deferrals and those declared at run time.
+func (e *Extension) addRequiredAssets(pc parser.Context, jobs []*job) {
+	jobs = slices.Clone(jobs)
+	sort.SliceStable(jobs, func(i, k int) bool { return jobs[i].line < jobs[k].line })
+
+	assets, _ := pc.Get(requiredKey).([]string)
+	add := func(urls []string) {
+		for _, u := range urls {
+			if !slices.Contains(assets, u) {
+				assets = append(assets, u)
+			}
+		}
+	}
+	for _, j := range jobs {
+		add(e.Requires[j.info.Language])
+		add(j.assets)
+	}
+	if assets != nil {
+		pc.Set(requiredKey, assets)
+	}
+}
+
+// RequiredAssets returns the page-level assets needed by the blocks
+// of a conversion, in the order of first use, so that each page can
+// include exactly the scripts and stylesheets it needs.  It is called
+// after the conversion, with its parser.Context:
+//
+//	pc := parser.NewContext()
+//	err := md.Convert(src, w, parser.WithContext(pc))
+//	assets := ext.RequiredAssets(pc)
+//
+// The assets of blocks which failed are included, so that the page
+// does not depend on which blocks rendered.
+func (e *Extension) RequiredAssets(pc parser.Context) []string {
+	assets, _ := pc.Get(requiredKey).([]string)
+	return assets
+}
@@ -0,0 +1,114 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestRequiredAssets(t *testing.T) {
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"math": func(ctx context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				pipefence.RequireAssets(ctx, "katex.css")
+				return src, nil
+			},
+			"dot": func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				return src, nil
+			},
+		},
+		Requires: map[string][]string{
+			"dot":  {"graph.css"},
+			"math": {"math.css"},
+		},
+		Defer: map[string]pipefence.Deferral{
+			"mermaid": {Assets: []string{"mermaid.js", "graph.css"}},
+		},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "none",
+			input: "```go\nx\n```\n",
+			want:  nil,
+		},
+		{
+			name:  "static",
+			input: "```dot\nx\n```\n",
+			want:  []string{"graph.css"},
+		},
+		{
+			name:  "runtime",
+			input: "```math\nx\n```\n",
+			want:  []string{"math.css", "katex.css"},
+		},
+		{
+			name:  "document order",
+			input: "```mermaid\nx\n```\n\n```math\ny\n```\n\n```dot\nz\n```\n",
+			want:  []string{"mermaid.js", "graph.css", "math.css", "katex.css"},
+		},
+		{
+			name:  "duplicates",
+			input: "```math\nx\n```\n\n```math\nx\n```\n\n```math\ny\n```\n",
+			want:  []string{"math.css", "katex.css"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := parser.NewContext()
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.input), &buf, parser.WithContext(pc)); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := ext.RequiredAssets(pc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RequiredAssets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredAssetsCached(t *testing.T) {
+	calls := 0
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"math": func(ctx context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				calls++
+				pipefence.RequireAssets(ctx, "katex.css")
+				return src, nil
+			},
+		},
+		Cache: &pipefence.MemoryCache{},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for i := 0; i < 2; i++ {
+		pc := parser.NewContext()
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte("```math\nx\n```\n"), &buf, parser.WithContext(pc)); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if got := buf.String(); got != "x\n" {
+			t.Errorf("conversion %d: gmark.Convert() = %q, want %q", i, got, "x\n")
+		}
+		if got, want := ext.RequiredAssets(pc), []string{"katex.css"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("conversion %d: RequiredAssets() = %q, want %q", i, got, want)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("pipe function called %d times, want 1", calls)
+	}
+}
+
+func TestRequireAssetsOutsidePipe(t *testing.T) {
+	// Must not panic.
+	pipefence.RequireAssets(context.Background(), "x.js")
+}
@@ -0,0 +1,34 @@
+package pipefence
+
+import (
+	"context"
+	"time"
+)
+
+// Retry returns a middleware which retries failed pipe invocations
+// up to n times.  The first retry happens after backoff, and the
+// delay doubles with each further retry.  Failures caused by the
+// cancellation of the context are not retried.
+//
+// Example:
+//
+//	ext.Use(pipefence.Retry(3, 100*time.Millisecond))
+func Retry(n int, backoff time.Duration) Middleware {
+	return func(next PipeFuncCtx) PipeFuncCtx {
+		return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+			delay := backoff
+			for attempt := 0; ; attempt++ {
+				out, err := next(ctx, src, info)
+				if err == nil || attempt >= n || ctx.Err() != nil {
+					return out, err
+				}
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(delay):
+				}
+				delay *= 2
+			}
+		}
+	}
+}
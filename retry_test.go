@@ -0,0 +1,64 @@
+package pipefence_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+func TestRetry(t *testing.T) {
+	for _, tt := range []struct {
+		Name      string
+		Failures  int
+		Retries   int
+		WantCalls int
+		WantErr   bool
+	}{
+		{Name: "Success", Failures: 0, Retries: 3, WantCalls: 1},
+		{Name: "TransientFailure", Failures: 2, Retries: 3, WantCalls: 3},
+		{Name: "PermanentFailure", Failures: 10, Retries: 3, WantCalls: 4, WantErr: true},
+		{Name: "NoRetries", Failures: 1, Retries: 0, WantCalls: 1, WantErr: true},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			calls := 0
+			pipe := pipefence.Retry(tt.Retries, time.Millisecond)(func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				calls++
+				if calls <= tt.Failures {
+					return nil, errors.New("crashed")
+				}
+				return []byte("ok"), nil
+			})
+
+			out, err := pipe(context.Background(), nil, pipefence.Info{})
+			if (err != nil) != tt.WantErr {
+				t.Errorf("pipe: err = %v, want error: %v", err, tt.WantErr)
+			}
+			if err == nil && string(out) != "ok" {
+				t.Errorf("pipe: output = %q, want %q", out, "ok")
+			}
+			if calls != tt.WantCalls {
+				t.Errorf("pipe called %d times, want %d", calls, tt.WantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	pipe := pipefence.Retry(3, time.Hour)(func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+		calls++
+		cancel()
+		return nil, errors.New("crashed")
+	})
+
+	if _, err := pipe(ctx, nil, pipefence.Info{}); err == nil {
+		t.Errorf("pipe succeeded, want error")
+	}
+	if calls != 1 {
+		t.Errorf("pipe called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,45 @@
+package pipefence
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RLimits are resource limits for the processes of exec pipes.  They
+// are set with the ulimit builtin of /bin/sh before the command is
+// executed.  Zero values mean no limit.
+type RLimits struct {
+	// CPU is the CPU time limit, rounded up to whole seconds.
+	CPU time.Duration
+
+	// Memory is the limit of the virtual memory in bytes.
+	Memory int64
+
+	// FileSize is the limit of the size of files created, in
+	// bytes.
+	FileSize int64
+}
+
+// wrap returns the command line running argv with the limits.
+func (l RLimits) wrap(argv []string) []string {
+	var ulimits []string
+	if l.CPU > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -t %d", ceilDiv(int64(l.CPU), int64(time.Second))))
+	}
+	if l.Memory > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %d", ceilDiv(l.Memory, 1024)))
+	}
+	if l.FileSize > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -f %d", ceilDiv(l.FileSize, 512)))
+	}
+	if len(ulimits) == 0 {
+		return argv
+	}
+	script := strings.Join(ulimits, " && ") + ` && exec "$@"`
+	return append([]string{"/bin/sh", "-c", script, "sh"}, argv...)
+}
+
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,61 @@
+package pipefence
+
+import (
+	"context"
+	"regexp"
+)
+
+// Scrubber removes nondeterministic parts from pipe outputs, such as
+// timestamps, random IDs or version comments, so that rendered sites
+// are reproducible and diffs between builds stay clean.
+type Scrubber func(out []byte) []byte
+
+// Scrub returns a middleware which applies the scrubbers in order to
+// the outputs of pipes.  As it is a middleware, the outputs are
+// scrubbed before they are stored in the Cache.
+//
+// Example:
+//
+//	ext.Use(pipefence.Scrub(
+//		pipefence.ScrubXMLComments,
+//		pipefence.ScrubRegexp(regexp.MustCompile(`id="[0-9a-f]{32}"`), `id="x"`),
+//	))
+func Scrub(scrubbers ...Scrubber) Middleware {
+	return func(next PipeFuncCtx) PipeFuncCtx {
+		return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+			out, err := next(ctx, src, info)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range scrubbers {
+				out = s(out)
+			}
+			return out, nil
+		}
+	}
+}
+
+// ScrubRegexp returns a scrubber replacing the matches of re with
+// repl, which may refer to submatches as in Regexp.ReplaceAll.
+func ScrubRegexp(re *regexp.Regexp, repl string) Scrubber {
+	return func(out []byte) []byte {
+		return re.ReplaceAll(out, []byte(repl))
+	}
+}
+
+var (
+	xmlCommentRE = regexp.MustCompile(`(?s)<!--.*?-->\n?`)
+	plantUMLPIRE = regexp.MustCompile(`<\?plantuml [^?]*\?>`)
+)
+
+// ScrubXMLComments removes XML comments, such as those with the tool
+// version which Graphviz and PlantUML write into SVG outputs.
+func ScrubXMLComments(out []byte) []byte {
+	return xmlCommentRE.ReplaceAll(out, nil)
+}
+
+// ScrubPlantUML removes the version processing instruction and the
+// comments with checksums and sources from PlantUML SVG outputs.
+func ScrubPlantUML(out []byte) []byte {
+	return ScrubXMLComments(plantUMLPIRE.ReplaceAll(out, nil))
+}
@@ -0,0 +1,74 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestScrubbers(t *testing.T) {
+	for _, tt := range []struct {
+		Name     string
+		Scrubber pipefence.Scrubber
+		Input    string
+		Want     string
+	}{
+		{
+			Name:     "Regexp",
+			Scrubber: pipefence.ScrubRegexp(regexp.MustCompile(`date="[^"]*"`), `date=""`),
+			Input:    `<svg date="2024-01-02T03:04:05Z"/>`,
+			Want:     `<svg date=""/>`,
+		},
+		{
+			Name:     "RegexpSubmatch",
+			Scrubber: pipefence.ScrubRegexp(regexp.MustCompile(`(id|href)="#?[0-9a-f]{8}"`), `$1="x"`),
+			Input:    `<g id="deadbeef"/><use href="#deadbeef"/>`,
+			Want:     `<g id="x"/><use href="x"/>`,
+		},
+		{
+			Name:     "XMLComments",
+			Scrubber: pipefence.ScrubXMLComments,
+			Input:    "<!-- Generated by graphviz version 2.43.0\n -->\n<svg><!-- a --><g/></svg>",
+			Want:     "<svg><g/></svg>",
+		},
+		{
+			Name:     "PlantUML",
+			Scrubber: pipefence.ScrubPlantUML,
+			Input:    `<svg><?plantuml 1.2023.10?><!--MD5=[0123]--><g/><!--SRC=[abc]--></svg>`,
+			Want:     `<svg><g/></svg>`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			if got := string(tt.Scrubber([]byte(tt.Input))); got != tt.Want {
+				t.Errorf("got %q, want %q", got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestScrub(t *testing.T) {
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"dot": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				return []byte("<!-- version 1 -->\n<svg/>\n"), nil
+			},
+		},
+		Cache: &pipefence.MemoryCache{},
+	}
+	ext.Use(pipefence.Scrub(pipefence.ScrubXMLComments))
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte("```dot\nx\n```\n"), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if got, want := buf.String(), "<svg/>\n"; got != want {
+			t.Errorf("gmark.Convert() = %q, want %q", got, want)
+		}
+	}
+}
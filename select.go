@@ -0,0 +1,33 @@
+package pipefence
+
+import (
+	"context"
+	"errors"
+)
+
+// errNoSelection is returned by Select pipes whose choose function
+// returns nil.
+var errNoSelection = errors.New("no pipe for this content")
+
+// Select returns a pipe function which invokes the pipe function
+// chosen by choose for the block content.  This allows one language
+// to cover several tools, e.g. for legacy documents using ```uml for
+// both PlantUML and Mermaid diagrams:
+//
+//	ext.Register("uml", pipefence.Select(func(src []byte) pipefence.PipeFuncCtx {
+//		if bytes.HasPrefix(bytes.TrimSpace(src), []byte("@startuml")) {
+//			return plantuml
+//		}
+//		return mermaid
+//	}))
+//
+// If choose returns nil, the block fails.
+func Select(choose func(src []byte) PipeFuncCtx) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+		f := choose(src)
+		if f == nil {
+			return nil, errNoSelection
+		}
+		return f(ctx, src, info)
+	}
+}
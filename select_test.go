@@ -0,0 +1,64 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestSelect(t *testing.T) {
+	tagged := func(tag string) pipefence.PipeFuncCtx {
+		return func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+			return []byte(tag + ":" + string(src)), nil
+		}
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"uml": pipefence.Select(func(src []byte) pipefence.PipeFuncCtx {
+				switch {
+				case bytes.HasPrefix(src, []byte("@startuml")):
+					return tagged("plantuml")
+				case bytes.HasPrefix(src, []byte("sequenceDiagram")):
+					return tagged("mermaid")
+				}
+				return nil
+			}),
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "PlantUML",
+			Input: "```uml\n@startuml\n```\n",
+			Want:  "plantuml:@startuml\n",
+		},
+		{
+			Name:  "Mermaid",
+			Input: "```uml\nsequenceDiagram\n```\n",
+			Want:  "mermaid:sequenceDiagram\n",
+		},
+		{
+			Name:  "NoSelection",
+			Input: "```uml\ngraph\n```\n",
+			Want:  "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">fenced block transformer &quot;uml&quot;: no pipe for this content</pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package pipefence
+
+import (
+	"context"
+	"fmt"
+)
+
+// SizeLimit restricts the sizes of the contents and outputs of
+// blocks, so that a pathological diagram can neither feed huge
+// inputs into an external tool nor bloat the page.
+type SizeLimit struct {
+	// Input is the maximum size of the block content in bytes.
+	// Larger blocks fail without invoking the pipe.  Zero means no
+	// limit.
+	Input int
+
+	// Output is the maximum size of the pipe output in bytes.
+	// Zero means no limit.
+	Output int
+}
+
+// SizeError is returned for blocks whose content or output exceeds
+// their SizeLimit.
+type SizeError struct {
+	Language string // Language of the fenced code block.
+	Line     int    // Line of the opening code fence, starting at 1.
+	Output   bool   // Whether the output, rather than the content, is too large.
+	Size     int    // Size of the content or output in bytes.
+	Limit    int    // The limit which was exceeded.
+}
+
+func (e *SizeError) Error() string {
+	what := "content"
+	if e.Output {
+		what = "output"
+	}
+	return fmt.Sprintf("fenced block transformer %q at line %d: %s of %d bytes exceeds limit of %d bytes", e.Language, e.Line, what, e.Size, e.Limit)
+}
+
+// sizeLimit returns the size limit for the given language.
+func (e *Extension) sizeLimit(lang string) SizeLimit {
+	if l, ok := e.SizeLimits[lang]; ok {
+		return l
+	}
+	return e.SizeLimit
+}
+
+// limitOutput returns a pipe function which invokes f, but fails
+// with a *SizeError if the output of f exceeds max bytes.
+func limitOutput(f PipeFuncCtx, j *job, max int) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, info Info) ([]byte, error) {
+		out, err := f(ctx, src, info)
+		if err == nil && len(out) > max {
+			return nil, &SizeError{Language: j.info.Language, Line: j.line, Output: true, Size: len(out), Limit: max}
+		}
+		return out, err
+	}
+}
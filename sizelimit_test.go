@@ -0,0 +1,77 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestSizeLimit(t *testing.T) {
+	var called bool
+	ext := &pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"echo": func(a []byte) ([]byte, error) {
+				called = true
+				return a, nil
+			},
+			"double": func(a []byte) ([]byte, error) {
+				called = true
+				return bytes.Repeat(a, 2), nil
+			},
+		},
+		SizeLimit: pipefence.SizeLimit{Input: 8},
+		SizeLimits: map[string]pipefence.SizeLimit{
+			"double": {Input: 8, Output: 10},
+		},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		Name       string
+		Input      string
+		WantErr    *pipefence.SizeError
+		WantCalled bool
+	}{
+		{
+			Name:       "Small",
+			Input:      "```echo\nfoo\n```\n",
+			WantCalled: true,
+		},
+		{
+			Name:    "LargeInput",
+			Input:   "```echo\nfoo bar baz\n```\n",
+			WantErr: &pipefence.SizeError{Language: "echo", Line: 1, Size: 12, Limit: 8},
+		},
+		{
+			Name:       "LargeOutput",
+			Input:      "\n```double\nfoo bar\n```\n",
+			WantErr:    &pipefence.SizeError{Language: "double", Line: 2, Output: true, Size: 16, Limit: 10},
+			WantCalled: true,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			called = false
+			err := gmark.Convert([]byte(tt.Input), io.Discard)
+			if called != tt.WantCalled {
+				t.Errorf("pipe called = %v, want %v", called, tt.WantCalled)
+			}
+			if tt.WantErr == nil {
+				if err != nil {
+					t.Errorf("gmark.Convert: %v", err)
+				}
+				return
+			}
+			var serr *pipefence.SizeError
+			if !errors.As(err, &serr) {
+				t.Fatalf("gmark.Convert: err = %v, want *SizeError", err)
+			}
+			if *serr != *tt.WantErr {
+				t.Errorf("gmark.Convert: err = %+v, want %+v", *serr, *tt.WantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package pipefence
+
+import (
+	"github.com/yuin/goldmark/util"
+)
+
+// sourceSummary returns the summary text of the <details> element
+// showing the source of a block, or "" if the source is not shown.
+//
+// The showsource option of the block overrides Extension.ShowSource:
+// "false" hides the source, "true" or an empty value show it, and
+// any other value shows it with the value as the summary text.
+func (e *Extension) sourceSummary(info Info) string {
+	v, ok := info.Options["showsource"]
+	switch {
+	case !ok && !e.ShowSource, v == "false":
+		return ""
+	case v == "" || v == "true":
+		return "Source"
+	}
+	return v
+}
+
+// writeSourceDetails writes a collapsible <details> element showing
+// the original content of a block.
+func writeSourceDetails(w util.BufWriter, summary, lang string, content []byte) {
+	w.WriteString("<details class=\"pipefence-source\">\n<summary>")
+	w.Write(util.EscapeHTML([]byte(summary)))
+	w.WriteString("</summary>\n")
+	writeOriginalBlock(w, lang, content)
+	w.WriteString("</details>\n")
+}
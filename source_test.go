@@ -0,0 +1,65 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestShowSource(t *testing.T) {
+	for _, tt := range []struct {
+		Name       string
+		ShowSource bool
+		Input      string
+		Want       string
+	}{
+		{
+			Name:  "Off",
+			Input: "```upper\na<b\n```\n",
+			Want:  "A<B\n",
+		},
+		{
+			Name:       "Global",
+			ShowSource: true,
+			Input:      "```upper\na<b\n```\n",
+			Want: "A<B\n<details class=\"pipefence-source\">\n<summary>Source</summary>\n" +
+				"<pre><code class=\"language-upper\">a&lt;b\n</code></pre>\n</details>\n",
+		},
+		{
+			Name:       "DisabledPerBlock",
+			ShowSource: true,
+			Input:      "```upper showsource=false\na<b\n```\n",
+			Want:       "A<B\n",
+		},
+		{
+			Name:  "EnabledPerBlock",
+			Input: "```upper showsource\na<b\n```\n",
+			Want: "A<B\n<details class=\"pipefence-source\">\n<summary>Source</summary>\n" +
+				"<pre><code class=\"language-upper\">a&lt;b\n</code></pre>\n</details>\n",
+		},
+		{
+			Name:  "Summary",
+			Input: "```upper showsource=\"Show <code>\"\na<b\n```\n",
+			Want: "A<B\n<details class=\"pipefence-source\">\n<summary>Show &lt;code&gt;</summary>\n" +
+				"<pre><code class=\"language-upper\">a&lt;b\n</code></pre>\n</details>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+				PipeFuncs: map[string]pipefence.PipeFunc{
+					"upper": func(a []byte) ([]byte, error) { return bytes.ToUpper(a), nil },
+				},
+				ShowSource: tt.ShowSource,
+			}))
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package pipefence
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// Density is a rendering of the blocks of a language for a pixel
+// density, for Extension.Srcsets:
+//
+//	ext.Srcsets = map[string][]pipefence.Density{
+//		"gnuplot": {
+//			{Descriptor: "1x", Options: map[string]string{"size": "640,480"}},
+//			{Descriptor: "2x", Options: map[string]string{"size": "1280,960"}},
+//		},
+//	}
+type Density struct {
+	// Descriptor is the srcset descriptor, such as "1x" or "2x".
+	Descriptor string
+
+	// Options are added to the options of each block, so that the
+	// pipe renders for the density.
+	Options map[string]string
+}
+
+// runSrcset runs pipeFunc for j once per density, and returns an
+// <img> tag with a srcset referencing the outputs.
+func (e *Extension) runSrcset(ctx context.Context, j *job, pipeFunc PipeFuncCtx, densities []Density) ([]byte, error) {
+	lang := j.info.Language
+	urls := make([][]byte, len(densities))
+	for i, d := range densities {
+		out, info, err := e.runVariant(ctx, j, pipeFunc, d.Options)
+		if err != nil {
+			return nil, err
+		}
+		mimeType := e.outputType(lang, out)
+		if !isBinary(mimeType) {
+			if i == 0 {
+				// Vector graphics need no srcset.
+				return e.present(out, info, j.inline)
+			}
+			return nil, fmt.Errorf("fenced block transformer %q: output for %s is not a raster image", lang, d.Descriptor)
+		}
+		if e.AssetWriter == nil {
+			urls[i] = []byte("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(out))
+			continue
+		}
+		url, err := e.AssetWriter.WriteAsset(assetName(out), out)
+		if err != nil {
+			return nil, fmt.Errorf("fenced block transformer %q: writing asset: %w", lang, err)
+		}
+		urls[i] = util.URLEscape([]byte(url), false)
+	}
+	if e.RequireAlt && altText(j.info) == "" {
+		return nil, fmt.Errorf("fenced block transformer %q: %w", lang, ErrNoAltText)
+	}
+	out := srcsetTag(urls, densities, j.info)
+	if e.LinkAssets && e.AssetWriter != nil {
+		out = linkTag(urls[len(urls)-1], out)
+	}
+	return out, nil
+}
+
+// srcsetTag returns an <img> tag for the given source URLs, one per
+// density, with the alt text taken from the alt or caption option.
+func srcsetTag(urls [][]byte, densities []Density, info Info) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<img src="`)
+	buf.Write(util.EscapeHTML(urls[0]))
+	buf.WriteString(`" srcset="`)
+	for i, url := range urls {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.Write(util.EscapeHTML(url))
+		buf.WriteString(" ")
+		buf.Write(util.EscapeHTML([]byte(densities[i].Descriptor)))
+	}
+	buf.WriteString(`" alt="`)
+	buf.Write(util.EscapeHTML([]byte(altText(info))))
+	buf.WriteString("\">\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,61 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestSrcsets(t *testing.T) {
+	scaled := func(_ context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		return []byte("scale " + info.Options["scale"]), nil
+	}
+	uri := func(s string) string {
+		return "data:image/x-raw;base64," + base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	densities := []pipefence.Density{
+		{Descriptor: "1x", Options: map[string]string{"scale": "1"}},
+		{Descriptor: "2x", Options: map[string]string{"scale": "2"}},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"plot": scaled,
+			"svg": func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+				return []byte("<svg/>\n"), nil
+			},
+		},
+		OutputTypes: map[string]string{"plot": "image/x-raw"},
+		Srcsets:     map[string][]pipefence.Density{"plot": densities, "svg": densities},
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Raster",
+			Input: "```plot alt=Plot\n```\n",
+			Want:  `<img src="` + uri("scale 1") + `" srcset="` + uri("scale 1") + ` 1x, ` + uri("scale 2") + ` 2x" alt="Plot">` + "\n",
+		},
+		{
+			Name:  "Vector",
+			Input: "```svg\n```\n",
+			Want:  "<svg/>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
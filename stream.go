@@ -0,0 +1,65 @@
+package pipefence
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// StreamPipeFunc is a variant of PipeFunc which reads the contents
+// of a fenced code block from src and writes its output to dst.  It
+// suits transformations written against readers and writers, such
+// as base64 encoders or tools whose standard output is copied, which
+// need not build intermediate byte slices of their own.
+type StreamPipeFunc func(dst io.Writer, src io.Reader) error
+
+// Stream returns a pipe function for the stream pipe function f:
+//
+//	ext.Register("b64", pipefence.Stream(func(dst io.Writer, src io.Reader) error {
+//		enc := base64.NewEncoder(base64.StdEncoding, dst)
+//		if _, err := io.Copy(enc, src); err != nil {
+//			return err
+//		}
+//		return enc.Close()
+//	}))
+//
+// Once the context of the pipe is done, e.g. because the conversion
+// was cancelled or the block timed out, writes to dst fail with its
+// error, so that f stops early.
+//
+// Stream does not stream into the renderer's BufWriter.  Pipes run
+// in the AST transformer, concurrently and before anything is
+// rendered, and their outputs go through the Cache, middleware and
+// post-processing, so the output of f is collected in a buffer sized
+// for the content, like the outputs of other pipe functions.  If f
+// fails, the buffer is discarded.  Very large outputs are therefore
+// held in memory once per block.
+func Stream(f StreamPipeFunc) PipeFuncCtx {
+	return func(ctx context.Context, src []byte, _ Info) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		buf.Grow(len(src))
+		if err := f(ctxWriter{ctx: ctx, w: &buf}, bytes.NewReader(src)); err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// ctxWriter is a writer which fails once ctx is done.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
@@ -0,0 +1,83 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestStream(t *testing.T) {
+	ext := &pipefence.Extension{ErrorMode: pipefence.RenderErrorInline}
+	ext.Register("b64", pipefence.Stream(func(dst io.Writer, src io.Reader) error {
+		enc := base64.NewEncoder(base64.StdEncoding, dst)
+		if _, err := io.Copy(enc, src); err != nil {
+			return err
+		}
+		return enc.Close()
+	}))
+	ext.Register("fail", pipefence.Stream(func(dst io.Writer, _ io.Reader) error {
+		io.WriteString(dst, "partial")
+		return errors.New("broken pipe")
+	}))
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Encode",
+			Input: "```b64\nhello\n```\n",
+			Want:  "aGVsbG8K",
+		},
+		{
+			Name:  "Error",
+			Input: "```fail\nhello\n```\n",
+			Want:  "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">fenced block transformer &quot;fail&quot;: broken pipe</pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var writeErr error
+	ext := &pipefence.Extension{ErrorMode: pipefence.RenderErrorInline}
+	ext.Register("yes", pipefence.Stream(func(dst io.Writer, _ io.Reader) error {
+		cancel()
+		for {
+			if _, err := io.WriteString(dst, "y\n"); err != nil {
+				writeErr = err
+				return err
+			}
+		}
+	}))
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	pc := parser.NewContext()
+	pipefence.WithContext(pc, ctx)
+	if err := gmark.Convert([]byte("```yes\n```\n"), io.Discard, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if !errors.Is(writeErr, context.Canceled) {
+		t.Errorf("write error = %v, want %v", writeErr, context.Canceled)
+	}
+}
@@ -0,0 +1,58 @@
+package pipefence
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrNoPipe is the error of blocks in a language passed to Require
+// for which no pipe is registered.
+var ErrNoPipe = errors.New("no pipe registered for required language")
+
+// Require makes blocks in the given languages fail with ErrNoPipe,
+// according to the ErrorMode, if no pipe is registered for them,
+// instead of rendering as plain code blocks.  This catches
+// misconfigurations in CI, e.g. a site whose diagrams silently stop
+// rendering because a pipe was not set up.  The DefaultPipe does not
+// count as a registered pipe.  It is safe to call Require while
+// conversions are running.
+//
+// Languages are compared after resolving Aliases.
+func (e *Extension) Require(langs []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, lang := range langs {
+		if !slices.Contains(e.required, lang) {
+			e.required = append(e.required, lang)
+		}
+	}
+}
+
+// checkRequired returns an error if a stage of lang, which must be
+// canonical, is required but has no registered pipe for a block with
+// the raw info string info.
+func (e *Extension) checkRequired(lang string, info []byte) error {
+	e.mu.RLock()
+	required := e.required
+	e.mu.RUnlock()
+
+	if len(required) == 0 {
+		return nil
+	}
+	for _, stage := range strings.Split(lang, "|") {
+		stage = strings.TrimSpace(stage)
+		isRequired := slices.ContainsFunc(required, func(r string) bool {
+			return e.canonical(r) == stage
+		})
+		if !isRequired {
+			continue
+		}
+		if _, ok := e.lookupRegistered(stage, info); !ok {
+			return fmt.Errorf("fenced block transformer %q: %w", stage, ErrNoPipe)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestRequire(t *testing.T) {
+	identity := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		return src, nil
+	}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{"dot": identity},
+		DefaultPipe:  identity,
+		Aliases:      map[string]string{"graphviz": "dot", "mmd": "mermaid"},
+		Allow:        []string{"dot", "mermaid", "pikchr", "other"},
+	}
+	ext.Require([]string{"dot", "mmd", "pikchr"})
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		Name    string
+		Input   string
+		WantErr bool
+	}{
+		{Name: "Registered", Input: "```dot\nx\n```\n"},
+		{Name: "RegisteredAlias", Input: "```graphviz\nx\n```\n"},
+		{Name: "NotRequired", Input: "```other\nx\n```\n"},
+		{Name: "NotPermitted", Input: "```grpahviz\nx\n```\n"},
+		{Name: "Missing", Input: "```pikchr\nx\n```\n", WantErr: true},
+		{Name: "MissingAliased", Input: "```mermaid\nx\n```\n", WantErr: true},
+		{Name: "MissingStage", Input: "```dot|pikchr\nx\n```\n", WantErr: true},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := gmark.Convert([]byte(tt.Input), &buf)
+			if tt.WantErr {
+				if !errors.Is(err, pipefence.ErrNoPipe) {
+					t.Errorf("gmark.Convert() = %v, want ErrNoPipe", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("gmark.Convert(): %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package pipefence
+
+import "regexp"
+
+var svgRootRE = regexp.MustCompile(`(?s)<svg\b[^>]*>`)
+
+// SVGRoot returns the location of the start tag of the first <svg>
+// element of out, which is taken to be the root element of an SVG
+// output, as a pair of indices like regexp.Regexp.FindIndex.  It
+// returns nil if out contains no <svg> element.  The SVG rewriting of
+// pipefence and of subpackages such as svgpost use it, so that they
+// agree on which outputs are SVGs.
+func SVGRoot(out []byte) []int {
+	return svgRootRE.FindIndex(out)
+}
@@ -0,0 +1,160 @@
+// Package svgpost provides filters for post-processing SVG outputs
+// of pipes before they are inlined into HTML.
+//
+// Filters can be applied to all pipes of an Extension as middleware:
+//
+//	ext.Use(svgpost.Middleware(
+//		svgpost.StripProlog(),
+//		svgpost.NamespaceIDs(""),
+//		svgpost.Responsive(),
+//	))
+//
+// Outputs without an <svg> element are left unchanged.
+package svgpost
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"regexp"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Filter transforms an SVG document.
+type Filter func(svg []byte) []byte
+
+// Chain returns a filter applying the given filters in order.
+func Chain(filters ...Filter) Filter {
+	return func(svg []byte) []byte {
+		for _, f := range filters {
+			svg = f(svg)
+		}
+		return svg
+	}
+}
+
+// Middleware returns a pipefence middleware which applies the
+// filters to the outputs of pipes which contain an <svg> element.
+func Middleware(filters ...Filter) pipefence.Middleware {
+	filter := Chain(filters...)
+	return func(next pipefence.PipeFuncCtx) pipefence.PipeFuncCtx {
+		return func(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+			out, err := next(ctx, src, info)
+			if err != nil || pipefence.SVGRoot(out) == nil {
+				return out, err
+			}
+			return filter(out), nil
+		}
+	}
+}
+
+var (
+	prologRE  = regexp.MustCompile(`(?s)^\s*(<\?xml.*?\?>|<!DOCTYPE.*?>|<!--.*?-->|\s+)*`)
+	viewBoxRE = regexp.MustCompile(`\sviewBox="`)
+	idRE      = regexp.MustCompile(`\sid="([^"]*)"`)
+	refRE     = regexp.MustCompile(`(url\(#|href="#)([^)"]*)`)
+)
+
+// StripProlog removes the XML declaration, doctype and comments
+// preceding the <svg> root element, which are not valid inside
+// HTML.
+func StripProlog() Filter {
+	return func(svg []byte) []byte {
+		return prologRE.ReplaceAll(svg, nil)
+	}
+}
+
+// NamespaceIDs prefixes all element IDs, and the references to them,
+// so that multiple SVGs can be inlined into one page without their
+// IDs colliding.  If prefix is empty, a prefix derived from the SVG
+// content is used.
+func NamespaceIDs(prefix string) Filter {
+	return func(svg []byte) []byte {
+		p := prefix
+		if p == "" {
+			sum := sha256.Sum256(svg)
+			p = "svg" + hex.EncodeToString(sum[:4])
+		}
+		p += "-"
+
+		ids := make(map[string]bool)
+		for _, m := range idRE.FindAllSubmatch(svg, -1) {
+			ids[string(m[1])] = true
+		}
+		if len(ids) == 0 {
+			return svg
+		}
+		svg = idRE.ReplaceAll(svg, []byte(` id="`+p+`$1"`))
+		return refRE.ReplaceAllFunc(svg, func(ref []byte) []byte {
+			m := refRE.FindSubmatch(ref)
+			if !ids[string(m[2])] {
+				return ref
+			}
+			return append(append(append([]byte(nil), m[1]...), p...), m[2]...)
+		})
+	}
+}
+
+// SetClass sets the class attribute of the <svg> root element.
+func SetClass(class string) Filter {
+	return func(svg []byte) []byte {
+		return editRoot(svg, func(tag []byte) []byte {
+			return setAttr(tag, "class", class)
+		})
+	}
+}
+
+// SetSize sets the width and height attributes of the <svg> root
+// element.  Empty values remove the attribute.
+func SetSize(width, height string) Filter {
+	return func(svg []byte) []byte {
+		return editRoot(svg, func(tag []byte) []byte {
+			return setAttr(setAttr(tag, "width", width), "height", height)
+		})
+	}
+}
+
+// Responsive removes the fixed width and height from the <svg> root
+// element, so that the image scales with its container.  SVGs
+// without a viewBox are left unchanged, as they would not scale.
+func Responsive() Filter {
+	return func(svg []byte) []byte {
+		return editRoot(svg, func(tag []byte) []byte {
+			if !viewBoxRE.Match(tag) {
+				return tag
+			}
+			tag = setAttr(setAttr(tag, "width", ""), "height", "")
+			return setAttr(tag, "style", "max-width: 100%; height: auto")
+		})
+	}
+}
+
+// editRoot replaces the <svg> root element start tag with the result
+// of f.
+func editRoot(svg []byte, f func(tag []byte) []byte) []byte {
+	loc := pipefence.SVGRoot(svg)
+	if loc == nil {
+		return svg
+	}
+	var buf bytes.Buffer
+	buf.Write(svg[:loc[0]])
+	buf.Write(f(svg[loc[0]:loc[1]]))
+	buf.Write(svg[loc[1]:])
+	return buf.Bytes()
+}
+
+// setAttr sets the attribute name of the start tag to value, or
+// removes it if value is empty.
+func setAttr(tag []byte, name, value string) []byte {
+	attrRE := regexp.MustCompile(`\s` + regexp.QuoteMeta(name) + `="[^"]*"`)
+	tag = attrRE.ReplaceAll(tag, nil)
+	if value == "" {
+		return tag
+	}
+	attr := " " + name + `="` + html.EscapeString(value) + `"`
+	i := len("<svg")
+	return append(tag[:i:i], append([]byte(attr), tag[i:]...)...)
+}
@@ -0,0 +1,95 @@
+package svgpost_test
+
+import (
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/svgpost"
+)
+
+func TestFilters(t *testing.T) {
+	for _, tt := range []struct {
+		Name   string
+		Filter svgpost.Filter
+		Input  string
+		Want   string
+	}{
+		{
+			Name:   "StripProlog",
+			Filter: svgpost.StripProlog(),
+			Input:  "<?xml version=\"1.0\"?>\n<!DOCTYPE svg>\n<!-- x -->\n<svg></svg>",
+			Want:   "<svg></svg>",
+		},
+		{
+			Name:   "NamespaceIDs",
+			Filter: svgpost.NamespaceIDs("a"),
+			Input:  `<svg><defs><marker id="m"/></defs><path marker-end="url(#m)"/><use href="#m"/><use xlink:href="#m"/><a href="#elsewhere"/></svg>`,
+			Want:   `<svg><defs><marker id="a-m"/></defs><path marker-end="url(#a-m)"/><use href="#a-m"/><use xlink:href="#a-m"/><a href="#elsewhere"/></svg>`,
+		},
+		{
+			Name:   "NamespaceIDsDerived",
+			Filter: svgpost.NamespaceIDs(""),
+			Input:  `<svg><g id="x"/></svg>`,
+			Want:   `<svg><g id="svgbbb25f9e-x"/></svg>`,
+		},
+		{
+			Name:   "SetClass",
+			Filter: svgpost.SetClass("diagram"),
+			Input:  `<svg class="old" width="1"><g class="inner"/></svg>`,
+			Want:   `<svg class="diagram" width="1"><g class="inner"/></svg>`,
+		},
+		{
+			Name:   "SetSize",
+			Filter: svgpost.SetSize("100%", ""),
+			Input:  "<svg width=\"62pt\"\n height=\"116pt\"><rect width=\"5\"/></svg>",
+			Want:   "<svg width=\"100%\"\n><rect width=\"5\"/></svg>",
+		},
+		{
+			Name:   "Responsive",
+			Filter: svgpost.Responsive(),
+			Input:  `<svg width="62pt" height="116pt" viewBox="0 0 62 116"></svg>`,
+			Want:   `<svg style="max-width: 100%; height: auto" viewBox="0 0 62 116"></svg>`,
+		},
+		{
+			Name:   "ResponsiveWithoutViewBox",
+			Filter: svgpost.Responsive(),
+			Input:  `<svg width="62pt" height="116pt"></svg>`,
+			Want:   `<svg width="62pt" height="116pt"></svg>`,
+		},
+		{
+			Name:   "Chain",
+			Filter: svgpost.Chain(svgpost.StripProlog(), svgpost.SetClass("x")),
+			Input:  "<?xml version=\"1.0\"?>\n<svg></svg>",
+			Want:   `<svg class="x"></svg>`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			if got := string(tt.Filter([]byte(tt.Input))); got != tt.Want {
+				t.Errorf("filter(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	mw := svgpost.Middleware(svgpost.SetClass("x"))
+	for _, tt := range []struct {
+		Output string
+		Want   string
+	}{
+		{Output: "<svg></svg>", Want: `<svg class="x"></svg>`},
+		{Output: "<p>no svg</p>", Want: "<p>no svg</p>"},
+	} {
+		pipe := mw(func(context.Context, []byte, pipefence.Info) ([]byte, error) {
+			return []byte(tt.Output), nil
+		})
+		got, err := pipe(context.Background(), nil, pipefence.Info{})
+		if err != nil {
+			t.Fatalf("pipe: %v", err)
+		}
+		if string(got) != tt.Want {
+			t.Errorf("pipe output %q: got %q, want %q", tt.Output, got, tt.Want)
+		}
+	}
+}
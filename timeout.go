@@ -0,0 +1,59 @@
+package pipefence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutError is returned when a pipe function did not finish
+// within its configured timeout.
+type TimeoutError struct {
+	Language string        // Language of the fenced code block.
+	Line     int           // Line of the opening code fence, starting at 1.
+	Timeout  time.Duration // The timeout which was exceeded.
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("fenced block transformer %q at line %d: timed out after %v", e.Language, e.Line, e.Timeout)
+}
+
+// timeout returns the timeout for the given language, or zero.
+func (e *Extension) timeout(lang string) time.Duration {
+	if d, ok := e.Timeouts[lang]; ok {
+		return d
+	}
+	return e.Timeout
+}
+
+// runWithTimeout invokes f, but returns early with a *TimeoutError
+// once d has passed.
+//
+// Pipe functions which ignore the context keep running in the
+// background until they return, but their result is discarded.
+func runWithTimeout(parent context.Context, d time.Duration, j *job, f func(context.Context) ([]byte, error)) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(parent, d)
+	defer cancel()
+
+	type result struct {
+		out []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := f(ctx)
+		done <- result{out, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+	if res.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+		return nil, &TimeoutError{Language: j.info.Language, Line: j.line, Timeout: d}
+	}
+	return res.out, res.err
+}
@@ -0,0 +1,44 @@
+package pipefence_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncs: map[string]pipefence.PipeFunc{
+			"fast": func(a []byte) ([]byte, error) {
+				return a, nil
+			},
+			"hang": func(a []byte) ([]byte, error) {
+				// Ignores the context on purpose.
+				<-release
+				return a, nil
+			},
+		},
+		Timeout: time.Minute,
+		Timeouts: map[string]time.Duration{
+			"hang": 10 * time.Millisecond,
+		},
+	}))
+
+	input := "```fast\nfoo\n```\n\n```hang\nfoo\n```\n"
+	err := gmark.Convert([]byte(input), io.Discard)
+
+	var terr *pipefence.TimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatalf("gmark.Convert: err = %v, want *TimeoutError", err)
+	}
+	want := pipefence.TimeoutError{Language: "hang", Line: 5, Timeout: 10 * time.Millisecond}
+	if *terr != want {
+		t.Errorf("gmark.Convert: err = %+v, want %+v", *terr, want)
+	}
+}
@@ -0,0 +1,138 @@
+package pipefence
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tool describes the external tool used by the pipe of a language,
+// so that ValidateTools can verify that it is installed.  Pipes
+// registered with RegisterExec and RegisterExecWith need a Tool only
+// to set VersionArgs or Soft.
+type Tool struct {
+	// Command is the name or path of the tool's binary.
+	Command string
+
+	// VersionArgs are the arguments making the tool print its
+	// version.  If nil, "--version" is used.
+	VersionArgs []string
+
+	// Soft makes blocks in the language render as plain code
+	// blocks, with a warning in the log, if the binary can not be
+	// found, instead of failing.
+	Soft bool
+}
+
+// ToolStatus is the result of validating a Tool.
+type ToolStatus struct {
+	Language string
+	Command  string
+	Path     string // Path of the binary, if found.
+	Version  string // First line of the version output.
+	Soft     bool
+	Err      error // Why the tool is unusable, or nil.
+}
+
+func (s ToolStatus) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("%s: %v", s.Language, s.Err)
+	}
+	return fmt.Sprintf("%s: %s (%s)", s.Language, s.Path, s.Version)
+}
+
+// toolVersionTimeout limits the run time of version commands.
+const toolVersionTimeout = 10 * time.Second
+
+// ValidateTools verifies that the binaries of all Tools, and of the
+// pipes registered with RegisterExec and RegisterExecWith, exist and
+// run with their VersionArgs.  For pipes running in a Container, the
+// container runtime is verified.  It returns the results ordered by
+// language.  This is meant as a pre-flight check, e.g. when a site
+// generator starts up.
+//
+// Other pipes, including exec pipes set in PipeFuncsCtx directly,
+// are only validated if they have an entry in Tools.
+func (e *Extension) ValidateTools(ctx context.Context) []ToolStatus {
+	e.mu.RLock()
+	tools := maps.Clone(e.execTools)
+	e.mu.RUnlock()
+	if tools == nil {
+		tools = make(map[string]Tool)
+	}
+	maps.Copy(tools, e.Tools)
+
+	langs := make([]string, 0, len(tools))
+	for lang := range tools {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	statuses := make([]ToolStatus, len(langs))
+	for i, lang := range langs {
+		statuses[i] = validateTool(ctx, lang, tools[lang])
+	}
+	return statuses
+}
+
+func validateTool(ctx context.Context, lang string, tool Tool) ToolStatus {
+	status := ToolStatus{Language: lang, Command: tool.Command, Soft: tool.Soft}
+	path, err := exec.LookPath(tool.Command)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	status.Path = path
+
+	args := tool.VersionArgs
+	if args == nil {
+		args = []string{"--version"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, toolVersionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		status.Err = fmt.Errorf("%s %s: %w", tool.Command, strings.Join(args, " "), err)
+		return status
+	}
+	line, _, _ := bytes.Cut(bytes.TrimSpace(out), []byte("\n"))
+	status.Version = string(bytes.TrimSpace(line))
+	return status
+}
+
+// missingSoftTool returns the command of a soft Tool for a stage of
+// lang whose binary can not be found, or "".  Lookups are remembered
+// for the lifetime of the Extension.
+func (e *Extension) missingSoftTool(lang string) string {
+	if len(e.Tools) == 0 {
+		return ""
+	}
+	for _, stage := range strings.Split(lang, "|") {
+		tool, ok := e.Tools[strings.TrimSpace(stage)]
+		if !ok || !tool.Soft {
+			continue
+		}
+
+		e.toolsMu.Lock()
+		found, ok := e.toolsFound[tool.Command]
+		if !ok {
+			_, err := exec.LookPath(tool.Command)
+			found = err == nil
+			if e.toolsFound == nil {
+				e.toolsFound = make(map[string]bool)
+			}
+			e.toolsFound[tool.Command] = found
+		}
+		e.toolsMu.Unlock()
+
+		if !found {
+			return tool.Command
+		}
+	}
+	return ""
+}
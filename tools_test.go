@@ -0,0 +1,129 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestValidateTools(t *testing.T) {
+	ext := &pipefence.Extension{
+		Tools: map[string]pipefence.Tool{
+			"ok":      {Command: "sh", VersionArgs: []string{"-c", "echo '  tool v1.2  '; echo more"}},
+			"missing": {Command: "pipefence-no-such-tool", Soft: true},
+			"broken":  {Command: "sh", VersionArgs: []string{"-c", "exit 2"}},
+		},
+	}
+	statuses := ext.ValidateTools(context.Background())
+	var got []string
+	for _, s := range statuses {
+		got = append(got, s.Language)
+	}
+	if want := "broken missing ok"; strings.Join(got, " ") != want {
+		t.Fatalf("ValidateTools() languages = %q, want %q", got, want)
+	}
+
+	if s := statuses[0]; s.Err == nil || !strings.Contains(s.Err.Error(), "exit status 2") {
+		t.Errorf("broken tool: Err = %v, want exit status 2", s.Err)
+	}
+	if s := statuses[1]; s.Err == nil || s.Path != "" || !s.Soft {
+		t.Errorf("missing tool: %+v, want error", s)
+	}
+	if s := statuses[2]; s.Err != nil || s.Version != "tool v1.2" || s.Path == "" {
+		t.Errorf("ok tool: %+v, want version %q", s, "tool v1.2")
+	}
+}
+
+func TestValidateToolsExecPipes(t *testing.T) {
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"unregistered": pipefence.ExecPipe("pipefence-no-such-tool"),
+			"native": func(ctx context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+				t.Errorf("ValidateTools called the pipe function")
+				return src, nil
+			},
+		},
+		Tools: map[string]pipefence.Tool{
+			"shell": {Command: "sh", VersionArgs: []string{"-c", "echo sh v1"}},
+		},
+	}
+	ext.RegisterExec("shell", "sh", "-c", "cat")
+	ext.RegisterExecWith("boxed", pipefence.ExecOptions{
+		Container: &pipefence.Container{Runtime: "pipefence-no-such-runtime", Image: "img"},
+	}, "dot")
+	ext.RegisterExec("gone", "pipefence-no-such-tool")
+	ext.Unregister("gone")
+
+	statuses := ext.ValidateTools(context.Background())
+	var got []string
+	for _, s := range statuses {
+		got = append(got, s.Language+"="+s.Command)
+	}
+	if want := "boxed=pipefence-no-such-runtime shell=sh"; strings.Join(got, " ") != want {
+		t.Fatalf("ValidateTools() = %q, want %q", got, want)
+	}
+	if s := statuses[0]; s.Err == nil {
+		t.Errorf("missing runtime: %+v, want error", s)
+	}
+	if s := statuses[1]; s.Err != nil || s.Version != "sh v1" {
+		t.Errorf("declared tool: %+v, want version %q", s, "sh v1")
+	}
+}
+
+func TestSoftTools(t *testing.T) {
+	var logs bytes.Buffer
+	called := false
+	pipe := func(_ context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+		called = true
+		return src, nil
+	}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{"missing": pipe, "present": pipe},
+		Tools: map[string]pipefence.Tool{
+			"missing": {Command: "pipefence-no-such-tool", Soft: true},
+			"present": {Command: "sh", Soft: true},
+		},
+		Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	for _, tt := range []struct {
+		input  string
+		want   string
+		called bool
+	}{
+		{
+			input: "```missing\nx\n```\n",
+			want:  "<pre><code class=\"language-missing\">x\n</code></pre>\n",
+		},
+		{
+			input: "```present|missing\nx\n```\n",
+			want:  "<pre><code class=\"language-present|missing\">x\n</code></pre>\n",
+		},
+		{
+			input:  "```present\nx\n```\n",
+			want:   "x\n",
+			called: true,
+		},
+	} {
+		called = false
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(tt.input), &buf); err != nil {
+			t.Fatalf("gmark.Convert(%q): %v", tt.input, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("gmark.Convert(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		if called != tt.called {
+			t.Errorf("gmark.Convert(%q): pipe called = %v, want %v", tt.input, called, tt.called)
+		}
+	}
+	if !strings.Contains(logs.String(), "tool=pipefence-no-such-tool") {
+		t.Errorf("log %q does not mention the missing tool", logs.String())
+	}
+}
@@ -0,0 +1,109 @@
+// Package typst provides a pipefence pipe rendering Typst markup,
+// such as typeset figures and equations, to SVG or PNG.
+//
+// The pipe runs the typst CLI, which must be installed separately.
+// The page is sized to fit its content, so that blocks render as
+// figures rather than as full pages.  Only the first page of a
+// document is rendered.
+//
+// Example:
+//
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"typst": typst.Pipe(typst.Options{}),
+//		},
+//	}
+//
+// The format and resolution can be overridden per block:
+//
+//	```typst format=png ppi=288
+//	$ sum_(k=1)^n k = (n(n+1)) / 2 $
+//	```
+//
+// PNG outputs are embedded as data: URIs, or written with the
+// Extension's AssetWriter.
+package typst
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// DefaultPreamble is the preamble used if Options.Preamble is
+// empty.  It sizes the page to fit its content.
+const DefaultPreamble = "#set page(width: auto, height: auto, margin: 4pt)\n"
+
+// Options configure the rendering of Typst documents.
+type Options struct {
+	// Command is the typst binary.  If empty, "typst" is used.
+	Command string
+
+	// Format is the output format, "svg" or "png".  If empty,
+	// "svg" is used.  It can be overridden with the format option
+	// of a block.
+	Format string
+
+	// PPI is the resolution of PNG outputs in pixels per inch.  If
+	// zero, the typst default is used.  It can be overridden with
+	// the ppi option of a block.
+	PPI int
+
+	// Preamble is prepended to every block, e.g. to set fonts or
+	// import packages.  If empty, DefaultPreamble is used.
+	Preamble string
+}
+
+// Pipe returns a pipe function which renders Typst markup with the
+// typst CLI.
+func Pipe(opts Options) pipefence.PipeFuncCtx {
+	cmd := opts.Command
+	if cmd == "" {
+		cmd = "typst"
+	}
+	preamble := opts.Preamble
+	if preamble == "" {
+		preamble = DefaultPreamble
+	}
+	return func(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+		o, err := blockOptions(opts, info)
+		if err != nil {
+			return nil, err
+		}
+		args := []string{"compile", "--format", o.Format}
+		if o.Format == "png" && o.PPI != 0 {
+			args = append(args, "--ppi", strconv.Itoa(o.PPI))
+		}
+		// Read the document from stdin and write the first page to
+		// stdout.
+		args = append(args, "--pages", "1", "-", "-")
+
+		doc := append([]byte(preamble), src...)
+		return pipefence.ExecPipe(cmd, args...)(ctx, doc, info)
+	}
+}
+
+// blockOptions returns opts with the overrides from the options of
+// the block.
+func blockOptions(opts Options, info pipefence.Info) (Options, error) {
+	if v, ok := info.Options["format"]; ok {
+		opts.Format = v
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = "svg"
+	case "svg", "png":
+	default:
+		return opts, fmt.Errorf("unsupported format %q", opts.Format)
+	}
+	if v, ok := info.Options["ppi"]; ok {
+		ppi, err := strconv.Atoi(v)
+		if err != nil || ppi <= 0 {
+			return opts, fmt.Errorf("invalid ppi %q", v)
+		}
+		opts.PPI = ppi
+	}
+	return opts, nil
+}
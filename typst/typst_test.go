@@ -0,0 +1,81 @@
+package typst_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/typst"
+	"github.com/yuin/goldmark"
+)
+
+// fakeTypst returns a command which outputs an SVG with its
+// arguments and its input as text.
+func fakeTypst(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "typst")
+	script := "#!/bin/sh\necho \"<svg><text>$*</text><text>$(cat)</text></svg>\"\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestPipe(t *testing.T) {
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"typst":  typst.Pipe(typst.Options{Command: fakeTypst(t)}),
+			"custom": typst.Pipe(typst.Options{Command: fakeTypst(t), PPI: 300, Preamble: "#set text(size: 8pt)\n"}),
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Name  string
+		Input string
+		Want  string
+	}{
+		{
+			Name:  "Default",
+			Input: "```typst\n$x^2$\n```\n",
+			Want: "<svg><text>compile --format svg --pages 1 - -</text>" +
+				"<text>#set page(width: auto, height: auto, margin: 4pt)\n$x^2$</text></svg>\n",
+		},
+		{
+			Name:  "Options",
+			Input: "```custom format=png\n$x$\n```\n",
+			Want: "<svg><text>compile --format png --ppi 300 --pages 1 - -</text>" +
+				"<text>#set text(size: 8pt)\n$x$</text></svg>\n",
+		},
+		{
+			Name:  "PPI",
+			Input: "```typst format=png ppi=144\n$x$\n```\n",
+			Want: "<svg><text>compile --format png --ppi 144 --pages 1 - -</text>" +
+				"<text>#set page(width: auto, height: auto, margin: 4pt)\n$x$</text></svg>\n",
+		},
+		{
+			Name:  "InvalidFormat",
+			Input: "```typst format=pdf\n$x$\n```\n",
+			Want: "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">" +
+				"fenced block transformer &quot;typst&quot;: unsupported format &quot;pdf&quot;</pre>\n",
+		},
+		{
+			Name:  "InvalidPPI",
+			Input: "```typst ppi=high\n$x$\n```\n",
+			Want: "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">" +
+				"fenced block transformer &quot;typst&quot;: invalid ppi &quot;high&quot;</pre>\n",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Package vega provides a pipefence pipe for Vega-Lite charts.
+//
+// Charts are either rendered to SVG on the server, with the
+// vl-convert CLI or a remote rendering service, or embedded with
+// vega-embed in the browser.
+//
+// Example:
+//
+//	v := &vega.Vega{Mode: vega.ClientSide}
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"vega-lite": v.Pipe,
+//		},
+//	}
+//	// ... convert, then include ext.RequiredAssets(pc) as <script> tags.
+//
+// The mode can be overridden per block with the mode option, which
+// is "server" or "client":
+//
+//	```vega-lite mode=client
+//	{"mark": "bar", "data": {"values": [{"a": 1}]}, ...}
+//	```
+package vega
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Mode selects where charts are rendered.
+type Mode int
+
+const (
+	// ServerSide renders charts to SVG using vl-convert, or the
+	// service at Vega.URL.
+	ServerSide Mode = iota
+
+	// ClientSide emits a container and a script embedding the
+	// chart with vega-embed.
+	ClientSide
+)
+
+// DefaultScriptURLs are the scripts returned by Assets in
+// ClientSide mode if no ScriptURLs are set.
+var DefaultScriptURLs = []string{
+	"https://cdn.jsdelivr.net/npm/vega@5",
+	"https://cdn.jsdelivr.net/npm/vega-lite@5",
+	"https://cdn.jsdelivr.net/npm/vega-embed@6",
+}
+
+// Vega renders Vega-Lite charts.
+type Vega struct {
+	// Mode selects server-side or client-side rendering.
+	Mode Mode
+
+	// Command is the vl-convert binary used in ServerSide mode.
+	// If empty, "vl-convert" is used.
+	Command string
+
+	// URL, if set, is the URL of a rendering service used in
+	// ServerSide mode instead of Command.  The spec is POSTed to it
+	// with pipefence.HTTPPipe, and the response is the SVG.
+	URL string
+
+	// HTTPOptions configure the requests to URL.
+	HTTPOptions pipefence.HTTPOptions
+
+	// ScriptURLs are the scripts returned by Assets.  If nil,
+	// DefaultScriptURLs are used.
+	ScriptURLs []string
+}
+
+// Pipe is a pipefence.PipeFuncCtx rendering a Vega-Lite chart.
+func (v *Vega) Pipe(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	if !json.Valid(src) {
+		return nil, fmt.Errorf("invalid Vega-Lite spec: not valid JSON")
+	}
+	mode := v.Mode
+	switch m := info.Options["mode"]; m {
+	case "":
+	case "server":
+		mode = ServerSide
+	case "client":
+		mode = ClientSide
+	default:
+		return nil, fmt.Errorf("invalid mode %q", m)
+	}
+
+	if mode == ClientSide {
+		pipefence.RequireAssets(ctx, v.scripts()...)
+		return embed(src, info)
+	}
+	if v.URL != "" {
+		opts := v.HTTPOptions
+		if opts.ContentType == "" {
+			opts.ContentType = "application/json"
+		}
+		return pipefence.HTTPPipe(v.URL, opts)(ctx, src, info)
+	}
+	cmd := v.Command
+	if cmd == "" {
+		cmd = "vl-convert"
+	}
+	return pipefence.ExecPipe(cmd, "vl2svg", "--input", "/dev/stdin", "--output", "/dev/stdout")(ctx, src, info)
+}
+
+// embed returns a container with a script embedding the chart.
+func embed(spec []byte, info pipefence.Info) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, spec); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(spec)
+	id := fmt.Sprintf("vega-%s-%d", hex.EncodeToString(sum[:4]), info.Line)
+
+	// The spec must not end the script element early.
+	js := bytes.ReplaceAll(compact.Bytes(), []byte("</"), []byte(`<\/`))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<div class=\"vega-lite\" id=\"%s\"></div>\n", id)
+	fmt.Fprintf(&buf, "<script>vegaEmbed(\"#%s\", %s);</script>\n", id, js)
+	return buf.Bytes(), nil
+}
+
+// Assets returns the URLs of the JavaScript files which need to be
+// included on pages with charts.  In ServerSide mode, no scripts are
+// needed, unless blocks select client-side rendering.  Pipe declares
+// the scripts with pipefence.RequireAssets for each block rendered on
+// the client, so that Extension.RequiredAssets reports them for the
+// pages which need them.
+func (v *Vega) Assets() []string {
+	if v.Mode != ClientSide {
+		return nil
+	}
+	return v.scripts()
+}
+
+// scripts returns the client-side rendering scripts.
+func (v *Vega) scripts() []string {
+	if v.ScriptURLs != nil {
+		return v.ScriptURLs
+	}
+	return DefaultScriptURLs
+}
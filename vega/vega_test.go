@@ -0,0 +1,101 @@
+package vega_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/vega"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestClientSide(t *testing.T) {
+	v := &vega.Vega{Mode: vega.ClientSide}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"vega-lite": v.Pipe,
+		},
+	}))
+
+	var buf bytes.Buffer
+	input := "```vega-lite\n{\"mark\": \"bar\",\n \"title\": \"</script>\"}\n```\n"
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	want := "<div class=\"vega-lite\" id=\"vega-2d5f4e12-1\"></div>\n" +
+		"<script>vegaEmbed(\"#vega-2d5f4e12-1\", {\"mark\":\"bar\",\"title\":\"<\\/script>\"});</script>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+
+	if got, want := v.Assets(), vega.DefaultScriptURLs; !reflect.DeepEqual(got, want) {
+		t.Errorf("v.Assets() = %q, want %q", got, want)
+	}
+}
+
+func TestClientSideOption(t *testing.T) {
+	v := &vega.Vega{ScriptURLs: []string{"/vega.js"}}
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"vega-lite": v.Pipe,
+		},
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	var buf bytes.Buffer
+	pc := parser.NewContext()
+	input := "```vega-lite mode=client\n{}\n```\n"
+	if err := gmark.Convert([]byte(input), &buf, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	if got, want := ext.RequiredAssets(pc), []string{"/vega.js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ext.RequiredAssets() = %q, want %q", got, want)
+	}
+}
+
+func TestServerSideURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("request content type = %q, want application/json", ct)
+		}
+		io.WriteString(w, "<svg/>")
+	}))
+	defer srv.Close()
+
+	v := &vega.Vega{URL: srv.URL}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"vega-lite": v.Pipe,
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Input string
+		Want  string
+	}{
+		{Input: "```vega-lite\n{}\n```\n", Want: "<svg/>"},
+		{
+			Input: "```vega-lite\n{\n```\n",
+			Want: "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">" +
+				"fenced block transformer &quot;vega-lite&quot;: invalid Vega-Lite spec: not valid JSON</pre>\n",
+		},
+	} {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if got := buf.String(); got != tt.Want {
+			t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+		}
+	}
+
+	if got := v.Assets(); got != nil {
+		t.Errorf("v.Assets() = %q, want nil", got)
+	}
+}
@@ -0,0 +1,126 @@
+package pipefence
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark/parser"
+)
+
+// Warning is a problem with a block which does not make it fail,
+// such as an option which the pipe ignores.  Warnings give authors
+// actionable feedback without failing builds.
+type Warning struct {
+	Line     int    // Line of the block, starting at 1.
+	Language string // Language of the block.
+	Message  string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s: %s", w.Line, w.Language, w.Message)
+}
+
+// warningsKey is the context key for the warnings of the running
+// pipe.
+type warningsKey struct{}
+
+// warnings collects the warnings of a pipe.
+type warnings struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (w *warnings) list() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.messages
+}
+
+// Warnf reports a warning about the block being piped, such as
+//
+//	pipefence.Warnf(ctx, "unknown option %q ignored", name)
+//
+// The warning is reported with OnWarning and Warnings.  Pipe
+// functions call it with the context they were passed; outside of
+// pipe functions it does nothing.  As with RequireAssets, the
+// warnings are stored in the Cache, and reported again when the
+// output is taken from it.
+func Warnf(ctx context.Context, format string, args ...any) {
+	w, ok := ctx.Value(warningsKey{}).(*warnings)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.messages = append(w.messages, fmt.Sprintf(format, args...))
+}
+
+// warningsPCKey is the parser.Context key for the warnings of a
+// conversion.
+var warningsPCKey = parser.NewContextKey()
+
+// deprecationWarnings returns the warnings for the stages of the
+// language lang, as written in the document, which are deprecated
+// aliases.
+func (e *Extension) deprecationWarnings(lang string) []string {
+	if len(e.DeprecatedAliases) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, stage := range strings.Split(lang, "|") {
+		stage = strings.TrimSpace(stage)
+		if !slices.Contains(e.DeprecatedAliases, stage) {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("language %q is deprecated, use %q", stage, e.canonical(stage)))
+	}
+	return msgs
+}
+
+// reportWarnings passes the given warnings and those of the jobs to
+// OnWarning in document order, and records them in pc unless it is
+// nil.  The warnings of the jobs are cleared, so that they are
+// reported only once.
+func (e *Extension) reportWarnings(pc parser.Context, ws []Warning, jobs []*job) {
+	for _, j := range jobs {
+		for _, msg := range j.warnings {
+			ws = append(ws, Warning{Line: j.line, Language: j.info.Language, Message: msg})
+		}
+		j.warnings = nil
+	}
+	if len(ws) == 0 {
+		return
+	}
+	sort.SliceStable(ws, func(i, k int) bool { return ws[i].Line < ws[k].Line })
+	if e.OnWarning != nil {
+		for _, w := range ws {
+			e.OnWarning(w)
+		}
+	}
+	if pc != nil {
+		all, _ := pc.Get(warningsPCKey).([]Warning)
+		pc.Set(warningsPCKey, append(all, ws...))
+	}
+}
+
+// Warnings returns the warnings of a conversion in document order.
+// It is called after the conversion, with its parser.Context:
+//
+//	pc := parser.NewContext()
+//	err := md.Convert(src, w, parser.WithContext(pc))
+//	for _, w := range ext.Warnings(pc) {
+//		log.Printf("%s:%v", path, w)
+//	}
+//
+// With WithPlaceholders, the warnings of pipes which run in
+// RunPending are only passed to OnWarning.
+func (e *Extension) Warnings(pc parser.Context) []Warning {
+	ws, _ := pc.Get(warningsPCKey).([]Warning)
+	return ws
+}
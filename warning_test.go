@@ -0,0 +1,54 @@
+package pipefence_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+func TestWarnings(t *testing.T) {
+	var got []pipefence.Warning
+	ext := &pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"dot": func(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+				for name := range info.Options {
+					pipefence.Warnf(ctx, "unknown option %q ignored", name)
+				}
+				return src, nil
+			},
+		},
+		Aliases:           map[string]string{"graphviz": "dot", "gv": "dot"},
+		DeprecatedAliases: []string{"graphviz"},
+		WarnOutputSize:    8,
+		OnWarning:         func(w pipefence.Warning) { got = append(got, w) },
+	}
+	gmark := goldmark.New(goldmark.WithExtensions(ext))
+
+	pc := parser.NewContext()
+	src := "```gv\nok\n```\n\n```graphviz\nok\n```\n\n```dot scale=2\nok\n```\n\n```dot\nvery long\n```\n"
+	if err := gmark.Convert([]byte(src), io.Discard, parser.WithContext(pc)); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+
+	want := []pipefence.Warning{
+		{Line: 5, Language: "dot", Message: `language "graphviz" is deprecated, use "dot"`},
+		{Line: 9, Language: "dot", Message: `unknown option "scale" ignored`},
+		{Line: 13, Language: "dot", Message: "output of 10 bytes exceeds 8 bytes"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OnWarning got %v, want %v", got, want)
+	}
+	if ws := ext.Warnings(pc); !reflect.DeepEqual(ws, want) {
+		t.Errorf("Warnings() = %v, want %v", ws, want)
+	}
+}
+
+func TestWarnfOutsidePipe(t *testing.T) {
+	// Must not panic.
+	pipefence.Warnf(context.Background(), "ignored")
+}
@@ -0,0 +1,10 @@
+module github.com/gnoack/goldmark-pipefence/wasmpipe
+
+go 1.21
+
+require (
+	github.com/gnoack/goldmark-pipefence v0.0.0-20261016025848-a08b4f0b800e
+	github.com/tetratelabs/wazero v1.8.2
+)
+
+require github.com/yuin/goldmark v1.5.4 // indirect
@@ -0,0 +1,97 @@
+// Package wasmpipe runs WebAssembly modules as pipefence pipes.
+//
+// The modules run sandboxed inside the Go process using wazero, so
+// transformers written in any language which compiles to
+// WebAssembly can be used without trusting them with access to the
+// host.
+//
+// A module must export its linear memory as "memory" and the
+// following functions:
+//
+//	alloc(size i32) -> (ptr i32)
+//	transform(ptr i32, len i32) -> (result i64)
+//
+// alloc returns a buffer of the given size, into which the fenced
+// code block content is copied.  transform transforms the content
+// and returns the location of the output, packed as
+// (out_ptr << 32) | out_len.  To report an error, transform traps
+// (e.g. with the unreachable instruction).
+//
+// Every invocation runs in a fresh module instance, so invocations
+// can neither interfere with each other nor run concurrently on
+// the same memory.
+package wasmpipe
+
+import (
+	"context"
+	"fmt"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/tetratelabs/wazero"
+)
+
+// Module is a compiled WebAssembly transformer.
+type Module struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// New compiles the WebAssembly module wasm.  The returned Module
+// must be closed when it is no longer needed.
+func New(ctx context.Context, wasm []byte) (*Module, error) {
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	compiled, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("wasmpipe: compiling module: %w", err)
+	}
+	for _, name := range []string{"alloc", "transform"} {
+		if _, ok := compiled.ExportedFunctions()[name]; !ok {
+			runtime.Close(ctx)
+			return nil, fmt.Errorf("wasmpipe: module does not export %q", name)
+		}
+	}
+	return &Module{runtime: runtime, compiled: compiled}, nil
+}
+
+// Close releases the resources of the module.
+func (m *Module) Close(ctx context.Context) error {
+	return m.runtime.Close(ctx)
+}
+
+// Pipe is a pipefence.PipeFuncCtx running the module's transform
+// function on src.
+func (m *Module) Pipe(ctx context.Context, src []byte, _ pipefence.Info) ([]byte, error) {
+	// The empty name allows multiple concurrent instances.
+	mod, err := m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return nil, fmt.Errorf("wasmpipe: instantiating module: %w", err)
+	}
+	defer mod.Close(ctx)
+
+	mem := mod.Memory()
+	if mem == nil {
+		return nil, fmt.Errorf("wasmpipe: module does not export memory")
+	}
+
+	res, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(src)))
+	if err != nil {
+		return nil, fmt.Errorf("wasmpipe: alloc: %w", err)
+	}
+	ptr := uint32(res[0])
+	if !mem.Write(ptr, src) {
+		return nil, fmt.Errorf("wasmpipe: alloc returned out of range buffer")
+	}
+
+	res, err = mod.ExportedFunction("transform").Call(ctx, uint64(ptr), uint64(len(src)))
+	if err != nil {
+		return nil, fmt.Errorf("wasmpipe: transform: %w", err)
+	}
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	out, ok := mem.Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("wasmpipe: transform returned out of range output")
+	}
+	// out aliases the module memory, which is freed on Close.
+	return append([]byte(nil), out...), nil
+}
@@ -0,0 +1,67 @@
+package wasmpipe_test
+
+import (
+	"context"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/wasmpipe"
+)
+
+// identityWasm is a module whose transform function returns its
+// input unchanged:
+//
+//	(module
+//	  (memory (export "memory") 1)
+//	  (func (export "alloc") (param i32) (result i32)
+//	    i32.const 1024)
+//	  (func (export "transform") (param i32 i32) (result i64)
+//	    local.get 0
+//	    i64.extend_i32_u
+//	    i64.const 32
+//	    i64.shl
+//	    local.get 1
+//	    i64.extend_i32_u
+//	    i64.or))
+var identityWasm = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // Header.
+	0x01, 0x0c, 0x02, // Type section.
+	0x60, 0x01, 0x7f, 0x01, 0x7f, // (i32) -> i32
+	0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, // (i32, i32) -> i64
+	0x03, 0x03, 0x02, 0x00, 0x01, // Function section.
+	0x05, 0x03, 0x01, 0x00, 0x01, // Memory section.
+	0x07, 0x1e, 0x03, // Export section.
+	0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	0x05, 'a', 'l', 'l', 'o', 'c', 0x00, 0x00,
+	0x09, 't', 'r', 'a', 'n', 's', 'f', 'o', 'r', 'm', 0x00, 0x01,
+	0x0a, 0x14, 0x02, // Code section.
+	0x05, 0x00, 0x41, 0x80, 0x08, 0x0b,
+	0x0c, 0x00, 0x20, 0x00, 0xad, 0x42, 0x20, 0x86, 0x20, 0x01, 0xad, 0x84, 0x0b,
+}
+
+func TestModule(t *testing.T) {
+	ctx := context.Background()
+	m, err := wasmpipe.New(ctx, identityWasm)
+	if err != nil {
+		t.Fatalf("wasmpipe.New: %v", err)
+	}
+	defer m.Close(ctx)
+
+	var pipe pipefence.PipeFuncCtx = m.Pipe
+	for _, in := range []string{"box \"lolcat\"\n", ""} {
+		got, err := pipe(ctx, []byte(in), pipefence.Info{})
+		if err != nil {
+			t.Fatalf("m.Pipe(%q): %v", in, err)
+		}
+		if string(got) != in {
+			t.Errorf("m.Pipe(%q) = %q, want %q", in, got, in)
+		}
+	}
+}
+
+func TestMissingExports(t *testing.T) {
+	empty := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	if _, err := wasmpipe.New(context.Background(), empty); err == nil {
+		t.Errorf("wasmpipe.New(empty module): err = nil, want error")
+	}
+}
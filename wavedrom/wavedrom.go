@@ -0,0 +1,128 @@
+// Package wavedrom provides a pipefence pipe for WaveDrom digital
+// timing diagrams.
+//
+// Diagrams are either rendered to SVG on the server, with the
+// wavedrom-cli tool or a remote rendering service such as Kroki, or
+// left to the WaveDrom JavaScript library in the browser.
+//
+// Example:
+//
+//	w := &wavedrom.WaveDrom{Mode: wavedrom.ClientSide}
+//	ext := &pipefence.Extension{
+//		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+//			"wavedrom": w.Pipe,
+//		},
+//	}
+//	// ... convert, then include ext.RequiredAssets(pc) as <script> tags and
+//	// call WaveDrom.ProcessAll() once the page has loaded.
+//
+// The mode can be overridden per block with the mode option, which
+// is "server" or "client":
+//
+//	```wavedrom mode=server
+//	{signal: [{name: "clk", wave: "p....."}]}
+//	```
+package wavedrom
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+)
+
+// Mode selects where diagrams are rendered.
+type Mode int
+
+const (
+	// ServerSide renders diagrams to SVG using wavedrom-cli, or
+	// the service at WaveDrom.URL.
+	ServerSide Mode = iota
+
+	// ClientSide emits the diagram source in a <script
+	// type="WaveDrom"> element, to be rendered by
+	// WaveDrom.ProcessAll() in the browser.
+	ClientSide
+)
+
+// DefaultScriptURLs are the scripts returned by Assets in
+// ClientSide mode if no ScriptURLs are set.
+var DefaultScriptURLs = []string{
+	"https://cdn.jsdelivr.net/npm/wavedrom@3/skins/default.js",
+	"https://cdn.jsdelivr.net/npm/wavedrom@3/wavedrom.min.js",
+}
+
+// WaveDrom renders WaveDrom timing diagrams.
+type WaveDrom struct {
+	// Mode selects server-side or client-side rendering.
+	Mode Mode
+
+	// Command is the wavedrom-cli binary used in ServerSide mode.
+	// If empty, "wavedrom-cli" is used.
+	Command string
+
+	// URL, if set, is the URL of a rendering service used in
+	// ServerSide mode instead of Command, e.g.
+	// "https://kroki.io/wavedrom/svg".  The diagram source is
+	// POSTed to it with pipefence.HTTPPipe, and the response is the
+	// SVG.
+	URL string
+
+	// HTTPOptions configure the requests to URL.
+	HTTPOptions pipefence.HTTPOptions
+
+	// ScriptURLs are the scripts returned by Assets.  If nil,
+	// DefaultScriptURLs are used.
+	ScriptURLs []string
+}
+
+// Pipe is a pipefence.PipeFuncCtx rendering a WaveDrom diagram.
+func (w *WaveDrom) Pipe(ctx context.Context, src []byte, info pipefence.Info) ([]byte, error) {
+	mode := w.Mode
+	switch m := info.Options["mode"]; m {
+	case "":
+	case "server":
+		mode = ServerSide
+	case "client":
+		mode = ClientSide
+	default:
+		return nil, fmt.Errorf("invalid mode %q", m)
+	}
+
+	if mode == ClientSide {
+		pipefence.RequireAssets(ctx, w.scripts()...)
+		// The source must not end the script element early.
+		js := bytes.ReplaceAll(src, []byte("</"), []byte(`<\/`))
+		return []byte("<script type=\"WaveDrom\">\n" + string(js) + "</script>\n"), nil
+	}
+	if w.URL != "" {
+		return pipefence.HTTPPipe(w.URL, w.HTTPOptions)(ctx, src, info)
+	}
+	cmd := w.Command
+	if cmd == "" {
+		cmd = "wavedrom-cli"
+	}
+	return pipefence.ExecPipe(cmd, "--input", "/dev/stdin", "--svg", "/dev/stdout")(ctx, src, info)
+}
+
+// Assets returns the URLs of the JavaScript files which need to be
+// included on pages with diagrams.  In ServerSide mode, no scripts are
+// needed, unless blocks select client-side rendering.  Pipe declares
+// the scripts with pipefence.RequireAssets for each block rendered on
+// the client, so that Extension.RequiredAssets reports them for the
+// pages which need them.
+func (w *WaveDrom) Assets() []string {
+	if w.Mode != ClientSide {
+		return nil
+	}
+	return w.scripts()
+}
+
+// scripts returns the client-side rendering scripts.
+func (w *WaveDrom) scripts() []string {
+	if w.ScriptURLs != nil {
+		return w.ScriptURLs
+	}
+	return DefaultScriptURLs
+}
@@ -0,0 +1,80 @@
+package wavedrom_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/gnoack/goldmark-pipefence/wavedrom"
+	"github.com/yuin/goldmark"
+)
+
+func TestClientSide(t *testing.T) {
+	w := &wavedrom.WaveDrom{Mode: wavedrom.ClientSide}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"wavedrom": w.Pipe,
+		},
+	}))
+
+	var buf bytes.Buffer
+	input := "```wavedrom\n{signal: [{name: \"</script>\", wave: \"p..\"}]}\n```\n"
+	if err := gmark.Convert([]byte(input), &buf); err != nil {
+		t.Fatalf("gmark.Convert: %v", err)
+	}
+	want := "<script type=\"WaveDrom\">\n{signal: [{name: \"<\\/script>\", wave: \"p..\"}]}\n</script>\n"
+	if got := buf.String(); got != want {
+		t.Errorf("gmark.Convert(%q) = %q, want %q", input, got, want)
+	}
+
+	if got, want := w.Assets(), wavedrom.DefaultScriptURLs; !reflect.DeepEqual(got, want) {
+		t.Errorf("w.Assets() = %q, want %q", got, want)
+	}
+}
+
+func TestServerSideURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<svg/>")
+	}))
+	defer srv.Close()
+
+	w := &wavedrom.WaveDrom{URL: srv.URL}
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"wavedrom": w.Pipe,
+		},
+		ErrorMode: pipefence.RenderErrorInline,
+	}))
+
+	for _, tt := range []struct {
+		Input string
+		Want  string
+	}{
+		{Input: "```wavedrom\n{signal: []}\n```\n", Want: "<svg/>"},
+		{
+			Input: "```wavedrom mode=client\n{signal: []}\n```\n",
+			Want:  "<script type=\"WaveDrom\">\n{signal: []}\n</script>\n",
+		},
+		{
+			Input: "```wavedrom mode=browser\n{signal: []}\n```\n",
+			Want: "<pre class=\"pipefence-error\" style=\"border: 1px solid red; color: red; padding: 0.5em\">" +
+				"fenced block transformer &quot;wavedrom&quot;: invalid mode &quot;browser&quot;</pre>\n",
+		},
+	} {
+		var buf bytes.Buffer
+		if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+			t.Fatalf("gmark.Convert: %v", err)
+		}
+		if got := buf.String(); got != tt.Want {
+			t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+		}
+	}
+
+	if got := w.Assets(); got != nil {
+		t.Errorf("w.Assets() = %q, want nil", got)
+	}
+}
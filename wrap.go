@@ -0,0 +1,35 @@
+package pipefence
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// wrapElement returns content wrapped in an HTML element with the
+// given name, carrying the block's attributes.
+func wrapElement(element string, attrs map[string]string, content []byte) []byte {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("<")
+	buf.WriteString(element)
+	for _, name := range names {
+		buf.WriteString(" ")
+		buf.Write(util.EscapeHTML([]byte(name)))
+		buf.WriteString(`="`)
+		buf.Write(util.EscapeHTML([]byte(attrs[name])))
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">\n")
+	buf.Write(content)
+	buf.WriteString("</")
+	buf.WriteString(element)
+	buf.WriteString(">\n")
+	return buf.Bytes()
+}
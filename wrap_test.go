@@ -0,0 +1,83 @@
+package pipefence_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	pipefence "github.com/gnoack/goldmark-pipefence"
+	"github.com/yuin/goldmark"
+)
+
+func TestAttributes(t *testing.T) {
+	var gotInfo pipefence.Info
+	gmark := goldmark.New(goldmark.WithExtensions(&pipefence.Extension{
+		PipeFuncsCtx: map[string]pipefence.PipeFuncCtx{
+			"dot": func(_ context.Context, a []byte, info pipefence.Info) ([]byte, error) {
+				gotInfo = info
+				return []byte("<svg/>\n"), nil
+			},
+		},
+		WrapElement: "div",
+	}))
+
+	for _, tt := range []struct {
+		Name     string
+		Input    string
+		Want     string
+		WantInfo pipefence.Info
+	}{
+		{
+			Name:  "Attributes",
+			Input: "```dot scale=2 {#topology .wide .dark data-zoom=2}\ndigraph{}\n```\n",
+			Want:  "<div class=\"wide dark\" data-zoom=\"2\" id=\"topology\">\n<svg/>\n</div>\n",
+			WantInfo: pipefence.Info{
+				Raw:      "dot scale=2 {#topology .wide .dark data-zoom=2}",
+				Language: "dot",
+				Options:  map[string]string{"scale": "2"},
+				Attributes: map[string]string{
+					"id":        "topology",
+					"class":     "wide dark",
+					"data-zoom": "2",
+				},
+				Line: 1,
+			},
+		},
+		{
+			Name:  "NoAttributes",
+			Input: "```dot\ndigraph{}\n```\n",
+			Want:  "<svg/>\n",
+			WantInfo: pipefence.Info{
+				Raw:      "dot",
+				Language: "dot",
+				Options:  map[string]string{},
+				Line:     1,
+			},
+		},
+		{
+			Name:  "InvalidAttributeBlock",
+			Input: "```dot {not valid!}\ndigraph{}\n```\n",
+			Want:  "<svg/>\n",
+			WantInfo: pipefence.Info{
+				Raw:      "dot {not valid!}",
+				Language: "dot",
+				Options:  map[string]string{"{not": "", "valid!}": ""},
+				Line:     1,
+			},
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gmark.Convert([]byte(tt.Input), &buf); err != nil {
+				t.Fatalf("gmark.Convert: %v", err)
+			}
+			if got := buf.String(); got != tt.Want {
+				t.Errorf("gmark.Convert(%q) = %q, want %q", tt.Input, got, tt.Want)
+			}
+			if !reflect.DeepEqual(gotInfo, tt.WantInfo) {
+				t.Errorf("PipeFuncCtx got info %+v, want %+v", gotInfo, tt.WantInfo)
+			}
+		})
+	}
+}